@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Context key holding the wallet identity of an authenticated client
+const userKey = "authUser"
+
+// ErrInvalidToken is returned for requests without a known bearer token
+var ErrInvalidToken = errors.New("invalid or missing API token")
+
+// loadTokens reads the IMAGE_API_TOKENS file: a JSON object mapping the
+// hex SHA-256 of each API token to the wallet identity the token acts as.
+// Only the digests are stored, so the file does not hold usable tokens:
+//
+//	{"<printf %s "$TOKEN" | sha256sum>": "Admin"}
+func loadTokens() (map[string]string, error) {
+	path := os.Getenv("IMAGE_API_TOKENS")
+	if path == "" {
+		return nil, errors.New("IMAGE_API_TOKENS is not set")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API tokens: %w", err)
+	}
+
+	var tokens map[string]string
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("invalid API tokens file %s: %w", path, err)
+	}
+
+	return tokens, nil
+}
+
+// tokenIdentity returns the wallet identity of a bearer token
+func tokenIdentity(tokens map[string]string, authorization string) (string, error) {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || token == "" {
+		return "", ErrInvalidToken
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	user, ok := tokens[hex.EncodeToString(sum[:])]
+	if !ok || user == "" {
+		return "", ErrInvalidToken
+	}
+
+	return user, nil
+}
+
+// abort replies with the same body as common.Abort and stops the chain
+func abort(c *gin.Context, status int, err error) {
+	c.AbortWithStatusJSON(status, gin.H{
+		"status": status,
+		"error":  err.Error(),
+	})
+	c.Error(err)
+}
+
+// Authenticate maps the bearer token of the request to the Fabric identity
+// used for the ledger calls of the route. The User header is ignored: a
+// wallet identity chosen by the client is never trusted
+func Authenticate(c *gin.Context) {
+	tokens, err := loadTokens()
+	if err != nil {
+		abort(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	user, err := tokenIdentity(tokens, c.GetHeader("Authorization"))
+	if err != nil {
+		c.Header("WWW-Authenticate", "Bearer")
+		abort(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	c.Set(userKey, user)
+	c.Next()
+}
+
+// User returns the identity set by Authenticate, or "" when the request
+// did not go through it
+func User(c *gin.Context) string {
+	return c.GetString(userKey)
+}
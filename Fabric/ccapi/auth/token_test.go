@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func tokenDigest(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Authenticate)
+	r.GET("/whoami", func(c *gin.Context) { c.String(http.StatusOK, User(c)) })
+	return r
+}
+
+func TestAuthenticate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	tokens := `{"` + tokenDigest("segredo-admin") + `":"Admin","` + tokenDigest("segredo-leitor") + `":"User1"}`
+	if err := os.WriteFile(path, []byte(tokens), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("IMAGE_API_TOKENS", path)
+	r := newTestRouter()
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		status int
+		user   string
+	}{
+		{"admin token", map[string]string{"Authorization": "Bearer segredo-admin"}, http.StatusOK, "Admin"},
+		{"reader token", map[string]string{"Authorization": "Bearer segredo-leitor"}, http.StatusOK, "User1"},
+		{"User header is ignored", map[string]string{"Authorization": "Bearer segredo-leitor", "User": "Admin"}, http.StatusOK, "User1"},
+		{"no token", map[string]string{"User": "Admin"}, http.StatusUnauthorized, ""},
+		{"unknown token", map[string]string{"Authorization": "Bearer outro"}, http.StatusUnauthorized, ""},
+		{"stored digest", map[string]string{"Authorization": "Bearer " + tokenDigest("segredo-admin")}, http.StatusUnauthorized, ""},
+		{"other scheme", map[string]string{"Authorization": "Basic segredo-admin"}, http.StatusUnauthorized, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+			for key, value := range test.header {
+				req.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.status {
+				t.Fatalf("expected status %d, got %d: %s", test.status, w.Code, w.Body)
+			}
+			if test.status == http.StatusOK && w.Body.String() != test.user {
+				t.Errorf("expected identity %s, got %s", test.user, w.Body)
+			}
+		})
+	}
+}
+
+func TestAuthenticateWithoutTokens(t *testing.T) {
+	t.Setenv("IMAGE_API_TOKENS", "")
+
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	req.Header.Set("User", "Admin")
+	w := httptest.NewRecorder()
+	newTestRouter().ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected requests to be refused without a token file, got %d", w.Code)
+	}
+}
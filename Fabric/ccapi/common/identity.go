@@ -0,0 +1,37 @@
+package common
+
+import (
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+)
+
+// Certificate extension where Fabric CA stores enrollment attributes
+var attrsOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// UserAttribute returns an enrollment attribute of the identity the gateway
+// uses for user, the same attribute the chaincode reads from the client identity
+func UserAttribute(user, name string) (string, bool, error) {
+	cert, err := loadCertificate(getSignCert(user))
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(attrsOID) {
+			continue
+		}
+
+		var attrs struct {
+			Attrs map[string]string `json:"attrs"`
+		}
+		if err := json.Unmarshal(ext.Value, &attrs); err != nil {
+			return "", false, fmt.Errorf("invalid attributes in certificate of %s: %w", user, err)
+		}
+
+		value, ok := attrs.Attrs[name]
+		return value, ok, nil
+	}
+
+	return "", false, nil
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger-labs/cc-tools-demo/ccapi/auth"
+	"github.com/hyperledger-labs/cc-tools-demo/ccapi/common"
+)
+
+// Enrollment attribute and value the sollytch chaincodes require for
+// administrative transactions
+const (
+	roleAttribute = "sollytch.role"
+	adminRole     = "admin"
+)
+
+// requestUser returns the identity authenticated from the request's API token
+func requestUser(c *gin.Context) string {
+	return auth.User(c)
+}
+
+// requireAdmin aborts with 403 unless the authenticated identity holds the admin role
+func requireAdmin(c *gin.Context) bool {
+	user := requestUser(c)
+	if user == "" {
+		common.Abort(c, http.StatusUnauthorized, auth.ErrInvalidToken)
+		return false
+	}
+
+	role, _, err := common.UserAttribute(user, roleAttribute)
+	if err != nil {
+		common.Abort(c, http.StatusForbidden, fmt.Errorf("failed to load identity %s: %w", user, err))
+		return false
+	}
+	if role != adminRole {
+		common.Abort(c, http.StatusForbidden, fmt.Errorf("identity %s does not have the %s role", user, adminRole))
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,421 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger-labs/cc-tools-demo/ccapi/chaincode"
+	"github.com/hyperledger-labs/cc-tools-demo/ccapi/common"
+	"github.com/hyperledger-labs/cc-tools-demo/ccapi/imagequality"
+	"github.com/hyperledger-labs/cc-tools-demo/ccapi/imagestore"
+)
+
+// Default upload limit for a single image
+const defaultMaxImageBytes = 20 << 20
+
+// Status of images in use in sollytch-image
+const imageActive = "ativa"
+
+// ledgerImage holds the sollytch-image record fields checked against a stored blob
+type ledgerImage struct {
+	IDKit         string `json:"idKit"`
+	HashData      string `json:"hashData"`
+	HashAlgorithm string `json:"hashAlgorithm"`
+	SizeBytes     int64  `json:"sizeBytes"`
+	Status        string `json:"status"`
+}
+
+// LedgerCheck reports whether a stored blob matches its sollytch-image record
+type LedgerCheck struct {
+	Registered  bool   `json:"registered"`
+	IDKit       string `json:"idKit,omitempty"`
+	Status      string `json:"status,omitempty"`
+	SizeMatches bool   `json:"sizeMatches"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ImageVerification is the integrity report returned by VerifyImage
+type ImageVerification struct {
+	Hash   string               `json:"hash"`
+	Intact bool                 `json:"intact"`
+	Info   *imagestore.BlobInfo `json:"info,omitempty"`
+	Ledger *LedgerCheck         `json:"ledger,omitempty"`
+}
+
+func imageStore(c *gin.Context) *imagestore.Store {
+	store, err := imagestore.Default()
+	if err != nil {
+		common.Abort(c, http.StatusInternalServerError, err)
+		return nil
+	}
+	return store
+}
+
+func storeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, imagestore.ErrInvalidHash):
+		return http.StatusBadRequest
+	case errors.Is(err, imagestore.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, imagestore.ErrRetained):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func uploadErrorStatus(err error, fallback int) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return fallback
+}
+
+func maxImageBytes() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("IMAGE_MAX_BYTES"), 10, 64); err == nil && value > 0 {
+		return value
+	}
+	return defaultMaxImageBytes
+}
+
+// ledgerVerificationEnabled is true unless IMAGE_LEDGER_VERIFY=false
+func ledgerVerificationEnabled() bool {
+	return os.Getenv("IMAGE_LEDGER_VERIFY") != "false"
+}
+
+func imageChaincodeName() string {
+	if name := os.Getenv("IMAGE_CCNAME"); name != "" {
+		return name
+	}
+	return "sollytch-image"
+}
+
+// checkLedger looks up the image in sollytch-image and compares it to the stored blob
+func checkLedger(c *gin.Context, info *imagestore.BlobInfo) *LedgerCheck {
+	result, err := chaincode.QueryGateway(os.Getenv("CHANNEL"), imageChaincodeName(), "GetImageByID", requestUser(c), []string{info.Hash})
+	if err != nil {
+		err, _ := common.ParseError(err)
+		return &LedgerCheck{Error: err.Error()}
+	}
+
+	var record ledgerImage
+	if err := json.Unmarshal(result, &record); err != nil {
+		return &LedgerCheck{Error: err.Error()}
+	}
+	if record.HashData != info.Hash || (record.HashAlgorithm != "" && record.HashAlgorithm != "sha256") {
+		return &LedgerCheck{Error: "ledger record does not describe this image"}
+	}
+
+	return &LedgerCheck{
+		Registered:  true,
+		IDKit:       record.IDKit,
+		Status:      record.Status,
+		SizeMatches: record.SizeBytes == info.Size,
+	}
+}
+
+// requireLedgerImage aborts with 409 unless the stored blob matches its
+// sollytch-image record
+func requireLedgerImage(c *gin.Context, info *imagestore.BlobInfo) (*LedgerCheck, bool) {
+	ledger := checkLedger(c, info)
+	if !ledger.Registered || !ledger.SizeMatches {
+		common.Abort(c, http.StatusConflict, fmt.Errorf("image does not match sollytch-image record: %+v", *ledger))
+		return nil, false
+	}
+	return ledger, true
+}
+
+// openUpload returns the image from a multipart "file" field or the raw request body
+func openUpload(c *gin.Context) (io.ReadCloser, string, error) {
+	if file, err := c.FormFile("file"); err == nil {
+		return openFormFile(file)
+	} else if !errors.Is(err, http.ErrNotMultipart) && !errors.Is(err, http.ErrMissingFile) {
+		return nil, "", err
+	}
+
+	return c.Request.Body, c.ContentType(), nil
+}
+
+func openFormFile(file *multipart.FileHeader) (io.ReadCloser, string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	return f, file.Header.Get("Content-Type"), nil
+}
+
+// readUpload reads the whole uploaded image, up to IMAGE_MAX_BYTES
+func readUpload(c *gin.Context) ([]byte, string, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageBytes())
+
+	body, mimeType, err := openUpload(c)
+	if err != nil {
+		common.Abort(c, uploadErrorStatus(err, http.StatusBadRequest), err)
+		return nil, "", false
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		common.Abort(c, uploadErrorStatus(err, http.StatusBadRequest), err)
+		return nil, "", false
+	}
+
+	return data, mimeType, true
+}
+
+// assessQuality analyzes an image against the IMAGE_QUALITY_* thresholds
+func assessQuality(c *gin.Context, data []byte) (*imagequality.Report, bool) {
+	thresholds, err := imagequality.ThresholdsFromEnv()
+	if err != nil {
+		common.Abort(c, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	metrics, err := imagequality.Analyze(data)
+	if errors.Is(err, imagequality.ErrUnsupportedFormat) {
+		common.Abort(c, http.StatusUnsupportedMediaType, err)
+		return nil, false
+	}
+	if err != nil {
+		common.Abort(c, http.StatusUnprocessableEntity, err)
+		return nil, false
+	}
+
+	return thresholds.Evaluate(metrics), true
+}
+
+// registerImage submits StoreImage to sollytch-image for a stored image. The
+// capture metadata comes from the client; hash, size, type, dimensions and
+// blurScore are overwritten with the values measured here
+func registerImage(c *gin.Context, idKit string, metadata map[string]interface{}, info *imagestore.BlobInfo, quality *imagequality.Report) error {
+	metadata["hashAlgorithm"] = "sha256"
+	metadata["sizeBytes"] = info.Size
+	metadata["mimeType"] = info.MimeType
+	if quality != nil {
+		metadata["width"] = quality.Metrics.Width
+		metadata["height"] = quality.Metrics.Height
+		metadata["blurScore"] = quality.Metrics.BlurScore
+	}
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = chaincode.InvokeGateway(os.Getenv("CHANNEL"), imageChaincodeName(), "StoreImage", requestUser(c), []string{idKit, info.Hash, string(metadataJSON)}, nil, nil)
+	return err
+}
+
+// StoreImage saves an uploaded image by its SHA-256.
+// Re-uploading identical content returns the existing record with created=false.
+// Unless IMAGE_QUALITY_MODE=off, the image is first checked for blur, exposure
+// and glare: with "reject" (default) a failing image is not stored, with "flag"
+// it is stored and the failed checks are returned.
+// A multipart upload with idKit and metadata (the capture metadata JSON) fields
+// is also registered in sollytch-image, only after it passes the quality check,
+// so a rejected image never reaches the ledger
+func StoreImage(c *gin.Context) {
+	store := imageStore(c)
+	if store == nil {
+		return
+	}
+
+	mode, err := imagequality.ModeFromEnv()
+	if err != nil {
+		common.Abort(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	data, mimeType, ok := readUpload(c)
+	if !ok {
+		return
+	}
+
+	idKit := c.PostForm("idKit")
+	var metadata map[string]interface{}
+	if idKit != "" {
+		if err := json.Unmarshal([]byte(c.PostForm("metadata")), &metadata); err != nil {
+			common.Abort(c, http.StatusBadRequest, fmt.Errorf("invalid capture metadata: %w", err))
+			return
+		}
+		if metadata == nil {
+			common.Abort(c, http.StatusBadRequest, fmt.Errorf("capture metadata is required with idKit"))
+			return
+		}
+	}
+
+	var quality *imagequality.Report
+	if mode != imagequality.ModeOff {
+		if quality, ok = assessQuality(c, data); !ok {
+			return
+		}
+
+		if !quality.Passed && mode == imagequality.ModeReject {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"status":  http.StatusUnprocessableEntity,
+				"error":   "image rejected by quality check",
+				"quality": quality,
+			})
+			return
+		}
+	}
+
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(data)
+	}
+
+	info, created, err := store.Put(bytes.NewReader(data), mimeType)
+	if err != nil {
+		common.Abort(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	if idKit != "" {
+		if err := registerImage(c, idKit, metadata, info, quality); err != nil {
+			err, status := common.ParseError(err)
+			common.Abort(c, status, fmt.Errorf("image stored but not registered in sollytch-image: %w", err))
+			return
+		}
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	c.JSON(status, gin.H{
+		"created":    created,
+		"info":       info,
+		"quality":    quality,
+		"registered": idKit != "",
+	})
+}
+
+// AssessImage returns the quality report of an uploaded image without storing it
+func AssessImage(c *gin.Context) {
+	data, _, ok := readUpload(c)
+	if !ok {
+		return
+	}
+
+	quality, ok := assessQuality(c, data)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, quality)
+}
+
+// GetImage serves a stored image after checking its content against its hash
+// and, unless IMAGE_LEDGER_VERIFY=false, against the sollytch-image record
+func GetImage(c *gin.Context) {
+	store := imageStore(c)
+	if store == nil {
+		return
+	}
+
+	data, info, err := store.Read(c.Param("hash"))
+	if err != nil {
+		common.Abort(c, storeErrorStatus(err), err)
+		return
+	}
+
+	if ledgerVerificationEnabled() {
+		ledger, ok := requireLedgerImage(c, info)
+		if !ok {
+			return
+		}
+		c.Header("X-Ledger-Kit", ledger.IDKit)
+		c.Header("X-Ledger-Status", ledger.Status)
+	}
+
+	mimeType := info.MimeType
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+
+	c.Header("X-Content-SHA256", info.Hash)
+	c.Header("ETag", strconv.Quote(info.Hash))
+	c.Data(http.StatusOK, mimeType, data)
+}
+
+// VerifyImage reports disk integrity and the sollytch-image record of a stored image
+func VerifyImage(c *gin.Context) {
+	store := imageStore(c)
+	if store == nil {
+		return
+	}
+
+	hash := c.Param("hash")
+	report := ImageVerification{Hash: hash}
+
+	info, err := store.Info(hash)
+	if err != nil {
+		common.Abort(c, storeErrorStatus(err), err)
+		return
+	}
+	report.Info = info
+
+	if _, _, err := store.Read(hash); err == nil {
+		report.Intact = true
+	} else if !errors.Is(err, imagestore.ErrCorrupted) {
+		common.Abort(c, storeErrorStatus(err), err)
+		return
+	}
+
+	report.Ledger = checkLedger(c, info)
+
+	c.JSON(http.StatusOK, report)
+}
+
+// DeleteImage removes an image whose retention period has elapsed.
+// Requires the admin role
+func DeleteImage(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	store := imageStore(c)
+	if store == nil {
+		return
+	}
+
+	if err := store.Delete(c.Param("hash"), time.Now().UTC()); err != nil {
+		common.Abort(c, storeErrorStatus(err), err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SetImageHold places (PUT) or releases (DELETE) a legal hold on an image.
+// Requires the admin role
+func SetImageHold(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	store := imageStore(c)
+	if store == nil {
+		return
+	}
+
+	info, err := store.SetLegalHold(c.Param("hash"), c.Request.Method == http.MethodPut)
+	if err != nil {
+		common.Abort(c, storeErrorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger-labs/cc-tools-demo/ccapi/common"
+	"github.com/hyperledger-labs/cc-tools-demo/ccapi/testline"
+)
+
+// MeasureImage reads distance_mm and control_line_ok from a stored cassette
+// photo. The request body is the cassette geometry (testline.Geometry); the
+// image is checked against its hash and, unless IMAGE_LEDGER_VERIFY=false,
+// must be registered and active in sollytch-image (records written before
+// the status field have an empty status until MigrateImageStatus runs)
+func MeasureImage(c *gin.Context) {
+	store := imageStore(c)
+	if store == nil {
+		return
+	}
+
+	var geometry testline.Geometry
+	if err := c.ShouldBindJSON(&geometry); err != nil {
+		common.Abort(c, http.StatusBadRequest, err)
+		return
+	}
+
+	data, info, err := store.Read(c.Param("hash"))
+	if err != nil {
+		common.Abort(c, storeErrorStatus(err), err)
+		return
+	}
+
+	if ledgerVerificationEnabled() {
+		ledger, ok := requireLedgerImage(c, info)
+		if !ok {
+			return
+		}
+		if ledger.Status != "" && ledger.Status != imageActive {
+			common.Abort(c, http.StatusConflict, fmt.Errorf("image %s is %s in sollytch-image", info.Hash, ledger.Status))
+			return
+		}
+	}
+
+	measurement, err := testline.Measure(data, geometry)
+	if err != nil {
+		common.Abort(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hash":        info.Hash,
+		"measurement": measurement,
+	})
+}
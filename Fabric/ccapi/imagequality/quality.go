@@ -0,0 +1,165 @@
+package imagequality
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+)
+
+// ErrUnsupportedFormat is returned for images that are not JPEG or PNG
+var ErrUnsupportedFormat = errors.New("unsupported image format: only JPEG and PNG are analyzed")
+
+// Images are reduced to this longest side before analysis, so scores do not
+// depend on the camera resolution
+const analysisSize = 1024
+
+// Laplacian variance at which BlurScore is 0.5
+const blurReference = 100.0
+
+// Luminance limits (0-255) used by the exposure and glare metrics
+const (
+	shadowLevel    = 5
+	highlightLevel = 250
+	glareLevel     = 240
+	glareMaxChroma = 25
+)
+
+// Metrics describes the quality of a cassette photo
+type Metrics struct {
+	Format string `json:"format"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+
+	// Variance of the Laplacian of the luminance; higher is sharper
+	LaplacianVariance float64 `json:"laplacianVariance"`
+	// Blur in (0,1]: blurReference / (blurReference + LaplacianVariance),
+	// so 1 is a flat image, 0.5 a variance of blurReference and sharp images
+	// approach 0. It is not calibrated against the image_blur_score feature
+	// of sollytch-chain, whose training values (0 to 0.704) come from another
+	// measurement, and must not be used as that feature without recalibration
+	BlurScore float64 `json:"blurScore"`
+
+	// Mean luminance in [0,1]
+	Brightness float64 `json:"brightness"`
+	// Standard deviation of luminance in [0,1]
+	Contrast float64 `json:"contrast"`
+	// Fraction of pixels clipped to black / white
+	ClippedShadows    float64 `json:"clippedShadows"`
+	ClippedHighlights float64 `json:"clippedHighlights"`
+	// Fraction of pixels that are bright and colourless, i.e. specular
+	// reflections on the cassette window
+	GlareRatio float64 `json:"glareRatio"`
+}
+
+// Analyze decodes a JPEG or PNG image and computes its quality metrics
+func Analyze(data []byte) (*Metrics, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() < 3 || bounds.Dy() < 3 {
+		return nil, fmt.Errorf("image too small for analysis: %dx%d", bounds.Dx(), bounds.Dy())
+	}
+
+	m := &Metrics{
+		Format: format,
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+	}
+
+	luma, width, height := m.sample(img)
+	m.LaplacianVariance = laplacianVariance(luma, width, height)
+	m.BlurScore = blurReference / (blurReference + m.LaplacianVariance)
+
+	return m, nil
+}
+
+// sample reduces img to at most analysisSize on its longest side by block
+// averaging, fills the exposure and glare metrics and returns the luminance
+func (m *Metrics) sample(img image.Image) ([]float64, int, int) {
+	bounds := img.Bounds()
+
+	step := (max(m.Width, m.Height) + analysisSize - 1) / analysisSize
+	width := m.Width / step
+	height := m.Height / step
+
+	luma := make([]float64, width*height)
+	var sum, sumSq float64
+	var shadows, highlights, glare int
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var r, g, b float64
+			for dy := 0; dy < step; dy++ {
+				for dx := 0; dx < step; dx++ {
+					pr, pg, pb, _ := img.At(bounds.Min.X+x*step+dx, bounds.Min.Y+y*step+dy).RGBA()
+					r += float64(pr)
+					g += float64(pg)
+					b += float64(pb)
+				}
+			}
+
+			// 16-bit channels averaged over the block, scaled to 0-255
+			scale := float64(step*step) * 257
+			r, g, b = r/scale, g/scale, b/scale
+
+			l := 0.299*r + 0.587*g + 0.114*b
+			luma[y*width+x] = l
+			sum += l
+			sumSq += l * l
+
+			if l <= shadowLevel {
+				shadows++
+			}
+			if l >= highlightLevel {
+				highlights++
+			}
+			if l >= glareLevel && math.Max(r, math.Max(g, b))-math.Min(r, math.Min(g, b)) <= glareMaxChroma {
+				glare++
+			}
+		}
+	}
+
+	n := float64(width * height)
+	mean := sum / n
+	m.Brightness = mean / 255
+	m.Contrast = math.Sqrt(math.Max(sumSq/n-mean*mean, 0)) / 255
+	m.ClippedShadows = float64(shadows) / n
+	m.ClippedHighlights = float64(highlights) / n
+	m.GlareRatio = float64(glare) / n
+
+	return luma, width, height
+}
+
+// laplacianVariance applies the 3x3 Laplacian kernel to the interior pixels
+// and returns the variance of the response
+func laplacianVariance(luma []float64, width, height int) float64 {
+	var sum, sumSq float64
+	n := 0
+
+	for y := 1; y < height-1; y++ {
+		for x := 1; x < width-1; x++ {
+			i := y*width + x
+			v := luma[i-width] + luma[i+width] + luma[i-1] + luma[i+1] - 4*luma[i]
+			sum += v
+			sumSq += v * v
+			n++
+		}
+	}
+
+	if n == 0 {
+		return 0
+	}
+
+	mean := sum / float64(n)
+	return sumSq/float64(n) - mean*mean
+}
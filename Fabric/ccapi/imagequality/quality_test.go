@@ -0,0 +1,157 @@
+package imagequality
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// checkerboard draws squares of size pixels alternating between dark and light
+func checkerboard(width, height, size int, dark, light uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			value := dark
+			if (x/size+y/size)%2 == 0 {
+				value = light
+			}
+			img.SetGray(x, y, color.Gray{Y: value})
+		}
+	}
+	return img
+}
+
+// boxBlur averages each pixel over a (2*radius+1)^2 window, clamped at the borders
+func boxBlur(src *image.Gray, radius int) *image.Gray {
+	bounds := src.Bounds()
+	dst := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			var sum, n int
+			for dy := -radius; dy <= radius; dy++ {
+				for dx := -radius; dx <= radius; dx++ {
+					p := image.Pt(x+dx, y+dy)
+					if p.In(bounds) {
+						sum += int(src.GrayAt(p.X, p.Y).Y)
+						n++
+					}
+				}
+			}
+			dst.SetGray(x, y, color.Gray{Y: uint8(sum / n)})
+		}
+	}
+	return dst
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func analyze(t *testing.T, img image.Image) *Metrics {
+	t.Helper()
+	m, err := Analyze(encodePNG(t, img))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestBlurScore(t *testing.T) {
+	sharpImage := checkerboard(256, 256, 16, 60, 190)
+	sharp := analyze(t, sharpImage)
+	blurred := analyze(t, boxBlur(sharpImage, 6))
+	flat := analyze(t, checkerboard(256, 256, 256, 128, 128))
+
+	if sharp.BlurScore >= DefaultThresholds.MaxBlurScore {
+		t.Errorf("expected sharp image below the blur threshold, got %.3f", sharp.BlurScore)
+	}
+	if blurred.BlurScore <= DefaultThresholds.MaxBlurScore {
+		t.Errorf("expected blurred image above the blur threshold, got %.3f", blurred.BlurScore)
+	}
+	if flat.BlurScore != 1 || flat.LaplacianVariance != 0 {
+		t.Errorf("expected a flat image to score 1, got %.3f (variance %.3f)", flat.BlurScore, flat.LaplacianVariance)
+	}
+
+	if report := DefaultThresholds.Evaluate(sharp); !report.Passed {
+		t.Errorf("expected sharp image to pass, got %v", report.Issues)
+	}
+	if report := DefaultThresholds.Evaluate(blurred); report.Passed {
+		t.Error("expected blurred image to fail")
+	}
+}
+
+func TestBlurScoreIgnoresResolution(t *testing.T) {
+	small := analyze(t, checkerboard(512, 512, 16, 60, 190))
+	// Twice the analysis size: squares shrink back to 16 pixels after sampling
+	large := analyze(t, checkerboard(2048, 2048, 32, 60, 190))
+
+	if large.Width != 2048 || large.Height != 2048 {
+		t.Errorf("expected original dimensions, got %dx%d", large.Width, large.Height)
+	}
+	if diff := large.BlurScore - small.BlurScore; diff > 0.01 || diff < -0.01 {
+		t.Errorf("expected resolution-independent score, got %.4f and %.4f", small.BlurScore, large.BlurScore)
+	}
+}
+
+func TestExposureAndGlare(t *testing.T) {
+	dark := DefaultThresholds.Evaluate(analyze(t, checkerboard(64, 64, 8, 0, 10)))
+	if dark.Passed || dark.Metrics.ClippedShadows < 0.5 {
+		t.Errorf("expected dark image to fail with clipped shadows, got %+v", dark)
+	}
+
+	glare := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			glare.Set(x, y, color.RGBA{R: 180, G: 60, B: 60, A: 255})
+			if x < 16 && y < 16 {
+				glare.Set(x, y, color.RGBA{R: 250, G: 250, B: 250, A: 255})
+			}
+		}
+	}
+	m := analyze(t, glare)
+	if m.GlareRatio != 0.0625 {
+		t.Errorf("expected 1/16 of the image as glare, got %.4f", m.GlareRatio)
+	}
+}
+
+func TestAnalyzeRejectsInvalidImages(t *testing.T) {
+	if _, err := Analyze([]byte("GIF89a")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("expected unsupported format, got %v", err)
+	}
+	if _, err := Analyze(encodePNG(t, checkerboard(2, 2, 1, 0, 255))); err == nil {
+		t.Error("expected a 2x2 image to be too small")
+	}
+}
+
+func TestThresholdsFromEnv(t *testing.T) {
+	t.Setenv("IMAGE_QUALITY_MAX_BLUR", "0.3")
+	thresholds, err := ThresholdsFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thresholds.MaxBlurScore != 0.3 || thresholds.MinBrightness != DefaultThresholds.MinBrightness {
+		t.Errorf("unexpected thresholds: %+v", thresholds)
+	}
+
+	t.Setenv("IMAGE_QUALITY_MAX_BLUR", "-1")
+	if _, err := ThresholdsFromEnv(); err == nil {
+		t.Error("expected a negative threshold to be rejected")
+	}
+
+	t.Setenv("IMAGE_QUALITY_MODE", "")
+	if mode, err := ModeFromEnv(); err != nil || mode != ModeReject {
+		t.Errorf("expected reject by default, got %q, %v", mode, err)
+	}
+	t.Setenv("IMAGE_QUALITY_MODE", "ignore")
+	if _, err := ModeFromEnv(); err == nil {
+		t.Error("expected an unknown mode to be rejected")
+	}
+}
@@ -0,0 +1,123 @@
+package imagequality
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Mode tells the upload handler what to do with images that fail a threshold
+type Mode string
+
+const (
+	ModeReject Mode = "reject"
+	ModeFlag   Mode = "flag"
+	ModeOff    Mode = "off"
+)
+
+// Thresholds are the limits an image must meet to pass the quality check
+type Thresholds struct {
+	MaxBlurScore         float64 `json:"maxBlurScore"`
+	MinBrightness        float64 `json:"minBrightness"`
+	MaxBrightness        float64 `json:"maxBrightness"`
+	MinContrast          float64 `json:"minContrast"`
+	MaxClippedShadows    float64 `json:"maxClippedShadows"`
+	MaxClippedHighlights float64 `json:"maxClippedHighlights"`
+	MaxGlareRatio        float64 `json:"maxGlareRatio"`
+}
+
+// DefaultThresholds are tuned for cassette photos taken by the readers
+var DefaultThresholds = Thresholds{
+	MaxBlurScore:         0.5,
+	MinBrightness:        0.15,
+	MaxBrightness:        0.9,
+	MinContrast:          0.04,
+	MaxClippedShadows:    0.25,
+	MaxClippedHighlights: 0.1,
+	MaxGlareRatio:        0.05,
+}
+
+// Report is the outcome of checking Metrics against Thresholds
+type Report struct {
+	Metrics *Metrics `json:"metrics"`
+	Passed  bool     `json:"passed"`
+	Issues  []string `json:"issues"`
+}
+
+// Evaluate lists every threshold the metrics fail
+func (t Thresholds) Evaluate(m *Metrics) *Report {
+	issues := []string{}
+
+	if m.BlurScore > t.MaxBlurScore {
+		issues = append(issues, fmt.Sprintf("blurry: blurScore %.3f above %.3f", m.BlurScore, t.MaxBlurScore))
+	}
+	if m.Brightness < t.MinBrightness {
+		issues = append(issues, fmt.Sprintf("underexposed: brightness %.3f below %.3f", m.Brightness, t.MinBrightness))
+	}
+	if m.Brightness > t.MaxBrightness {
+		issues = append(issues, fmt.Sprintf("overexposed: brightness %.3f above %.3f", m.Brightness, t.MaxBrightness))
+	}
+	if m.Contrast < t.MinContrast {
+		issues = append(issues, fmt.Sprintf("low contrast: %.3f below %.3f", m.Contrast, t.MinContrast))
+	}
+	if m.ClippedShadows > t.MaxClippedShadows {
+		issues = append(issues, fmt.Sprintf("clipped shadows: %.3f above %.3f", m.ClippedShadows, t.MaxClippedShadows))
+	}
+	if m.ClippedHighlights > t.MaxClippedHighlights {
+		issues = append(issues, fmt.Sprintf("clipped highlights: %.3f above %.3f", m.ClippedHighlights, t.MaxClippedHighlights))
+	}
+	if m.GlareRatio > t.MaxGlareRatio {
+		issues = append(issues, fmt.Sprintf("glare: %.3f above %.3f", m.GlareRatio, t.MaxGlareRatio))
+	}
+
+	return &Report{
+		Metrics: m,
+		Passed:  len(issues) == 0,
+		Issues:  issues,
+	}
+}
+
+// ModeFromEnv reads IMAGE_QUALITY_MODE, defaulting to reject
+func ModeFromEnv() (Mode, error) {
+	switch mode := Mode(os.Getenv("IMAGE_QUALITY_MODE")); mode {
+	case "":
+		return ModeReject, nil
+	case ModeReject, ModeFlag, ModeOff:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid IMAGE_QUALITY_MODE: %q", mode)
+	}
+}
+
+// ThresholdsFromEnv overrides DefaultThresholds with the IMAGE_QUALITY_* variables
+func ThresholdsFromEnv() (Thresholds, error) {
+	t := DefaultThresholds
+
+	overrides := []struct {
+		name  string
+		value *float64
+	}{
+		{"IMAGE_QUALITY_MAX_BLUR", &t.MaxBlurScore},
+		{"IMAGE_QUALITY_MIN_BRIGHTNESS", &t.MinBrightness},
+		{"IMAGE_QUALITY_MAX_BRIGHTNESS", &t.MaxBrightness},
+		{"IMAGE_QUALITY_MIN_CONTRAST", &t.MinContrast},
+		{"IMAGE_QUALITY_MAX_SHADOWS", &t.MaxClippedShadows},
+		{"IMAGE_QUALITY_MAX_HIGHLIGHTS", &t.MaxClippedHighlights},
+		{"IMAGE_QUALITY_MAX_GLARE", &t.MaxGlareRatio},
+	}
+
+	for _, o := range overrides {
+		value := os.Getenv(o.name)
+		if value == "" {
+			continue
+		}
+
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 {
+			return t, fmt.Errorf("invalid %s: %q", o.name, value)
+		}
+		*o.value = parsed
+	}
+
+	return t, nil
+}
@@ -0,0 +1,130 @@
+package imagestore
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetentionPolicy controls how long stored images are kept.
+// MinRetention is the period during which an image cannot be deleted.
+// MaxRetention, when positive, is the age after which Purge removes it
+type RetentionPolicy struct {
+	MinRetention time.Duration
+	MaxRetention time.Duration
+}
+
+// Default retention: evidence is kept for five years and never purged
+const defaultMinRetention = 5 * 365 * 24 * time.Hour
+
+var (
+	defaultStore    *Store
+	defaultStoreErr error
+	defaultOnce     sync.Once
+)
+
+// Default returns the store configured by IMAGE_STORE_PATH,
+// IMAGE_RETENTION_MIN and IMAGE_RETENTION_MAX
+func Default() (*Store, error) {
+	defaultOnce.Do(func() {
+		defaultStore, defaultStoreErr = newFromEnv()
+	})
+
+	return defaultStore, defaultStoreErr
+}
+
+func newFromEnv() (*Store, error) {
+	root := os.Getenv("IMAGE_STORE_PATH")
+	if root == "" {
+		root = "./images"
+	}
+
+	policy := RetentionPolicy{MinRetention: defaultMinRetention}
+
+	var err error
+	if policy.MinRetention, err = durationFromEnv("IMAGE_RETENTION_MIN", policy.MinRetention); err != nil {
+		return nil, err
+	}
+	if policy.MaxRetention, err = durationFromEnv("IMAGE_RETENTION_MAX", 0); err != nil {
+		return nil, err
+	}
+	if policy.MaxRetention > 0 && policy.MaxRetention < policy.MinRetention {
+		return nil, fmt.Errorf("IMAGE_RETENTION_MAX must not be shorter than IMAGE_RETENTION_MIN")
+	}
+
+	return New(root, policy)
+}
+
+func durationFromEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", name, value)
+	}
+
+	return d, nil
+}
+
+// Purge removes images past their expiry that are not under legal hold
+// and returns their hashes
+func (s *Store) Purge(now time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos, err := filepath.Glob(filepath.Join(s.root, "blobs", "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	purged := []string{}
+	for _, path := range infos {
+		hash := strings.TrimSuffix(filepath.Base(path), ".json")
+		if ValidateHash(hash) != nil {
+			continue
+		}
+
+		info, err := s.readInfo(hash)
+		if err != nil {
+			return purged, err
+		}
+		if info.LegalHold || info.ExpiresAt == nil || now.Before(*info.ExpiresAt) {
+			continue
+		}
+
+		if err := s.remove(hash); err != nil {
+			return purged, err
+		}
+		purged = append(purged, hash)
+	}
+
+	return purged, nil
+}
+
+// RunPurge calls Purge every interval until done is closed
+func (s *Store) RunPurge(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			purged, err := s.Purge(now.UTC())
+			if err != nil {
+				log.Println("Image purge failed: ", err)
+			}
+			if len(purged) > 0 {
+				log.Printf("Purged %d expired images", len(purged))
+			}
+		}
+	}
+}
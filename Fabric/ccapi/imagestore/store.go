@@ -0,0 +1,302 @@
+package imagestore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidHash = errors.New("hash must be 64 lowercase hex characters (sha256)")
+	ErrNotFound    = errors.New("image not found")
+	ErrCorrupted   = errors.New("stored image does not match its hash")
+	ErrRetained    = errors.New("image is under retention")
+)
+
+var hashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// BlobInfo is the sidecar record kept next to each stored image
+type BlobInfo struct {
+	Hash        string     `json:"hash"`
+	Size        int64      `json:"size"`
+	MimeType    string     `json:"mimeType"`
+	StoredAt    time.Time  `json:"storedAt"`
+	RetainUntil time.Time  `json:"retainUntil"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	LegalHold   bool       `json:"legalHold"`
+}
+
+// Store keeps image blobs on local disk addressed by their SHA-256.
+// Blobs live in <root>/blobs/<first two hex chars>/<hash>, with a
+// <hash>.json sidecar holding the BlobInfo. Identical content is stored once
+type Store struct {
+	root   string
+	policy RetentionPolicy
+	mu     sync.Mutex
+}
+
+// New creates the store directories under root
+func New(root string, policy RetentionPolicy) (*Store, error) {
+	for _, dir := range []string{"blobs", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create image store: %w", err)
+		}
+	}
+
+	return &Store{root: root, policy: policy}, nil
+}
+
+// ValidateHash checks that hash is a lowercase hex SHA-256
+func ValidateHash(hash string) error {
+	if !hashPattern.MatchString(hash) {
+		return ErrInvalidHash
+	}
+	return nil
+}
+
+func (s *Store) blobPath(hash string) string {
+	return filepath.Join(s.root, "blobs", hash[:2], hash)
+}
+
+func (s *Store) infoPath(hash string) string {
+	return s.blobPath(hash) + ".json"
+}
+
+// Put streams r to disk while hashing it. If the content is already stored
+// the upload is discarded and the existing record is returned with created=false.
+// A stored copy that is missing or no longer matches its hash is replaced by
+// the upload, keeping the existing retention record
+func (s *Store) Put(r io.Reader, mimeType string) (info *BlobInfo, created bool, err error) {
+	tmp, err := os.CreateTemp(filepath.Join(s.root, "tmp"), "upload-*")
+	if err != nil {
+		return nil, false, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to write image: %w", err)
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Deduplicate: keep the first copy and its retention record
+	existing, err := s.readInfo(hash)
+	if err == nil {
+		if err := s.checkBlob(hash, existing.Size); err == nil {
+			return existing, false, nil
+		} else if !errors.Is(err, ErrCorrupted) {
+			return nil, false, err
+		}
+		if err := os.Rename(tmp.Name(), s.blobPath(hash)); err != nil {
+			return nil, false, err
+		}
+		if existing.Size != size {
+			existing.Size = size
+			if err := s.writeInfo(existing); err != nil {
+				return nil, false, err
+			}
+		}
+		return existing, false, nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, false, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.blobPath(hash)), 0o750); err != nil {
+		return nil, false, err
+	}
+	if err := os.Rename(tmp.Name(), s.blobPath(hash)); err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now().UTC()
+	info = &BlobInfo{
+		Hash:        hash,
+		Size:        size,
+		MimeType:    mimeType,
+		StoredAt:    now,
+		RetainUntil: now.Add(s.policy.MinRetention),
+	}
+	if s.policy.MaxRetention > 0 {
+		expiresAt := now.Add(s.policy.MaxRetention)
+		info.ExpiresAt = &expiresAt
+	}
+
+	if err := s.writeInfo(info); err != nil {
+		return nil, false, err
+	}
+
+	return info, true, nil
+}
+
+// Info returns the sidecar record of a stored image
+func (s *Store) Info(hash string) (*BlobInfo, error) {
+	if err := ValidateHash(hash); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.readInfo(hash)
+}
+
+// Read loads an image and checks that its content still hashes to hash.
+// A mismatch returns ErrCorrupted and no data
+func (s *Store) Read(hash string) ([]byte, *BlobInfo, error) {
+	info, err := s.Info(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := os.ReadFile(s.blobPath(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, ErrCorrupted
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != hash || int64(len(data)) != info.Size {
+		return nil, nil, ErrCorrupted
+	}
+
+	return data, info, nil
+}
+
+// Delete removes an image whose minimum retention has elapsed and which is
+// not under legal hold
+func (s *Store) Delete(hash string, now time.Time) error {
+	if err := ValidateHash(hash); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := s.readInfo(hash)
+	if err != nil {
+		return err
+	}
+	if info.LegalHold {
+		return fmt.Errorf("%w: legal hold", ErrRetained)
+	}
+	if now.Before(info.RetainUntil) {
+		return fmt.Errorf("%w until %s", ErrRetained, info.RetainUntil.Format(time.RFC3339))
+	}
+
+	return s.remove(hash)
+}
+
+// SetLegalHold places or releases a legal hold, which blocks deletion and purge
+func (s *Store) SetLegalHold(hash string, hold bool) (*BlobInfo, error) {
+	if err := ValidateHash(hash); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := s.readInfo(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	info.LegalHold = hold
+	if err := s.writeInfo(info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// checkBlob returns ErrCorrupted unless the blob exists, has size bytes and hashes to hash
+func (s *Store) checkBlob(hash string, size int64) error {
+	f, err := os.Open(s.blobPath(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrCorrupted
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	n, err := io.Copy(hasher, f)
+	if err != nil {
+		return err
+	}
+	if n != size || hex.EncodeToString(hasher.Sum(nil)) != hash {
+		return ErrCorrupted
+	}
+
+	return nil
+}
+
+func (s *Store) remove(hash string) error {
+	if err := os.Remove(s.blobPath(hash)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.Remove(s.infoPath(hash))
+}
+
+func (s *Store) readInfo(hash string) (*BlobInfo, error) {
+	data, err := os.ReadFile(s.infoPath(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var info BlobInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("invalid record for image %s: %w", hash, err)
+	}
+
+	return &info, nil
+}
+
+// writeInfo replaces the sidecar atomically so a crash never leaves it truncated
+func (s *Store) writeInfo(info *BlobInfo) error {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(s.root, "tmp"), "info-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.infoPath(info.Hash))
+}
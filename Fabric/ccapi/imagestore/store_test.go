@@ -0,0 +1,179 @@
+package imagestore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T, policy RetentionPolicy) *Store {
+	t.Helper()
+	store, err := New(t.TempDir(), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func mustPut(t *testing.T, store *Store, data []byte) *BlobInfo {
+	t.Helper()
+	info, _, err := store.Put(bytes.NewReader(data), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestPutRead(t *testing.T) {
+	store := newTestStore(t, RetentionPolicy{MinRetention: time.Hour, MaxRetention: 2 * time.Hour})
+	data := []byte("cassete")
+
+	info, created, err := store.Put(bytes.NewReader(data), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Error("expected first upload to be created")
+	}
+	if info.Hash != sha256Hex(data) || info.Size != int64(len(data)) || info.MimeType != "image/png" {
+		t.Errorf("unexpected info: %+v", info)
+	}
+	if info.RetainUntil != info.StoredAt.Add(time.Hour) || info.ExpiresAt == nil || !info.ExpiresAt.Equal(info.StoredAt.Add(2*time.Hour)) {
+		t.Errorf("unexpected retention: %+v", info)
+	}
+
+	read, readInfo, err := store.Read(info.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read, data) || readInfo.Hash != info.Hash {
+		t.Errorf("expected stored content back, got %q", read)
+	}
+
+	tests := []struct {
+		name string
+		hash string
+		err  error
+	}{
+		{"unknown hash", sha256Hex([]byte("outra")), ErrNotFound},
+		{"sha512 hash", hex.EncodeToString(make([]byte, 64)), ErrInvalidHash},
+		{"uppercase hash", "AB" + info.Hash[2:], ErrInvalidHash},
+		{"path traversal", "../" + info.Hash[3:], ErrInvalidHash},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, err := store.Read(test.hash); !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestPutDeduplicates(t *testing.T) {
+	store := newTestStore(t, RetentionPolicy{MinRetention: time.Hour})
+	data := []byte("cassete")
+	first := mustPut(t, store, data)
+
+	info, created, err := store.Put(bytes.NewReader(data), "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created {
+		t.Error("expected identical content not to be created again")
+	}
+	if info.MimeType != "image/png" || !info.StoredAt.Equal(first.StoredAt) {
+		t.Errorf("expected the first record to be kept, got %+v", info)
+	}
+}
+
+func TestPutRepairsStoredCopy(t *testing.T) {
+	tests := []struct {
+		name   string
+		damage func(path string) error
+	}{
+		{"missing blob", os.Remove},
+		{"corrupt blob", func(path string) error { return os.WriteFile(path, []byte("cassetf"), 0o640) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newTestStore(t, RetentionPolicy{MinRetention: time.Hour})
+			data := []byte("cassete")
+			info := mustPut(t, store, data)
+
+			if err := test.damage(store.blobPath(info.Hash)); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := store.Read(info.Hash); !errors.Is(err, ErrCorrupted) {
+				t.Fatalf("expected damaged copy to be reported, got %v", err)
+			}
+
+			repaired, created, err := store.Put(bytes.NewReader(data), "image/png")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if created || !repaired.StoredAt.Equal(info.StoredAt) {
+				t.Errorf("expected existing record to be kept, got created=%v %+v", created, repaired)
+			}
+			if read, _, err := store.Read(info.Hash); err != nil || !bytes.Equal(read, data) {
+				t.Errorf("expected copy to be repaired, got %q, %v", read, err)
+			}
+		})
+	}
+}
+
+func TestDeleteRetention(t *testing.T) {
+	store := newTestStore(t, RetentionPolicy{MinRetention: time.Hour})
+	info := mustPut(t, store, []byte("cassete"))
+	afterRetention := info.RetainUntil.Add(time.Second)
+
+	if err := store.Delete(info.Hash, info.StoredAt); !errors.Is(err, ErrRetained) {
+		t.Fatalf("expected deletion within retention to fail, got %v", err)
+	}
+
+	if _, err := store.SetLegalHold(info.Hash, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(info.Hash, afterRetention); !errors.Is(err, ErrRetained) {
+		t.Fatalf("expected legal hold to block deletion, got %v", err)
+	}
+
+	if _, err := store.SetLegalHold(info.Hash, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(info.Hash, afterRetention); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Info(info.Hash); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected deleted image to be gone, got %v", err)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	store := newTestStore(t, RetentionPolicy{MinRetention: time.Hour, MaxRetention: 2 * time.Hour})
+	expired := mustPut(t, store, []byte("expirada"))
+	held := mustPut(t, store, []byte("retida"))
+
+	if _, err := store.SetLegalHold(held.Hash, true); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := store.Purge(expired.ExpiresAt.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 1 || purged[0] != expired.Hash {
+		t.Errorf("expected only the expired image to be purged, got %v", purged)
+	}
+	if _, err := store.Info(held.Hash); err != nil {
+		t.Errorf("expected held image to be kept, got %v", err)
+	}
+}
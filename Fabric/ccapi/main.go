@@ -5,10 +5,12 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/hyperledger-labs/cc-tools-demo/ccapi/chaincode"
+	"github.com/hyperledger-labs/cc-tools-demo/ccapi/imagestore"
 	"github.com/hyperledger-labs/cc-tools-demo/ccapi/server"
 	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
 )
@@ -36,6 +38,18 @@ func main() {
 
 	chaincode.RegisterForEvents()
 
+	// Purge expired images from the local image store
+	store, err := imagestore.Default()
+	if err != nil {
+		log.Println("Image store disabled: ", err)
+	} else {
+		interval, err := time.ParseDuration(os.Getenv("IMAGE_PURGE_INTERVAL"))
+		if err != nil || interval <= 0 {
+			interval = 24 * time.Hour
+		}
+		go store.RunPurge(interval, ctx.Done())
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 
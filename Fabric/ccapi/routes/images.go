@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger-labs/cc-tools-demo/ccapi/auth"
+	"github.com/hyperledger-labs/cc-tools-demo/ccapi/handlers"
+)
+
+func addImageRoutes(rg *gin.RouterGroup) {
+	// Every image route acts on the ledger as the identity of its API token
+	rg.Use(auth.Authenticate)
+
+	// Content-addressed image store
+	rg.POST("", handlers.StoreImage)
+	rg.POST("/quality", handlers.AssessImage)
+	rg.GET("/:hash", handlers.GetImage)
+	rg.DELETE("/:hash", handlers.DeleteImage)
+	rg.GET("/:hash/verify", handlers.VerifyImage)
+	rg.POST("/:hash/measure", handlers.MeasureImage)
+	rg.PUT("/:hash/hold", handlers.SetImageHold)
+	rg.DELETE("/:hash/hold", handlers.SetImageHold)
+}
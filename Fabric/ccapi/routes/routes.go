@@ -30,6 +30,10 @@ func AddRoutesToEngine(r *gin.Engine) {
 	chaincodeRG := r.Group("/api")
 	addCCRoutes(chaincodeRG)
 
+	// Image store routes
+	imageRG := r.Group("/api/images")
+	addImageRoutes(imageRG)
+
 	// Update SDK route
 	sdkRG := r.Group("/sdk")
 	addSDKRoutes(sdkRG)
@@ -0,0 +1,93 @@
+package testline
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Geometry describes where the strip is in the photo and how to read it.
+// Positions along the strip are measured in mm from the origin, the edge
+// of the window on the sample pad side
+type Geometry struct {
+	// Reading window over the strip, in image pixels
+	WindowX      int `json:"windowX"`
+	WindowY      int `json:"windowY"`
+	WindowWidth  int `json:"windowWidth"`
+	WindowHeight int `json:"windowHeight"`
+
+	// Flow direction: "y" (top to bottom) or "x" (left to right).
+	// Reversed means the sample pad is at the bottom / right of the window
+	FlowAxis string `json:"flowAxis"`
+	Reversed bool   `json:"reversed"`
+
+	// Image scale
+	PixelsPerMM float64 `json:"pixelsPerMM"`
+
+	// Expected control line position and how far from it to search
+	ControlLineMM      float64 `json:"controlLineMM"`
+	ControlToleranceMM float64 `json:"controlToleranceMM"`
+
+	// Range along the strip searched for the test line
+	TestMinMM float64 `json:"testMinMM"`
+	TestMaxMM float64 `json:"testMaxMM"`
+
+	// Expected band width, used for smoothing and background removal
+	LineWidthMM float64 `json:"lineWidthMM"`
+
+	// Minimum peak signal-to-noise ratio for a line to count as present.
+	// Zero uses DefaultMinSNR
+	MinSNR float64 `json:"minSNR"`
+}
+
+// DefaultMinSNR is the detection threshold used when Geometry.MinSNR is zero
+const DefaultMinSNR = 4.0
+
+// Validate checks the geometry against an image of the given size
+func (g *Geometry) Validate(imageWidth, imageHeight int) error {
+	if g.FlowAxis != "x" && g.FlowAxis != "y" {
+		return errors.New(`flowAxis must be "x" or "y"`)
+	}
+	if g.WindowWidth <= 0 || g.WindowHeight <= 0 || g.WindowX < 0 || g.WindowY < 0 {
+		return errors.New("window must have a non-negative origin and positive size")
+	}
+	if g.WindowX+g.WindowWidth > imageWidth || g.WindowY+g.WindowHeight > imageHeight {
+		return fmt.Errorf("window exceeds the %dx%d image", imageWidth, imageHeight)
+	}
+	if g.PixelsPerMM <= 0 {
+		return errors.New("pixelsPerMM must be positive")
+	}
+	if g.LineWidthMM <= 0 {
+		return errors.New("lineWidthMM must be positive")
+	}
+	if g.ControlToleranceMM <= 0 {
+		return errors.New("controlToleranceMM must be positive")
+	}
+	if g.TestMinMM < 0 || g.TestMinMM >= g.TestMaxMM {
+		return errors.New("testMinMM must be non-negative and below testMaxMM")
+	}
+	if g.MinSNR < 0 {
+		return errors.New("minSNR must not be negative")
+	}
+
+	length := float64(g.stripLengthPx()) / g.PixelsPerMM
+	if g.ControlLineMM <= 0 || g.ControlLineMM >= length || g.TestMaxMM > length {
+		return fmt.Errorf("line positions must lie within the %.2f mm window", length)
+	}
+
+	return nil
+}
+
+// stripLengthPx is the window size along the flow axis
+func (g *Geometry) stripLengthPx() int {
+	if g.FlowAxis == "x" {
+		return g.WindowWidth
+	}
+	return g.WindowHeight
+}
+
+func (g *Geometry) minSNR() float64 {
+	if g.MinSNR == 0 {
+		return DefaultMinSNR
+	}
+	return g.MinSNR
+}
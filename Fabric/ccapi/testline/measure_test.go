@@ -0,0 +1,198 @@
+package testline
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// Synthetic band: centre position from the origin and depth in inverted green levels
+type band struct {
+	mm    float64
+	depth float64
+}
+
+// Strip 25 mm long at 10 pixels per mm. Bands are 11 pixels wide, so the
+// smoothing window covers exactly one band and the peak equals its depth
+func testGeometry(flowAxis string, reversed bool) Geometry {
+	g := Geometry{
+		WindowX:            20,
+		WindowY:            30,
+		WindowWidth:        60,
+		WindowHeight:       250,
+		FlowAxis:           flowAxis,
+		Reversed:           reversed,
+		PixelsPerMM:        10,
+		ControlLineMM:      5,
+		ControlToleranceMM: 1.5,
+		TestMinMM:          8,
+		TestMaxMM:          20,
+		LineWidthMM:        1,
+	}
+	if flowAxis == "x" {
+		g.WindowWidth, g.WindowHeight = g.WindowHeight, g.WindowWidth
+	}
+	return g
+}
+
+// cassette draws the window of g over a 300x300 image with the given bands
+// and a little deterministic noise on the green channel
+func cassette(t *testing.T, g Geometry, bands ...band) []byte {
+	t.Helper()
+	noise := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, 300, 300))
+	length := g.stripLengthPx()
+
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 300; x++ {
+			green := 220.0
+
+			inside := x >= g.WindowX && x < g.WindowX+g.WindowWidth && y >= g.WindowY && y < g.WindowY+g.WindowHeight
+			along := y - g.WindowY
+			if g.FlowAxis == "x" {
+				along = x - g.WindowX
+			}
+			if inside {
+				if g.Reversed {
+					along = length - 1 - along
+				}
+				for _, b := range bands {
+					if math.Abs(float64(along)-b.mm*g.PixelsPerMM) <= 5 {
+						green -= b.depth
+					}
+				}
+				green += float64(noise.Intn(5) - 2)
+			}
+
+			img.Set(x, y, color.RGBA{R: 200, G: uint8(green), B: 190, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func assertLine(t *testing.T, name string, line *Line, want band) {
+	t.Helper()
+	if !line.Present {
+		t.Fatalf("expected %s line to be present, got %+v", name, line)
+	}
+	if math.Abs(line.PositionMM-want.mm) > 0.1 {
+		t.Errorf("expected %s line at %.2f mm, got %.2f", name, want.mm, line.PositionMM)
+	}
+	if math.Abs(line.Intensity-want.depth) > 3 {
+		t.Errorf("expected %s line intensity %.0f, got %.2f", name, want.depth, line.Intensity)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	control, test := band{mm: 5.3, depth: 120}, band{mm: 12.5, depth: 45}
+
+	tests := []struct {
+		name     string
+		flowAxis string
+		reversed bool
+	}{
+		{"vertical", "y", false},
+		{"vertical reversed", "y", true},
+		{"horizontal", "x", false},
+		{"horizontal reversed", "x", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := testGeometry(tc.flowAxis, tc.reversed)
+
+			m, err := Measure(cassette(t, g, control, test), g)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertLine(t, "control", m.ControlLine, control)
+			assertLine(t, "test", m.TestLine, test)
+			if !m.ControlLineOK || m.DistanceMM == nil || *m.DistanceMM != m.TestLine.PositionMM {
+				t.Errorf("unexpected measurement: %+v", m)
+			}
+			if m.Confidence < 0.95 {
+				t.Errorf("expected high confidence for clear lines, got %.3f", m.Confidence)
+			}
+		})
+	}
+}
+
+func TestMeasureWithoutLines(t *testing.T) {
+	g := testGeometry("y", false)
+	control := band{mm: 5, depth: 90}
+
+	// Negative reading: only the control line
+	m, err := Measure(cassette(t, g, control), g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLine(t, "control", m.ControlLine, control)
+	if m.TestLine.Present || m.DistanceMM != nil {
+		t.Errorf("expected no test line, got %+v", m.TestLine)
+	}
+	if m.Confidence < 0.9 {
+		t.Errorf("expected a flat test range to be a confident negative, got %.3f", m.Confidence)
+	}
+
+	// Invalid test: no control line, even with a test line
+	m, err = Measure(cassette(t, g, band{mm: 12, depth: 60}), g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ControlLineOK || m.Confidence != 0 {
+		t.Errorf("expected missing control line with zero confidence, got %+v", m)
+	}
+	if !m.TestLine.Present || m.DistanceMM == nil {
+		t.Errorf("expected test line to be reported, got %+v", m.TestLine)
+	}
+
+	// Faint band below the detection threshold (DefaultMinSNR over the
+	// noise floor of the averaged profile)
+	m, err = Measure(cassette(t, g, control, band{mm: 12, depth: 1}), g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.TestLine.Present || m.DistanceMM != nil {
+		t.Errorf("expected faint band not to count as a line, got %+v", m.TestLine)
+	}
+}
+
+func TestMeasureRejectsInvalidInput(t *testing.T) {
+	valid := testGeometry("y", false)
+	photo := cassette(t, valid)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		modify func(g *Geometry)
+		err    string
+	}{
+		{"not an image", []byte("GIF89a"), nil, "unsupported"},
+		{"flow axis", photo, func(g *Geometry) { g.FlowAxis = "z" }, "flowAxis"},
+		{"window outside image", photo, func(g *Geometry) { g.WindowX = 280 }, "exceeds"},
+		{"scale", photo, func(g *Geometry) { g.PixelsPerMM = 0 }, "pixelsPerMM"},
+		{"test range", photo, func(g *Geometry) { g.TestMinMM = 20 }, "testMinMM"},
+		{"control outside strip", photo, func(g *Geometry) { g.ControlLineMM = 30 }, "within"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := valid
+			if tc.modify != nil {
+				tc.modify(&g)
+			}
+			if _, err := Measure(tc.data, g); err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -6,18 +6,13 @@ const fs = require('node:fs');
 const fsPromises = require('node:fs/promises');
 const path = require('node:path');
 const { spawn } = require('node:child_process');
+const { signTestPayload } = require('./resources/testSignature.js');
 
 // Configurações da rede Fabric
 const channelName = 'mainchannel';
 const chaincodeName = 'sollytch-chain';
 const mspId = 'org1MSP';
 
-const controleInternoEncoder = {
-    'ok': 2,
-    'fail': 1,
-    'invalid': 0
-};
-
 const cryptoPath = path.resolve(__dirname,'..','fabric','organizations','peerOrganizations','org1.example.com');
 
 const keyDirectoryPath = path.resolve(
@@ -207,20 +202,8 @@ function createTestDataWithNewId(originalJson, newId) {
 
 // Função para executar StoreTest (medindo apenas submitTransaction)
 async function runStoreTest(testData, testId, iteration) {
-    const parsedData = JSON.parse(testData);
-    
-    // Preparar predictStr (mas não medir este tempo)
-    const predictStr = [
-        parsedData.lat, parsedData.lon, parsedData.expiry_days_left,
-        parsedData.distance_mm, parsedData.time_to_migrate_s, parsedData.sample_volume_uL,
-        parsedData.sample_pH, parsedData.sample_turbidity_NTU, parsedData.sample_temp_C,
-        parsedData.ambient_T_C, parsedData.ambient_RH_pct, parsedData.lighting_lux,
-        parsedData.tilt_deg, parsedData.preincubation_time_s, parsedData.time_since_sampling_min,
-        parsedData.image_blur_score || 0, parsedData.tempo_transporte_horas,
-        parsedData.estimated_concentration_ppb, parsedData.incerteza_estimativa_ppb,
-        parsedData.control_line_ok ? 1 : 0,
-        controleInternoEncoder[parsedData.controle_interno_result] || 0
-    ].join(',');
+    // Assinar o JSON do teste (mas não medir este tempo)
+    const { signature, keyID } = await signTestPayload(testData);
     
     // MEDIR APENAS O SUBMIT TRANSACTION
     const startTime = Date.now();
@@ -230,7 +213,8 @@ async function runStoreTest(testData, testId, iteration) {
             "StoreTest",
             testId,
             testData,
-            predictStr
+            signature,
+            keyID
         );
         
         const endTime = Date.now();
@@ -12,6 +12,7 @@ const fsRead = require('fs');
 const fs = require('node:fs/promises');
 const path = require('node:path');
 const { TextDecoder } = require('node:util');
+const { signTestPayload } = require('./resources/testSignature.js');
 
 const channelName = 'mainchannel';
 const chaincodeName = 'sollytch-chain';
@@ -48,12 +49,6 @@ const peerHostAlias = 'peer0.org1.example.com';
 
 const utf8Decoder = new TextDecoder();
 
-const controleInternoEncoder = {
-    'ok': 2,
-    'fail': 1,
-    'invalid': 0
-};
-
 function setNestedField(obj, path, value) {
     const keys = path.split('.');
     let current = obj;
@@ -69,77 +64,6 @@ function setNestedField(obj, path, value) {
 }
 
 
-function preprocessForPrediction(testData) {
-    console.log("Processando dados para predição...");
-    
-    // 1. IDENTIFICAR FEATURES (igual ao Python)
-    const numericFeatures = [
-        'expiry_days_left', 'distance_mm', 'time_to_migrate_s', 'sample_volume_uL',
-        'sample_pH', 'sample_turbidity_NTU', 'sample_temp_C', 'ambient_T_C',
-        'ambient_RH_pct', 'lighting_lux', 'tilt_deg', 'preincubation_time_s',
-        'time_since_sampling_min', 'tempo_transporte_horas', 'estimated_concentration_ppb',
-        'incerteza_estimativa_ppb'
-    ];
-    
-    const categoricalFeatures = ['control_line_ok', 'controle_interno_result'];
-    const allFeatures = [...numericFeatures, ...categoricalFeatures];
-    
-    const processedData = {...testData};
-    
-    if (typeof processedData.control_line_ok === 'boolean') {
-        processedData.control_line_ok = processedData.control_line_ok ? 1 : 0;
-        console.log(`Booleano convertido: control_line_ok → ${processedData.control_line_ok}`);
-    }
-    
-    if (processedData.controle_interno_result in controleInternoEncoder) {
-        processedData.controle_interno_result = controleInternoEncoder[processedData.controle_interno_result];
-        console.log(`Codificada 'controle_interno_result': ${testData.controle_interno_result} → ${processedData.controle_interno_result}`);
-    } else {
-        processedData.controle_interno_result = 0; // valor padrão
-        console.log(`Valor desconhecido 'controle_interno_result': ${testData.controle_interno_result} → 0`);
-    }
-    
-    allFeatures.forEach(feature => {
-        if (processedData[feature] === null || processedData[feature] === undefined) {
-            processedData[feature] = 0;
-            console.log(`Preenchido valor nulo: ${feature} → 0`);
-        }
-    });
-    
-    if (processedData.image_blur_score === null || processedData.image_blur_score === undefined) {
-        processedData.image_blur_score = 0.0;
-    }
-    
-    const csvData = [
-        processedData.lat,
-        processedData.lon,
-        processedData.expiry_days_left,
-        processedData.distance_mm,
-        processedData.time_to_migrate_s,
-        processedData.sample_volume_uL,
-        processedData.sample_pH,
-        processedData.sample_turbidity_NTU,
-        processedData.sample_temp_C,
-        processedData.ambient_T_C,
-        processedData.ambient_RH_pct,
-        processedData.lighting_lux,
-        processedData.tilt_deg,
-        processedData.preincubation_time_s,
-        processedData.time_since_sampling_min,
-        processedData.image_blur_score,
-        processedData.tempo_transporte_horas,
-        processedData.estimated_concentration_ppb,
-        processedData.incerteza_estimativa_ppb,
-        processedData.control_line_ok,
-        processedData.controle_interno_result
-    ].join(',');
-    
-    console.log("Dados pré-processados com sucesso");
-    console.log(`CSV gerado: ${csvData}`);
-    
-    return csvData;
-}
-
 async function newGrpcConnection() {
     const tlsRootCert = await fs.readFile(tlsCertPath);
     const tlsCredentials = grpc.credentials.createSsl(tlsRootCert);
@@ -176,14 +100,12 @@ async function invoke(contract) {
     const testID = testData.test_id;
     console.log(testID)
     
-    // Pré-processar os dados
-    const predictStr = preprocessForPrediction(testData);
-    
-    // String JSON original
+    // String JSON original, assinada pelo operador ou leitor
     const jsonStr = JSON.stringify(testData);
 
     try {
-        await contract.submitTransaction("StoreTest", testID, jsonStr, predictStr);
+        const { signature, keyID } = await signTestPayload(jsonStr);
+        await contract.submitTransaction("StoreTest", testID, jsonStr, signature, keyID);
         console.log("Teste armazenado com sucesso");
     } catch (error) {
         console.error("Erro:", error);
@@ -228,12 +150,18 @@ async function getTestByLote(contract,lote) {
     }
 }
 
-function hashImage(path) {
+function hashFile(path, algorithm) {
   const fileBuffer = fsRead.readFileSync(path);
-  const hash = crypto.createHash("sha512").update(fileBuffer).digest("hex");
+  const hash = crypto.createHash(algorithm).update(fileBuffer).digest("hex");
   return hash;
 }
 
+// Imagens usam SHA-256, o mesmo hash que endereça o repositório de imagens
+// da ccapi (/api/images); planilhas continuam com SHA-512
+function hashImage(path) {
+  return hashFile(path, "sha256");
+}
+
 async function getImageByID(contract,imageID) {
     try {
         const rawResult = await contract.evaluateTransaction("GetImageByID", imageID);
@@ -272,13 +200,34 @@ async function getImagesByKit(contract,kitID) {
     }
 }
 
-async function storeImage(contract,imagePath) {
+// O chaincode só aceita imagens de kits registrados; registra o kit para a
+// organização do cliente se ainda não tiver dono
+async function ensureKitRegistered(contract, kitID) {
+    try {
+        await contract.evaluateTransaction("GetKitOwner", kitID);
+    } catch (err) {
+        await contract.submitTransaction("RegisterKit", kitID);
+        console.log(`Kit ${kitID} registrado`);
+    }
+}
+
+async function storeImage(contract,imagePath,captureMetadata) {
     const kitID = 'teste2'
     const imageHash = hashImage(imagePath)
+
+    // Metadados da captura; tamanho e algoritmo de hash vêm do próprio arquivo
+    const metadata = {
+        ...captureMetadata,
+        sizeBytes: fsRead.statSync(imagePath).size,
+        hashAlgorithm: 'sha256'
+    };
+
+    await ensureKitRegistered(contract, kitID);
     await contract.submitTransaction(
         "StoreImage",
         kitID,
-        imageHash
+        imageHash,
+        JSON.stringify(metadata)
     );
 
     console.log("Imagem armazenada com sucesso!");
@@ -286,7 +235,7 @@ async function storeImage(contract,imagePath) {
 
 async function storePlanilha(contract, planilhaPath) {
     const lote = 'C23017'
-    const planilhaHash = hashImage(planilhaPath)
+    const planilhaHash = hashFile(planilhaPath, "sha512")
     await contract.submitTransaction(
         "StorePlanilha",
         lote,
@@ -389,10 +338,15 @@ async function models(contract,key,path) {
     // Converte para base64
     const modelBase64 = modelBuffer.toString('base64');
 
+    // Estatísticas de treino geradas por treino_ml/estatisticas.go,
+    // usadas em GetDriftReport e na verificação de fora de distribuição
+    const trainingStats = await fs.readFile(`${path}.stats.json`, 'utf8');
+
     await contract.submitTransaction(
         'StoreModel',
         key,
-        modelBase64
+        modelBase64,
+        trainingStats
     );
 
     console.log(`modelo "${key}" armazenado com sucesso no ledger`);
@@ -407,16 +361,24 @@ async function storeModel(contract) {
         'caminho do arquivo do modelo (.model): '
     )).trim();
 
+    const statsPath = (await askQuestion(
+        `caminho das estatísticas de treino (${filePath}.stats.json): `
+    )).trim() || `${filePath}.stats.json`;
+
     // Lê o arquivo como binário
     const modelBuffer = await fs.readFile(filePath);
 
     // Converte para base64
     const modelBase64 = modelBuffer.toString('base64');
 
+    // Estatísticas de treino geradas por treino_ml/estatisticas.go
+    const trainingStats = await fs.readFile(statsPath, 'utf8');
+
     await contract.submitTransaction(
         'StoreModel',
         modelKey,
-        modelBase64
+        modelBase64,
+        trainingStats
     );
 
     console.log(`modelo "${modelKey}" armazenado com sucesso no ledger`);
@@ -456,7 +418,10 @@ async function main() {
     try {
         const network = gateway.getNetwork(channelName);
         const sollytchImageContract = network.getContract("sollytch-image");
-        const sollytchChainContract = network.getContract("sollytch-chain");
+        // sollytch-chain expõe os contratos tests, planilhas, models e registry
+        const testsContract = network.getContract("sollytch-chain", "tests");
+        const planilhasContract = network.getContract("sollytch-chain", "planilhas");
+        const modelsContract = network.getContract("sollytch-chain", "models");
 
         // const action = (await askQuestion(
         //     'acao (store_test | query_test | edit_test | storemodel | store_image | query_image | store_planilha | query_planilha): '
@@ -464,35 +429,44 @@ async function main() {
         // const action = "store_test"
         const action = "models"
         if (action === 'store_test') {
-            await invoke(sollytchChainContract);
+            await invoke(testsContract);
 
         } else if (action === 'query_test') {
             const whichQuery = (await askQuestion("Buscar pelo lote ou por TEST-ID? (lote | id) ")).trim();
             if (whichQuery === "lote"){
                 const lote = await askQuestion('Insira o numero do lote: ')
-                await getTestByLote(sollytchChainContract, lote)
+                await getTestByLote(testsContract, lote)
             } else if (whichQuery === "id"){
                 const testID = await askQuestion('Insira o id do teste (ex TEST-00001): ')
-                await getTestByID(sollytchChainContract,testID)
+                await getTestByID(testsContract,testID)
             }else{
                 console.log("opcao invalida")
             }
 
         } else if (action === 'storemodel') {
-            await storeModel(sollytchChainContract);
+            await storeModel(modelsContract);
 
         } else if (action === 'edit_test') {
-            await editTest(sollytchChainContract);
+            await editTest(testsContract);
 
         } else if (action === 'models') {
-            await models(sollytchChainContract, 'qc_status', 'examples/qc_status');
-            await models(sollytchChainContract, 'result_class', 'examples/result_class');
-            await models(sollytchChainContract, 'acao_recomendada', 'examples/acao_recomendada');
+            await models(modelsContract, 'qc_status', 'examples/qc_status');
+            await models(modelsContract, 'result_class', 'examples/result_class');
+            await models(modelsContract, 'acao_recomendada', 'examples/acao_recomendada');
 
         } else if (action === 'store_image') {
             // const imageID = (await askQuestion('imageID: ')).trim();
             const imagePath = "./imagem.jpg" 
-            await storeImage(sollytchImageContract,imagePath);
+            await storeImage(sollytchImageContract,imagePath,{
+                testId: 'TEST-00001',
+                capturedAt: new Date().toISOString(),
+                deviceId: 'LEITOR-001',
+                mimeType: 'image/jpeg',
+                width: 1920,
+                height: 1080,
+                perceptualHash: '0000000000000000',
+                blurScore: 0
+            });
 
         } else if (action === 'query_image') {
             const whichQuery = (await askQuestion("Buscar pelo kit ou por imagem individual? (kit | imagem) ")).trim();
@@ -507,15 +481,15 @@ async function main() {
             }
         } else if (action === 'store_planilha'){
             const imagePath = "./examples/testesAfericao.xlsx" 
-            await storePlanilha(sollytchChainContract,imagePath);
+            await storePlanilha(planilhasContract,imagePath);
         } else if (action === 'query_planilha'){
             const whichQuery = (await askQuestion("Buscar pelo lote ou por planilha individual? (lote | planilha) ")).trim();
             if (whichQuery === "lote"){
                 const lote = await askQuestion('Insira o numero do lote: ')
-                await getPlanilhasByLote(sollytchChainContract, lote)
+                await getPlanilhasByLote(planilhasContract, lote)
             } else if (whichQuery === "planilha"){
                 const planilhaHash = await askQuestion('Insira o hash da planilha: ')
-                await getPlanilhaByHash(sollytchChainContract,planilhaHash)
+                await getPlanilhaByHash(planilhasContract,planilhaHash)
             }else{
                 console.log("opcao invalida")
             }
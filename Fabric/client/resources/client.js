@@ -4,6 +4,7 @@ const crypto = require('node:crypto'); // usado para lidar com chaves privadas
 const fs = require('node:fs/promises'); // leitura de arquivos usando promises
 const path = require('node:path'); // manipula caminhos
 const { TextDecoder } = require('node:util'); // decodifica texto em utf8
+const { signTestPayload } = require('./testSignature.js'); // assina o JSON do teste
 
 // configuracoes principais do canal e chaincode
 const channelName = ('mainchannel');
@@ -39,82 +40,6 @@ const tlsCertPath = path.resolve(cryptoPath,
     'tls',
     'ca.crt');
 
-const controleInternoEncoder = {
-    'ok': 2,
-    'fail': 1,
-    'invalid': 0
-};
-
-function preprocessForPrediction(testData) {
-    console.log("Processando dados para predição...");
-    
-    const numericFeatures = [
-        'expiry_days_left', 'distance_mm', 'time_to_migrate_s', 'sample_volume_uL',
-        'sample_pH', 'sample_turbidity_NTU', 'sample_temp_C', 'ambient_T_C',
-        'ambient_RH_pct', 'lighting_lux', 'tilt_deg', 'preincubation_time_s',
-        'time_since_sampling_min', 'tempo_transporte_horas', 'estimated_concentration_ppb',
-        'incerteza_estimativa_ppb'
-    ];
-    
-    const categoricalFeatures = ['control_line_ok', 'controle_interno_result'];
-    const allFeatures = [...numericFeatures, ...categoricalFeatures];
-    
-    const processedData = {...testData};
-    
-    if (typeof processedData.control_line_ok === 'boolean') {
-        processedData.control_line_ok = processedData.control_line_ok ? 1 : 0;
-        console.log(`Booleano convertido: control_line_ok → ${processedData.control_line_ok}`);
-    }
-    
-    if (processedData.controle_interno_result in controleInternoEncoder) {
-        processedData.controle_interno_result = controleInternoEncoder[processedData.controle_interno_result];
-        console.log(`Codificada 'controle_interno_result': ${testData.controle_interno_result} → ${processedData.controle_interno_result}`);
-    } else {
-        processedData.controle_interno_result = 0; // valor padrão
-        console.log(`Valor desconhecido 'controle_interno_result': ${testData.controle_interno_result} → 0`);
-    }
-    
-    allFeatures.forEach(feature => {
-        if (processedData[feature] === null || processedData[feature] === undefined) {
-            processedData[feature] = 0;
-            console.log(`Preenchido valor nulo: ${feature} → 0`);
-        }
-    });
-    
-    if (processedData.image_blur_score === null || processedData.image_blur_score === undefined) {
-        processedData.image_blur_score = 0.0;
-    }
-    
-    const csvData = [
-        processedData.lat,
-        processedData.lon,
-        processedData.expiry_days_left,
-        processedData.distance_mm,
-        processedData.time_to_migrate_s,
-        processedData.sample_volume_uL,
-        processedData.sample_pH,
-        processedData.sample_turbidity_NTU,
-        processedData.sample_temp_C,
-        processedData.ambient_T_C,
-        processedData.ambient_RH_pct,
-        processedData.lighting_lux,
-        processedData.tilt_deg,
-        processedData.preincubation_time_s,
-        processedData.time_since_sampling_min,
-        processedData.image_blur_score,
-        processedData.tempo_transporte_horas,
-        processedData.estimated_concentration_ppb,
-        processedData.incerteza_estimativa_ppb,
-        processedData.control_line_ok,
-        processedData.controle_interno_result
-    ].join(',');
-    
-    console.log("Dados pré-processados com sucesso");
-    console.log(`CSV gerado: ${csvData}`);
-    
-    return csvData;
-}
-
 // endereco e alias (nome) do peer
 const peerEndpoint = ('localhost:7051');
 const peerHostAlias = ('peer0.org1.example.com');
@@ -165,9 +90,9 @@ async function newSigner() {
 // }
 
 async function invoke(jsonString, testID) {
-    const predictStr = preprocessForPrediction(jsonString);
     try {
-        await contract.submitTransaction("StoreTest", testID, jsonString, predictStr);
+        const { signature, keyID } = await signTestPayload(jsonString); // assinatura do operador ou leitor
+        await contract.submitTransaction("StoreTest", testID, jsonString, signature, keyID);
         console.log("Teste armazenado com sucesso");
     } catch (error) {
         console.error("Erro:", error);
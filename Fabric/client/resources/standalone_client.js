@@ -6,6 +6,7 @@ const fsRead = require('fs');
 const fs = require('node:fs/promises');
 const path = require('node:path');
 const { TextDecoder } = require('node:util');
+const { signTestPayload } = require('./testSignature.js');
 
 let network, gateway, sollytchChainContract, sollytchImageContract, client
 
@@ -43,82 +44,6 @@ const peerHostAlias = 'peer0.org1.example.com';
 
 const utf8Decoder = new TextDecoder();
 
-const controleInternoEncoder = {
-    'ok': 2,
-    'fail': 1,
-    'invalid': 0
-};
-
-function preprocessForPrediction(testData) {
-    console.log("Processando dados para predição...");
-    
-    const numericFeatures = [
-        'expiry_days_left', 'distance_mm', 'time_to_migrate_s', 'sample_volume_uL',
-        'sample_pH', 'sample_turbidity_NTU', 'sample_temp_C', 'ambient_T_C',
-        'ambient_RH_pct', 'lighting_lux', 'tilt_deg', 'preincubation_time_s',
-        'time_since_sampling_min', 'tempo_transporte_horas', 'estimated_concentration_ppb',
-        'incerteza_estimativa_ppb'
-    ];
-
-    const categoricalFeatures = ['control_line_ok', 'controle_interno_result'];
-    const allFeatures = [...numericFeatures, ...categoricalFeatures];
-
-    const processedData = {...testData};
-
-    if (typeof processedData.control_line_ok === 'boolean') {
-        processedData.control_line_ok = processedData.control_line_ok ? 1 : 0;
-        console.log(`Booleano convertido: control_line_ok → ${processedData.control_line_ok}`);
-    }
-
-    if (processedData.controle_interno_result in controleInternoEncoder) {
-        processedData.controle_interno_result = controleInternoEncoder[processedData.controle_interno_result];
-        console.log(`Codificada 'controle_interno_result': ${testData.controle_interno_result} → ${processedData.controle_interno_result}`);
-    } else {
-        processedData.controle_interno_result = 0; // valor padrão
-        console.log(`Valor desconhecido 'controle_interno_result': ${testData.controle_interno_result} → 0`);
-    }
-
-    allFeatures.forEach(feature => {
-        if (processedData[feature] === null || processedData[feature] === undefined) {
-            processedData[feature] = 0;
-            console.log(`Preenchido valor nulo: ${feature} → 0`);
-        }
-    });
-
-    if (processedData.image_blur_score === null || processedData.image_blur_score === undefined) {
-        processedData.image_blur_score = 0.0;
-    }
-
-    const csvData = [
-        processedData.lat,
-        processedData.lon,
-        processedData.expiry_days_left,
-        processedData.distance_mm,
-        processedData.time_to_migrate_s,
-        processedData.sample_volume_uL,
-        processedData.sample_pH,
-        processedData.sample_turbidity_NTU,
-        processedData.sample_temp_C,
-        processedData.ambient_T_C,
-        processedData.ambient_RH_pct,
-        processedData.lighting_lux,
-        processedData.tilt_deg,
-        processedData.preincubation_time_s,
-        processedData.time_since_sampling_min,
-        processedData.image_blur_score,
-        processedData.tempo_transporte_horas,
-        processedData.estimated_concentration_ppb,
-        processedData.incerteza_estimativa_ppb,
-        processedData.control_line_ok,
-        processedData.controle_interno_result
-    ].join(',');
-
-    console.log("Dados pré-processados com sucesso");
-    console.log(`CSV gerado: ${csvData}`);
-
-    return csvData;
-}
-
 async function newGrpcConnection() {
     const tlsRootCert = await fs.readFile(tlsCertPath);
     const tlsCredentials = grpc.credentials.createSsl(tlsRootCert);
@@ -154,13 +79,15 @@ async function storeTest(jsonStr) {
     const testID = testData.test_id;
     console.log(testID)
     
-    const predictStr = preprocessForPrediction(testData);
     try {
+        // assinatura destacada do operador ou leitor sobre o JSON do teste
+        const { signature, keyID } = await signTestPayload(jsonStr);
         await sollytchChainContract.submitTransaction(
             "StoreTest",
             testID,
             jsonStr,
-            predictStr
+            signature,
+            keyID
         );
         console.log(`Teste ${testID} armazenado com sucesso`)
     } catch (err) {
@@ -183,12 +110,14 @@ async function updateTest(jsonStr, testID) {
     }
 }
 
-async function storeModel(modelBase64, modelKey) {
+async function storeModel(modelBase64, modelKey, trainingStatsJSON) {
     try{
+        // estatísticas de treino (treino_ml/estatisticas.go), usadas em GetDriftReport
         await sollytchChainContract.submitTransaction(
-            'StoreModel',
+            'models:StoreModel',
             modelKey,
-            modelBase64
+            modelBase64,
+            trainingStatsJSON
         );
         console.log(`Modelo ${modelKey} armazenado com sucesso`)
     } catch(err){
@@ -241,7 +170,7 @@ async function queryTestByLote(lote){
 async function storePlanilha(lote,planilhaHash){
     try{
         await sollytchChainContract.submitTransaction(
-            "StorePlanilha",
+            "planilhas:StorePlanilha",
             lote,
             planilhaHash
         );
@@ -254,7 +183,7 @@ async function storePlanilha(lote,planilhaHash){
 async function queryPlanilhaByHash(planilhaHash){
     try {
         const rawResult = await sollytchChainContract.evaluateTransaction(
-            "GetPlanilhaByHash",
+            "planilhas:GetPlanilhaByHash",
             planilhaHash
         );
         
@@ -275,7 +204,7 @@ async function queryPlanilhaByHash(planilhaHash){
 async function queryPlanilhaByLote(lote){
     try {
         const rawResult = await sollytchChainContract.evaluateTransaction(
-            "GetPlanilhasByLote",
+            "planilhas:GetPlanilhasByLote",
             lote
         );
         
@@ -293,16 +222,32 @@ async function queryPlanilhaByLote(lote){
     }   
 }
 
-async function storeImage(imageHash, kitID) {
+// O chaincode só aceita imagens de kits registrados; registra o kit para a
+// organização do cliente se ainda não tiver dono
+async function ensureKitRegistered(kitID) {
+    try {
+        await sollytchImageContract.evaluateTransaction("GetKitOwner", kitID);
+    } catch (err) {
+        await sollytchImageContract.submitTransaction("RegisterKit", kitID);
+        console.log(`Kit ${kitID} registrado`);
+    }
+}
+
+// metadata: metadados da captura (testId, capturedAt, deviceId, mimeType,
+// width, height, sizeBytes, hashAlgorithm, perceptualHash, blurScore)
+async function storeImage(imageHash, kitID, metadata) {
     try{
+        await ensureKitRegistered(kitID);
         await sollytchImageContract.submitTransaction(
             "StoreImage",
             kitID,
-            imageHash
+            imageHash,
+            JSON.stringify(metadata)
         );
         console.log("Imagem armazenada com sucesso!");
     } catch(err){
         console.error("erro ao armazenar hash de imagem: ", err)
+        throw err
     }
 }
 
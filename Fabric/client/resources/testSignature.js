@@ -0,0 +1,56 @@
+const crypto = require('node:crypto');
+const fs = require('node:fs/promises');
+
+// chave privada (PEM) do operador ou do leitor que assina os testes
+// e o keyID registrado no ledger para ela (operator_did ou device_id)
+const signingKeyPath = process.env.SOLLYTCH_SIGNING_KEY;
+const signingKeyID = process.env.SOLLYTCH_SIGNING_KEY_ID;
+
+// forma canônica do JSON conforme o RFC 8785 (JCS), a mesma gerada pelo
+// chaincode para verificar a assinatura destacada do StoreTest: chaves
+// ordenadas por unidades UTF-16, sem espaços, strings e números como o
+// JSON.stringify os escreve (12.0 vira 12, 1e2 vira 100)
+// o vetor de teste compartilhado com o chaincode fica em
+// sollytch-chain/testdata/canonical_json.json
+function canonicalJSON(value) {
+    if (Array.isArray(value)) {
+        return `[${value.map(canonicalJSON).join(',')}]`;
+    }
+    if (value !== null && typeof value === 'object') {
+        const fields = Object.keys(value).sort()
+            .map(key => `${JSON.stringify(key)}:${canonicalJSON(value[key])}`);
+        return `{${fields.join(',')}}`;
+    }
+    return JSON.stringify(value);
+}
+
+// assina o JSON do teste e retorna { signature, keyID } para o StoreTest
+// ECDSA assina o SHA-256 do payload (DER); Ed25519 assina o payload direto
+// o JSON precisa conter o test_id, que o chaincode compara com o testID
+async function signTestPayload(jsonStr) {
+    if (!signingKeyPath) {
+        throw new Error('defina SOLLYTCH_SIGNING_KEY com a chave privada do operador ou leitor');
+    }
+
+    const testData = JSON.parse(jsonStr);
+    if (!testData.test_id) {
+        throw new Error('test_id é obrigatório no JSON assinado');
+    }
+
+    const keyID = signingKeyID || testData.operator_did;
+    if (!keyID) {
+        throw new Error('defina SOLLYTCH_SIGNING_KEY_ID ou informe operator_did no teste');
+    }
+
+    const privateKey = crypto.createPrivateKey(await fs.readFile(signingKeyPath));
+    const algorithm = privateKey.asymmetricKeyType === 'ed25519' ? null : 'sha256';
+    const payload = Buffer.from(canonicalJSON(testData));
+    const signature = crypto.sign(algorithm, payload, privateKey).toString('base64');
+
+    return { signature, keyID };
+}
+
+module.exports = {
+    canonicalJSON,
+    signTestPayload
+};
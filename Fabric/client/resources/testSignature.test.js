@@ -0,0 +1,14 @@
+// confere a forma canônica com o vetor compartilhado com o chaincode
+// executar com: node --test resources/
+const test = require('node:test');
+const assert = require('node:assert');
+const path = require('node:path');
+
+const { canonicalJSON } = require('./testSignature.js');
+const vectors = require(path.join(__dirname, '../../sollytch-chain/testdata/canonical_json.json'));
+
+for (const vector of vectors) {
+    test(vector.name, () => {
+        assert.strictEqual(canonicalJSON(JSON.parse(vector.input)), vector.canonical);
+    });
+}
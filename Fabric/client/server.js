@@ -113,13 +113,30 @@ app.post('/store/image', upload.single('image'), async (req, res) => {
   }
 
   try {
+    // Metadados da captura informados pelo leitor; tamanho, tipo e
+    // algoritmo de hash vêm do próprio arquivo. O SHA-256 é o mesmo hash
+    // que endereça o repositório de imagens da ccapi
+    let captureMetadata;
+    try {
+      captureMetadata = JSON.parse(req.body.metadata || '');
+    } catch (err) {
+      return res.status(400).json({ error: "metadata deve ser um JSON com os metadados da captura" });
+    }
+
     const buffer = fsRead.readFileSync(filePath);
     const hash = crypto
-      .createHash("sha512")
+      .createHash("sha256")
       .update(buffer)
       .digest("hex");
 
-    await withFabric(() => storeImage(hash, kitID));
+    const metadata = {
+      ...captureMetadata,
+      mimeType: req.file.mimetype,
+      sizeBytes: req.file.size,
+      hashAlgorithm: 'sha256'
+    };
+
+    await withFabric(() => storeImage(hash, kitID, metadata));
 
     res.json({
       message: "Imagem armazenada com sucesso",
@@ -139,9 +156,10 @@ app.post('/store/image', upload.single('image'), async (req, res) => {
 });
 
 // Grupo: Store Model
-app.post('/store/model', upload.single('model'), async (req, res) => {
+app.post('/store/model', upload.fields([{ name: 'model' }, { name: 'stats' }]), async (req, res) => {
   const { modelKey } = req.body;
-  const filePath = req.file?.path;
+  const filePath = req.files?.model?.[0]?.path;
+  const statsPath = req.files?.stats?.[0]?.path;
 
   if (!modelKey) {
     return res.status(400).json({ error: "modelKey é obrigatório" });
@@ -151,11 +169,16 @@ app.post('/store/model', upload.single('model'), async (req, res) => {
     return res.status(400).json({ error: "Arquivo de modelo não enviado" });
   }
 
+  if (!statsPath) {
+    return res.status(400).json({ error: "Estatísticas de treino não enviadas" });
+  }
+
   try {
     const buffer = fsRead.readFileSync(filePath);
     const base64 = buffer.toString('base64');
+    const trainingStats = fsRead.readFileSync(statsPath, 'utf8');
 
-    await withFabric(() => storeModel(base64, modelKey));
+    await withFabric(() => storeModel(base64, modelKey, trainingStats));
 
     res.json({
       message: "Modelo armazenado com sucesso",
@@ -166,9 +189,11 @@ app.post('/store/model', upload.single('model'), async (req, res) => {
     console.error(err);
     res.status(500).json({ error: err.message });
   } finally {
-    // Limpar arquivo temporário
-    if (filePath && fsRead.existsSync(filePath)) {
-      fsRead.unlinkSync(filePath);
+    // Limpar arquivos temporários
+    for (const tempPath of [filePath, statsPath]) {
+      if (tempPath && fsRead.existsSync(tempPath)) {
+        fsRead.unlinkSync(tempPath);
+      }
     }
   }
 });
@@ -88,6 +88,13 @@ CONN_EOF
 
   cat "$tempdir/src/connection.json"
 
+  # Índices do CouchDB do chaincode: o external builder copia metadata/ para
+  # a saída do release, de onde o peer cria os índices em statedb/couchdb
+  if [ -d "$CC_SRC_PATH/META-INF/statedb" ]; then
+    mkdir -p "$tempdir/src/metadata"
+    cp -a "$CC_SRC_PATH/META-INF/statedb" "$tempdir/src/metadata/"
+  fi
+
    mkdir -p "$tempdir/pkg"
 
 cat << METADATA-EOF > "$tempdir/pkg/metadata.json"
@@ -0,0 +1,73 @@
+#!/usr/bin/env bash
+
+# Gera a CA, o certificado do servidor do chaincode e o certificado de
+# cliente do peer para o TLS mútuo do chaincode-as-a-service, e monta em
+# pkg/ o pacote do chaincode com os PEMs no connection.json. O chaincode lê
+# certs/server e certs/ca/cert.pem (KEY_PATH, CERT_PATH e CA_CERT_PATH do
+# chaincode.env)
+#
+# Uso: ./scripts/ccaasCerts.sh <pasta do chaincode> [host do servidor]
+# Ex.: ./scripts/ccaasCerts.sh sollytch-chain
+#
+# As pastas certs/ e pkg/ não são versionadas: o connection.json de pkg/
+# contém a chave privada do cliente do peer. O connection.json versionado
+# (sem TLS) fornece apenas o address
+
+set -e
+
+CC_DIR=${1:?"Uso: ./scripts/ccaasCerts.sh <pasta do chaincode> [host do servidor]"}
+CC_DIR=$(cd "$CC_DIR" && pwd)
+CC_HOST=${2:-$(basename "$CC_DIR")}
+CERTS=$CC_DIR/certs
+PKG=$CC_DIR/pkg
+
+mkdir -p "$CERTS/ca" "$CERTS/server" "$CERTS/client" "$PKG"
+
+# CA do chaincode, usada pelo peer para validar o servidor (root_cert) e
+# pelo chaincode para validar o cliente (CA_CERT_PATH)
+openssl genrsa -out "$CERTS/ca/key.pem" 2048
+openssl req -new -x509 -nodes -days 365 -key "$CERTS/ca/key.pem" -out "$CERTS/ca/cert.pem" \
+    -subj "/O=Sollytch/OU=Chaincode/CN=ca.${CC_HOST}"
+
+# Certificado do servidor, com o host do address do connection.json
+echo "subjectAltName=DNS:${CC_HOST},DNS:localhost
+extendedKeyUsage=serverAuth" > "$CERTS/server.cnf"
+openssl req -newkey rsa:2048 -nodes -keyout "$CERTS/server/key.pem" -out "$CERTS/server/req.pem" \
+    -subj "/O=Sollytch/OU=Chaincode/CN=${CC_HOST}"
+openssl x509 -req -days 365 -set_serial 01 -in "$CERTS/server/req.pem" -extfile "$CERTS/server.cnf" \
+    -CA "$CERTS/ca/cert.pem" -CAkey "$CERTS/ca/key.pem" -out "$CERTS/server/cert.pem"
+
+# Certificado de cliente apresentado pelo peer
+echo "extendedKeyUsage=clientAuth" > "$CERTS/client.cnf"
+openssl req -newkey rsa:2048 -nodes -keyout "$CERTS/client/key.pem" -out "$CERTS/client/req.pem" \
+    -subj "/O=Sollytch/OU=Peer/CN=peer.${CC_HOST}"
+openssl x509 -req -days 365 -set_serial 02 -in "$CERTS/client/req.pem" -extfile "$CERTS/client.cnf" \
+    -CA "$CERTS/ca/cert.pem" -CAkey "$CERTS/ca/key.pem" -out "$CERTS/client/cert.pem"
+
+rm "$CERTS/server/req.pem" "$CERTS/client/req.pem" "$CERTS/server.cnf" "$CERTS/client.cnf"
+
+# PEM em uma linha, com as quebras escapadas para o JSON
+function one_line_pem {
+    awk 'NF {sub(/\r/, ""); printf "%s\\n",$0;}' "$1"
+}
+
+ADDRESS=$(sed -n 's/.*"address": *"\([^"]*\)".*/\1/p' "$CC_DIR/connection.json")
+
+cat > "$PKG/connection.json" <<CONN_EOF
+{
+  "address": "${ADDRESS}",
+  "dial_timeout": "10s",
+  "tls_required": true,
+  "client_auth_required": true,
+  "root_cert": "$(one_line_pem "$CERTS/ca/cert.pem")",
+  "client_key": "$(one_line_pem "$CERTS/client/key.pem")",
+  "client_cert": "$(one_line_pem "$CERTS/client/cert.pem")"
+}
+CONN_EOF
+
+# Pacote no formato do external builder: metadata.json e code.tar.gz com o connection.json
+cp "$CC_DIR/metadata.json" "$PKG/metadata.json"
+tar -C "$PKG" -czf "$PKG/code.tar.gz" connection.json
+tar -C "$PKG" -czf "$PKG/$(basename "$CC_DIR").tar.gz" metadata.json code.tar.gz
+
+echo "Certificados gerados em $CERTS; pacote com TLS em $PKG/$(basename "$CC_DIR").tar.gz"
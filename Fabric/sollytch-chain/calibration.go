@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Tipos de curva de calibração suportados
+const (
+	// c = a0 + a1*d + a2*t
+	calibrationLinear = "linear"
+	// c = a0 + a1*d + a2*d² + a3*t + a4*t²
+	calibrationQuadratic = "quadratic"
+	// c = exp(a0 + a1*d + a2*t)
+	calibrationExponential = "exponential"
+	// logística de 4 parâmetros invertida sobre d: c = C*((A-D)/(d-D) - 1)^(1/B)
+	calibration4PL = "4pl"
+)
+
+// Quantidade de coeficientes exigida por tipo de curva
+var calibrationCoefficients = map[string]int{
+	calibrationLinear:      3,
+	calibrationQuadratic:   5,
+	calibrationExponential: 3,
+	calibration4PL:         4,
+}
+
+// struct json da curva de calibração de um lote de kits
+type KitCalibration struct {
+	//trackers
+	Version       int    `json:"version"`
+	LastUpdatedAt string `json:"last_updated_at"`
+	CreatedAt     string `json:"created_at"`
+	SupplierMSP   string `json:"supplier_msp"`
+
+	//chaves de busca
+	CalibrationID string `json:"calibration_id"`
+	KitLot        string `json:"kit_lot"`
+
+	//validade
+	ValidFrom  string `json:"valid_from"`
+	ValidUntil string `json:"valid_until"`
+
+	//curva
+	ModelType    string    `json:"model_type"`
+	Coefficients []float64 `json:"coefficients"`
+
+	//faixa válida das entradas
+	DistanceMinMM float64 `json:"distance_min_mm"`
+	DistanceMaxMM float64 `json:"distance_max_mm"`
+	TimeMinS      float64 `json:"time_min_s"`
+	TimeMaxS      float64 `json:"time_max_s"`
+
+	//parametros de incerteza
+	UncertaintyAbsPpb  float64 `json:"uncertainty_abs_ppb"`
+	UncertaintyRelPct  float64 `json:"uncertainty_rel_pct"`
+	DistanceResolution float64 `json:"distance_resolution_mm"`
+}
+
+// Função que monta a chave de estado de uma calibração
+func calibrationKey(ctx contractapi.TransactionContextInterface, calibrationID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("calibracao", []string{calibrationID})
+}
+
+/*
+	Função que calcula a concentração (ppb) prevista pela curva de calibração
+	a partir da distância de migração (mm) e do tempo de migração (s)
+*/
+func (cal *KitCalibration) concentration(distanceMM float64, timeS float64) (float64, error) {
+	a := cal.Coefficients
+
+	var c float64
+	switch cal.ModelType {
+	case calibrationLinear:
+		c = a[0] + a[1]*distanceMM + a[2]*timeS
+	case calibrationQuadratic:
+		c = a[0] + a[1]*distanceMM + a[2]*distanceMM*distanceMM + a[3]*timeS + a[4]*timeS*timeS
+	case calibrationExponential:
+		c = math.Exp(a[0] + a[1]*distanceMM + a[2]*timeS)
+	case calibration4PL:
+		ratio := (a[0]-a[3])/(distanceMM-a[3]) - 1
+		if ratio <= 0 || a[1] == 0 {
+			return 0, fmt.Errorf("distance_mm %.4f fora do dominio da curva 4PL", distanceMM)
+		}
+		c = a[2] * math.Pow(ratio, 1/a[1])
+	default:
+		return 0, fmt.Errorf("model_type %s não suportado", cal.ModelType)
+	}
+
+	if math.IsNaN(c) || math.IsInf(c, 0) {
+		return 0, fmt.Errorf("curva de calibração produziu valor invalido")
+	}
+
+	// Concentrações negativas não têm significado físico
+	return math.Max(c, 0), nil
+}
+
+/*
+	Função que estima a incerteza padrão (ppb) da concentração calculada
+	Combina um termo absoluto, um termo relativo à concentração e a
+	propagação da resolução da leitura de distância pela curva
+*/
+func (cal *KitCalibration) uncertainty(distanceMM float64, timeS float64, c float64) float64 {
+	relative := cal.UncertaintyRelPct / 100 * c
+
+	// Derivada numérica da curva em relação à distância
+	var propagated float64
+	if cal.DistanceResolution > 0 {
+		h := cal.DistanceResolution / 2
+		upper, errUpper := cal.concentration(distanceMM+h, timeS)
+		lower, errLower := cal.concentration(distanceMM-h, timeS)
+		if errUpper == nil && errLower == nil {
+			propagated = (upper - lower) / (2 * h) * cal.DistanceResolution
+		}
+	}
+
+	return math.Sqrt(cal.UncertaintyAbsPpb*cal.UncertaintyAbsPpb + relative*relative + propagated*propagated)
+}
+
+// Função que indica se as entradas estão dentro da faixa válida da calibração
+func (cal *KitCalibration) inRange(distanceMM float64, timeS float64) bool {
+	return distanceMM >= cal.DistanceMinMM && distanceMM <= cal.DistanceMaxMM &&
+		timeS >= cal.TimeMinS && timeS <= cal.TimeMaxS
+}
+
+/*
+	Função responsável por registrar ou atualizar a curva de calibração de
+	um lote de kits. Apenas fornecedores podem registrar calibrações, e uma
+	calibração só pode ser alterada pela organização que a registrou
+*/
+func (r *RegistryContract) RegisterCalibration(ctx contractapi.TransactionContextInterface, calibrationJSON string) error {
+	if err := requireRole(ctx, supplierRole); err != nil {
+		return err
+	}
+
+	mspID, err := callerMSP(ctx)
+	if err != nil {
+		return err
+	}
+
+	var cal KitCalibration
+	if err := json.Unmarshal([]byte(calibrationJSON), &cal); err != nil {
+		return fmt.Errorf("erro ao decodificar JSON: %v", err)
+	}
+
+	// Valida identificação, curva e faixas
+	if cal.CalibrationID == "" || cal.KitLot == "" {
+		return fmt.Errorf("calibration_id e kit_lot são obrigatórios")
+	}
+
+	expected, ok := calibrationCoefficients[cal.ModelType]
+	if !ok {
+		return fmt.Errorf("model_type %s não suportado", cal.ModelType)
+	}
+	if len(cal.Coefficients) != expected {
+		return fmt.Errorf("model_type %s exige %d coeficientes, recebido %d", cal.ModelType, expected, len(cal.Coefficients))
+	}
+
+	if cal.DistanceMinMM >= cal.DistanceMaxMM || cal.TimeMinS >= cal.TimeMaxS {
+		return fmt.Errorf("faixa válida de distance_mm ou time_s invalida")
+	}
+	if cal.UncertaintyAbsPpb < 0 || cal.UncertaintyRelPct < 0 || cal.DistanceResolution < 0 {
+		return fmt.Errorf("parametros de incerteza não podem ser negativos")
+	}
+
+	validFrom, err := parseDate(cal.ValidFrom)
+	if err != nil {
+		return fmt.Errorf("valid_from: %v", err)
+	}
+	validUntil, err := parseDate(cal.ValidUntil)
+	if err != nil {
+		return fmt.Errorf("valid_until: %v", err)
+	}
+	if !validUntil.After(validFrom) {
+		return fmt.Errorf("valid_until deve ser posterior a valid_from")
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+	timestamp := now.Format(time.RFC3339)
+
+	key, err := calibrationKey(ctx, cal.CalibrationID)
+	if err != nil {
+		return err
+	}
+
+	existingBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return err
+	}
+
+	if existingBytes != nil {
+		var existing KitCalibration
+		if err := json.Unmarshal(existingBytes, &existing); err != nil {
+			return err
+		}
+
+		if existing.SupplierMSP != mspID {
+			return fmt.Errorf("calibração %s pertence a %s", cal.CalibrationID, existing.SupplierMSP)
+		}
+
+		cal.Version = existing.Version + 1
+		cal.CreatedAt = existing.CreatedAt
+	} else {
+		cal.Version = 0
+		cal.CreatedAt = timestamp
+	}
+
+	cal.SupplierMSP = mspID
+	cal.LastUpdatedAt = timestamp
+
+	bytes, err := json.Marshal(cal)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, bytes)
+}
+
+// Função que carrega uma calibração do ledger pelo seu ID
+func getCalibration(ctx contractapi.TransactionContextInterface, calibrationID string) (*KitCalibration, error) {
+	if calibrationID == "" {
+		return nil, fmt.Errorf("calibrationID não pode ser vazio")
+	}
+
+	key, err := calibrationKey(ctx, calibrationID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao acessar o ledger: %v", err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("calibração %s não registrada", calibrationID)
+	}
+
+	var cal KitCalibration
+	if err := json.Unmarshal(data, &cal); err != nil {
+		return nil, fmt.Errorf("erro ao deserializar calibração: %v", err)
+	}
+
+	return &cal, nil
+}
+
+// Função que consulta uma curva de calibração pelo seu ID
+func (r *RegistryContract) GetCalibration(ctx contractapi.TransactionContextInterface, calibrationID string) (*KitCalibration, error) {
+	return getCalibration(ctx, calibrationID)
+}
+
+/*
+	Função que calcula a concentração estimada e sua incerteza usando a
+	calibração referenciada pelo teste. Rejeita calibrações desconhecidas,
+	fora do período de validade ou de outro lote de kits, e marca testes
+	cujas leituras estão fora da faixa válida da curva
+*/
+func applyCalibration(ctx contractapi.TransactionContextInterface, record *TestRecord, now time.Time) error {
+	if record.KitCalibrationID == "" {
+		return fmt.Errorf("kit_calibration_id é obrigatório")
+	}
+
+	cal, err := getCalibration(ctx, record.KitCalibrationID)
+	if err != nil {
+		return err
+	}
+
+	if cal.KitLot != record.CassetteLot {
+		return fmt.Errorf("calibração %s pertence ao lote %s, não ao lote %s", cal.CalibrationID, cal.KitLot, record.CassetteLot)
+	}
+
+	validFrom, err := parseDate(cal.ValidFrom)
+	if err != nil {
+		return err
+	}
+	validUntil, err := parseDate(cal.ValidUntil)
+	if err != nil {
+		return err
+	}
+	if now.Before(validFrom) || !now.Before(validUntil) {
+		return fmt.Errorf("calibração %s fora do período de validade", cal.CalibrationID)
+	}
+
+	// Sem a leitura da linha de teste não há como estimar a concentração
+	if !record.DistanceMM.Valid || !record.TimeToMigrateS.Valid {
+		return fmt.Errorf("distance_mm e time_to_migrate_s são obrigatórios para a calibração")
+	}
+	distance := record.DistanceMM.Float64
+	seconds := record.TimeToMigrateS.Float64
+
+	c, err := cal.concentration(distance, seconds)
+	if err != nil {
+		return err
+	}
+
+	record.EstimatedConcentrationPpb = c
+	record.IncertezaEstimativaPpb = cal.uncertainty(distance, seconds, c)
+	record.ConcentrationOutOfRange = !cal.inRange(distance, seconds)
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCalibrationConcentration(t *testing.T) {
+	tests := []struct {
+		name     string
+		cal      KitCalibration
+		expected float64
+	}{
+		{
+			name:     "linear",
+			cal:      KitCalibration{ModelType: calibrationLinear, Coefficients: []float64{1, 2, 0.01}},
+			expected: 1 + 2*20 + 0.01*400,
+		},
+		{
+			name:     "quadratic",
+			cal:      KitCalibration{ModelType: calibrationQuadratic, Coefficients: []float64{1, 1, 0.5, 0, 0.001}},
+			expected: 1 + 20 + 0.5*400 + 0.001*160000,
+		},
+		{
+			name:     "exponential",
+			cal:      KitCalibration{ModelType: calibrationExponential, Coefficients: []float64{0.5, 0.1, 0}},
+			expected: math.Exp(0.5 + 0.1*20),
+		},
+		{
+			name:     "4pl",
+			cal:      KitCalibration{ModelType: calibration4PL, Coefficients: []float64{40, 1, 10, 0}},
+			expected: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		c, err := tt.cal.concentration(20, 400)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if math.Abs(c-tt.expected) > 1e-9 {
+			t.Errorf("%s: expected %f, got %f", tt.name, tt.expected, c)
+		}
+	}
+}
+
+func TestCalibration4PLOutOfDomain(t *testing.T) {
+	cal := KitCalibration{ModelType: calibration4PL, Coefficients: []float64{40, 1, 10, 0}}
+
+	if _, err := cal.concentration(45, 400); err == nil {
+		t.Fatal("expected error for distance beyond the upper asymptote")
+	}
+}
+
+func TestCalibrationUncertainty(t *testing.T) {
+	cal := KitCalibration{
+		ModelType:          calibrationLinear,
+		Coefficients:       []float64{0, 2, 0},
+		UncertaintyAbsPpb:  3,
+		UncertaintyRelPct:  10,
+		DistanceResolution: 2,
+	}
+
+	c, err := cal.concentration(20, 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sqrt(3² + (0.1*40)² + (2*2)²)
+	expected := math.Sqrt(9 + 16 + 16)
+	if u := cal.uncertainty(20, 400, c); math.Abs(u-expected) > 1e-9 {
+		t.Errorf("expected %f, got %f", expected, u)
+	}
+}
+
+func TestStoreTestCalibration(t *testing.T) {
+	f := newStoreTestFixture(t)
+
+	// CAL1050 is c = 1 + d, with 1 ppb of absolute uncertainty
+	record := f.mustStore(t, "TEST-00001", storeTestJSON(t, "TEST-00001", map[string]interface{}{
+		"estimated_concentration_ppb": 99,
+		"incerteza_estimativa_ppb":    0,
+	}))
+	if math.Abs(record.EstimatedConcentrationPpb-25.87) > 1e-9 || record.IncertezaEstimativaPpb != 1 || record.ConcentrationOutOfRange {
+		t.Errorf("expected concentration from CAL1050, got %+v", record)
+	}
+
+	// Readings outside the valid range are stored and flagged
+	record = f.mustStore(t, "TEST-00002", storeTestJSON(t, "TEST-00002", map[string]interface{}{"distance_mm": 45}))
+	if !record.ConcentrationOutOfRange {
+		t.Error("expected distance beyond the curve range to be flagged")
+	}
+
+	f.assertRejected(t, "TEST-00003", storeTestJSON(t, "TEST-00003", map[string]interface{}{"kit_calibration_id": "CAL-999"}), "CAL-999 não registrada")
+	f.assertRejected(t, "TEST-00004", storeTestJSON(t, "TEST-00004", map[string]interface{}{"kit_calibration_id": nil}), "kit_calibration_id é obrigatório")
+	f.assertRejected(t, "TEST-00005", storeTestJSON(t, "TEST-00005", map[string]interface{}{"distance_mm": nil}), "distance_mm e time_to_migrate_s")
+
+	// Calibrations are only used within their validity period
+	supplier := newTestContext(f.stub, newIdentity("Org1MSP", "supplier", map[string]string{roleAttribute: supplierRole}))
+	mustTx(t, f.stub, func() error {
+		return new(RegistryContract).RegisterCalibration(supplier, fmt.Sprintf(`{"calibration_id":"CAL1049","kit_lot":"C22009",`+
+			`"valid_from":%q,"valid_until":%q,"model_type":"linear","coefficients":[1,1,0],`+
+			`"distance_min_mm":0,"distance_max_mm":40,"time_min_s":0,"time_max_s":900}`,
+			f.now.Add(-60*24*time.Hour).Format(time.RFC3339), f.now.Add(-time.Hour).Format(time.RFC3339)))
+	})
+	f.assertRejected(t, "TEST-00006", storeTestJSON(t, "TEST-00006", map[string]interface{}{"kit_calibration_id": "CAL1049"}), "fora do período de validade")
+}
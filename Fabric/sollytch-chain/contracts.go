@@ -0,0 +1,100 @@
+package main
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+)
+
+// Versão publicada nos metadados do chaincode (org.hyperledger.fabric:GetMetadata)
+const chaincodeVersion = "1.0.0"
+
+/*
+	Contrato "tests": registro, consulta, revisão e migração dos testes
+	Chamado pelos clientes como "tests:<transação>"; é o contrato padrão
+*/
+type TestContract struct {
+	contractapi.Contract
+}
+
+// Transações somente leitura do contrato de testes
+func (t *TestContract) GetEvaluateTransactions() []string {
+	return []string{"GetTestByID", "GetTestsByLote", "GetDiscrepancies"}
+}
+
+// Contrato "planilhas": hashes das planilhas por lote de cassete
+type PlanilhaContract struct {
+	contractapi.Contract
+}
+
+// Transações somente leitura do contrato de planilhas
+func (c *PlanilhaContract) GetEvaluateTransactions() []string {
+	return []string{"GetPlanilhasByLote", "GetPlanilhaByHash"}
+}
+
+// Contrato "models": modelos de ML usados nas predições e seu drift
+type ModelContract struct {
+	contractapi.Contract
+}
+
+// Transações somente leitura do contrato de modelos
+func (m *ModelContract) GetEvaluateTransactions() []string {
+	return []string{"GetDriftReport"}
+}
+
+/*
+	Contrato "registry": cadastros de referência usados na validação dos
+	testes (lotes de reagente, transportes, calibrações, leitores e operadores)
+*/
+type RegistryContract struct {
+	contractapi.Contract
+}
+
+// Transações somente leitura do contrato de cadastros
+func (r *RegistryContract) GetEvaluateTransactions() []string {
+	return []string{
+		"GetReagentLot",
+		"GetShipment",
+		"GetCalibration",
+		"GetDevice",
+		"GetOperator",
+		"GetOperatorByDID",
+		"GetExpiringCertifications",
+	}
+}
+
+// Função que monta os contratos do chaincode com nome e descrição
+func newContracts() []contractapi.ContractInterface {
+	tests := new(TestContract)
+	tests.Name = "tests"
+	tests.Info = metadata.InfoMetadata{
+		Title:       "Testes",
+		Description: "Registro de testes com predição on-chain, revisão e aprovação",
+		Version:     chaincodeVersion,
+	}
+
+	planilhas := new(PlanilhaContract)
+	planilhas.Name = "planilhas"
+	planilhas.Info = metadata.InfoMetadata{
+		Title:       "Planilhas",
+		Description: "Hashes das planilhas de ensaio por lote de cassete",
+		Version:     chaincodeVersion,
+	}
+
+	models := new(ModelContract)
+	models.Name = "models"
+	models.Info = metadata.InfoMetadata{
+		Title:       "Modelos",
+		Description: "Modelos de ML, envio em partes e relatórios de drift",
+		Version:     chaincodeVersion,
+	}
+
+	registry := new(RegistryContract)
+	registry.Name = "registry"
+	registry.Info = metadata.InfoMetadata{
+		Title:       "Cadastros",
+		Description: "Lotes de reagente, transportes, calibrações, leitores e operadores",
+		Version:     chaincodeVersion,
+	}
+
+	return []contractapi.ContractInterface{tests, planilhas, models, registry}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestContractMetadata(t *testing.T) {
+	chaincode, err := contractapi.NewChaincode(newContracts()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stub := shimtest.NewMockStub("sollytch-chain", chaincode)
+	response := stub.MockInvoke("tx1", [][]byte{[]byte("org.hyperledger.fabric:GetMetadata")})
+	if response.Status != 200 {
+		t.Fatalf("GetMetadata failed: %s", response.Message)
+	}
+
+	var meta struct {
+		Contracts map[string]struct {
+			Transactions []struct {
+				Name string   `json:"name"`
+				Tag  []string `json:"tag"`
+			} `json:"transactions"`
+		} `json:"contracts"`
+	}
+	if err := json.Unmarshal(response.Payload, &meta); err != nil {
+		t.Fatal(err)
+	}
+
+	tags := map[string][]string{}
+	for _, name := range []string{"tests", "planilhas", "models", "registry"} {
+		contract, ok := meta.Contracts[name]
+		if !ok {
+			t.Fatalf("expected contract %s in metadata", name)
+		}
+		for _, tx := range contract.Transactions {
+			tags[name+":"+tx.Name] = tx.Tag
+		}
+	}
+
+	if _, ok := tags["planilhas:PlanilhaExists"]; ok {
+		t.Error("expected PlanilhaExists to be hidden")
+	}
+
+	expected := map[string]string{
+		"tests:StoreTest":              "SUBMIT",
+		"tests:GetTestByID":            "EVALUATE",
+		"planilhas:StorePlanilha":      "SUBMIT",
+		"planilhas:GetPlanilhasByLote": "EVALUATE",
+		"models:GetDriftReport":        "EVALUATE",
+		"registry:RegisterDevice":      "SUBMIT",
+		"registry:GetDevice":           "EVALUATE",
+	}
+	for tx, tag := range expected {
+		found := false
+		for _, value := range tags[tx] {
+			if value == tag {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected %s to be tagged %s, got %v", tx, tag, tags[tx])
+		}
+	}
+}
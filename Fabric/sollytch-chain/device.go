@@ -0,0 +1,295 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Situações possíveis de um leitor registrado
+const (
+	deviceActive  = "ativo"
+	deviceRevoked = "revogado"
+)
+
+// struct json do leitor (dispositivo) registrado
+type Device struct {
+	//trackers
+	Version       int    `json:"version"`
+	LastUpdatedAt string `json:"last_updated_at"`
+	CreatedAt     string `json:"created_at"`
+
+	//chave de busca
+	DeviceID string `json:"device_id"`
+
+	//conteudo
+	OwnerMSP         string   `json:"owner_msp"`
+	PublicKey        string   `json:"public_key"`
+	AllowedFirmware  []string `json:"allowed_firmware"`
+	Status           string   `json:"status"`
+	RevokedAt        string   `json:"revoked_at,omitempty"`
+	RevokedBy        string   `json:"revoked_by,omitempty"`
+	RevocationReason string   `json:"revocation_reason,omitempty"`
+}
+
+// Função que monta a chave de estado de um leitor
+func deviceKey(ctx contractapi.TransactionContextInterface, deviceID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("dispositivo", []string{deviceID})
+}
+
+// Função que valida uma chave pública em PEM (PKIX)
+func parsePublicKeyPEM(publicKey string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(publicKey))
+	if block == nil {
+		return nil, fmt.Errorf("public_key não está em formato PEM")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("public_key invalida: %v", err)
+	}
+
+	return key, nil
+}
+
+// Função que grava um leitor no ledger
+func putDevice(ctx contractapi.TransactionContextInterface, device *Device) error {
+	key, err := deviceKey(ctx, device.DeviceID)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.Marshal(device)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, bytes)
+}
+
+/*
+	Função que carrega um leitor e garante que o cliente pertence à
+	organização dona do dispositivo, antes de qualquer alteração
+*/
+func loadOwnedDevice(ctx contractapi.TransactionContextInterface, deviceID string) (*Device, error) {
+	device, err := getDevice(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := callerMSP(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if device.OwnerMSP != mspID {
+		return nil, fmt.Errorf("dispositivo %s pertence a %s", deviceID, device.OwnerMSP)
+	}
+
+	return device, nil
+}
+
+/*
+	Função responsável por registrar um novo leitor no ledger
+	Exige o papel "admin"; a organização do cliente passa a ser a dona
+	do dispositivo
+*/
+func (r *RegistryContract) RegisterDevice(ctx contractapi.TransactionContextInterface, deviceJSON string) error {
+	// Garante que o cliente é um administrador
+	if err := requireRole(ctx, adminRole); err != nil {
+		return err
+	}
+
+	var device Device
+	if err := json.Unmarshal([]byte(deviceJSON), &device); err != nil {
+		return fmt.Errorf("erro ao decodificar JSON: %v", err)
+	}
+
+	if device.DeviceID == "" {
+		return fmt.Errorf("device_id é obrigatório")
+	}
+	if len(device.AllowedFirmware) == 0 {
+		return fmt.Errorf("allowed_firmware não pode ser vazio")
+	}
+	if _, err := parsePublicKeyPEM(device.PublicKey); err != nil {
+		return err
+	}
+
+	key, err := deviceKey(ctx, device.DeviceID)
+	if err != nil {
+		return err
+	}
+
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("dispositivo %s ja existe", device.DeviceID)
+	}
+
+	mspID, err := callerMSP(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	device.Version = 0
+	device.OwnerMSP = mspID
+	device.Status = deviceActive
+	device.CreatedAt = now.Format(time.RFC3339)
+	device.LastUpdatedAt = device.CreatedAt
+	device.RevokedAt = ""
+	device.RevokedBy = ""
+	device.RevocationReason = ""
+
+	return putDevice(ctx, &device)
+}
+
+/*
+	Função que publica a aprovação de uma nova versão de firmware
+	para um leitor. Exige o papel "admin" na organização dona do dispositivo
+*/
+func (r *RegistryContract) ApproveFirmware(ctx contractapi.TransactionContextInterface, deviceID string, fwVersion string) error {
+	// Garante que o cliente é um administrador
+	if err := requireRole(ctx, adminRole); err != nil {
+		return err
+	}
+
+	if fwVersion == "" {
+		return fmt.Errorf("fwVersion não pode ser vazio")
+	}
+
+	device, err := loadOwnedDevice(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+
+	for _, allowed := range device.AllowedFirmware {
+		if allowed == fwVersion {
+			return fmt.Errorf("firmware %s ja aprovado para o dispositivo %s", fwVersion, deviceID)
+		}
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	device.AllowedFirmware = append(device.AllowedFirmware, fwVersion)
+	device.Version++
+	device.LastUpdatedAt = now.Format(time.RFC3339)
+
+	return putDevice(ctx, device)
+}
+
+/*
+	Função que revoga um leitor, mantendo o registro com a identidade
+	de quem revogou. Testes desse dispositivo passam a ser rejeitados.
+	Exige o papel "admin" na organização dona do dispositivo
+*/
+func (r *RegistryContract) RevokeDevice(ctx contractapi.TransactionContextInterface, deviceID string, reason string) error {
+	// Garante que o cliente é um administrador
+	if err := requireRole(ctx, adminRole); err != nil {
+		return err
+	}
+
+	if reason == "" {
+		return fmt.Errorf("reason não pode ser vazio")
+	}
+
+	device, err := loadOwnedDevice(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+
+	if device.Status == deviceRevoked {
+		return fmt.Errorf("dispositivo %s ja revogado", deviceID)
+	}
+
+	revokedBy, err := callerID(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	device.Status = deviceRevoked
+	device.RevokedAt = now.Format(time.RFC3339)
+	device.RevokedBy = revokedBy
+	device.RevocationReason = reason
+	device.Version++
+	device.LastUpdatedAt = device.RevokedAt
+
+	return putDevice(ctx, device)
+}
+
+// Função que carrega um leitor do ledger pelo seu ID
+func getDevice(ctx contractapi.TransactionContextInterface, deviceID string) (*Device, error) {
+	if deviceID == "" {
+		return nil, fmt.Errorf("deviceID não pode ser vazio")
+	}
+
+	key, err := deviceKey(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao acessar o ledger: %v", err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("dispositivo %s não registrado", deviceID)
+	}
+
+	var device Device
+	if err := json.Unmarshal(data, &device); err != nil {
+		return nil, fmt.Errorf("erro ao deserializar dispositivo: %v", err)
+	}
+
+	return &device, nil
+}
+
+// Função que consulta um leitor pelo seu ID
+func (r *RegistryContract) GetDevice(ctx contractapi.TransactionContextInterface, deviceID string) (*Device, error) {
+	return getDevice(ctx, deviceID)
+}
+
+/*
+	Função que rejeita testes de leitores não registrados, revogados
+	ou executando firmware fora da lista de versões aprovadas
+*/
+func checkDevice(ctx contractapi.TransactionContextInterface, record *TestRecord) error {
+	if record.DeviceID == "" {
+		return fmt.Errorf("device_id é obrigatório")
+	}
+
+	device, err := getDevice(ctx, record.DeviceID)
+	if err != nil {
+		return err
+	}
+
+	if device.Status != deviceActive {
+		return fmt.Errorf("dispositivo %s revogado", record.DeviceID)
+	}
+
+	for _, allowed := range device.AllowedFirmware {
+		if allowed == record.DeviceFWVersion {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("firmware %s não aprovado para o dispositivo %s", record.DeviceFWVersion, record.DeviceID)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+// Gera um par de chaves ECDSA P-256 e a chave pública em PEM (PKIX)
+func newSigningKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func deviceJSON(t *testing.T, deviceID string, publicKeyPEM string) string {
+	t.Helper()
+	bytes, err := json.Marshal(Device{DeviceID: deviceID, PublicKey: publicKeyPEM, AllowedFirmware: []string{"1.0.0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bytes)
+}
+
+func TestRegisterDeviceRequiresAdmin(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-chain", nil)
+	registry := new(RegistryContract)
+	_, publicKey := newSigningKey(t)
+
+	operator := newTestContext(stub, newIdentity("Org1MSP", "operator", nil))
+	if err := inTx(t, stub, func() error { return registry.RegisterDevice(operator, deviceJSON(t, "DEV-001", publicKey)) }); err == nil {
+		t.Fatal("expected device registration without admin role to be rejected")
+	}
+
+	admin := newTestContext(stub, newIdentity("Org1MSP", "admin", map[string]string{roleAttribute: adminRole}))
+	mustTx(t, stub, func() error { return registry.RegisterDevice(admin, deviceJSON(t, "DEV-001", publicKey)) })
+
+	device, err := getDevice(admin, "DEV-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.OwnerMSP != "Org1MSP" || device.Status != deviceActive {
+		t.Errorf("unexpected registered device: %+v", device)
+	}
+
+	if err := inTx(t, stub, func() error { return registry.RegisterDevice(admin, deviceJSON(t, "DEV-001", publicKey)) }); err == nil {
+		t.Error("expected duplicate device to be rejected")
+	}
+
+	// Only the owning organisation can change the device
+	otherAdmin := newTestContext(stub, newIdentity("Org2MSP", "admin", map[string]string{roleAttribute: adminRole}))
+	if err := inTx(t, stub, func() error { return registry.ApproveFirmware(otherAdmin, "DEV-001", "1.1.0") }); err == nil {
+		t.Error("expected firmware approval from another organisation to be rejected")
+	}
+	if err := inTx(t, stub, func() error { return registry.RevokeDevice(otherAdmin, "DEV-001", "perdido") }); err == nil {
+		t.Error("expected revocation from another organisation to be rejected")
+	}
+
+	// Clients of the owning organisation also need the admin role
+	if err := inTx(t, stub, func() error { return registry.ApproveFirmware(operator, "DEV-001", "1.1.0") }); err == nil {
+		t.Error("expected firmware approval without admin role to be rejected")
+	}
+	if err := inTx(t, stub, func() error { return registry.RevokeDevice(operator, "DEV-001", "perdido") }); err == nil {
+		t.Error("expected revocation without admin role to be rejected")
+	}
+
+	mustTx(t, stub, func() error { return registry.ApproveFirmware(admin, "DEV-001", "1.1.0") })
+	mustTx(t, stub, func() error { return registry.RevokeDevice(admin, "DEV-001", "perdido") })
+
+	device, err = getDevice(admin, "DEV-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.Status != deviceRevoked || len(device.AllowedFirmware) != 2 {
+		t.Errorf("unexpected device after admin changes: %+v", device)
+	}
+}
+
+func TestStoreTestDevice(t *testing.T) {
+	f := newStoreTestFixture(t)
+	registry := new(RegistryContract)
+	admin := newTestContext(f.stub, newIdentity("Org1MSP", "admin", map[string]string{roleAttribute: adminRole}))
+
+	f.mustStore(t, "TEST-00001", storeTestJSON(t, "TEST-00001", nil))
+
+	f.assertRejected(t, "TEST-00002", storeTestJSON(t, "TEST-00002", map[string]interface{}{"device_fw_version": "1.0.5"}), "firmware 1.0.5 não aprovado")
+	f.assertRejected(t, "TEST-00003", storeTestJSON(t, "TEST-00003", map[string]interface{}{"device_id": "DEV-999"}), "DEV-999")
+	f.assertRejected(t, "TEST-00004", storeTestJSON(t, "TEST-00004", map[string]interface{}{"device_id": nil}), "device_id é obrigatório")
+
+	// Approved firmware is accepted from the next test on
+	mustTx(t, f.stub, func() error { return registry.ApproveFirmware(admin, "DEV-001", "1.0.5") })
+	f.mustStore(t, "TEST-00002", storeTestJSON(t, "TEST-00002", map[string]interface{}{"device_fw_version": "1.0.5"}))
+
+	// A revoked device is rejected whatever its firmware
+	mustTx(t, f.stub, func() error { return registry.RevokeDevice(admin, "DEV-001", "perdido") })
+	f.assertRejected(t, "TEST-00005", storeTestJSON(t, "TEST-00005", nil), "revogado")
+}
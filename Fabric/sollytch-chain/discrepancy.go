@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Nome do evento emitido quando um teste possui divergências
+const discrepancyEvent = "TestDiscrepancy"
+
+// Divergência entre o valor informado e o valor previsto no ledger
+type Discrepancy struct {
+	Field     string `json:"field"`
+	Reported  string `json:"reported"`
+	Predicted string `json:"predicted"`
+}
+
+// Payload do evento de divergência
+type discrepancyEventPayload struct {
+	TestID        string        `json:"test_id"`
+	CassetteLot   string        `json:"cassette_lot"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
+
+/*
+	Função que compara os valores informados pelo cliente (reported_*) com
+	as predições do ledger. Campos não informados (vazios) não são comparados
+*/
+func compareReported(record *TestRecord) []Discrepancy {
+	discrepancies := []Discrepancy{}
+
+	fields := []struct {
+		name      string
+		reported  string
+		predicted string
+	}{
+		{"acao_recomendada", record.ReportedAcaoRecomendada, record.AcaoRecomendada},
+		{"result_class", record.ReportedResultClass, record.ResultClass},
+		{"qc_status", record.ReportedQCStatus, record.QCStatus},
+	}
+
+	for _, field := range fields {
+		if field.reported != "" && field.reported != field.predicted {
+			discrepancies = append(discrepancies, Discrepancy{
+				Field:     field.name,
+				Reported:  field.reported,
+				Predicted: field.predicted,
+			})
+		}
+	}
+
+	return discrepancies
+}
+
+// Função que monta a chave do índice de testes com divergência por lote
+func discrepancyIndexKey(ctx contractapi.TransactionContextInterface, cassetteLot string, testID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(
+		"divergencia~lote~teste",
+		[]string{cassetteLot, testID},
+	)
+}
+
+/*
+	Função que registra as divergências de um teste recém-armazenado
+	Cria o índice "divergencia~lote~teste" e emite o evento TestDiscrepancy
+*/
+func recordDiscrepancies(ctx contractapi.TransactionContextInterface, record *TestRecord) error {
+	if len(record.Discrepancies) == 0 {
+		return nil
+	}
+
+	indexKey, err := discrepancyIndexKey(ctx, record.CassetteLot, record.TestID)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(indexKey, []byte{0x00}); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(discrepancyEventPayload{
+		TestID:        record.TestID,
+		CassetteLot:   record.CassetteLot,
+		Discrepancies: record.Discrepancies,
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent(discrepancyEvent, payload)
+}
+
+/*
+	Função que retorna os testes cuja predição diverge do resultado informado
+	pelo leitor/operador. Quando cassetteLot é vazio, retorna todos os lotes
+*/
+func (t *TestContract) GetDiscrepancies(ctx contractapi.TransactionContextInterface, cassetteLot string) ([]*TestRecord, error) {
+	attributes := []string{}
+	if cassetteLot != "" {
+		attributes = append(attributes, cassetteLot)
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(
+		"divergencia~lote~teste",
+		attributes,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	results := []*TestRecord{}
+
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, parts, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		test, err := getTestRecord(ctx, parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("erro ao carregar teste %s: %v", parts[1], err)
+		}
+
+		results = append(results, test)
+	}
+
+	return results, nil
+}
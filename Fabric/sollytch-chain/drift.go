@@ -0,0 +1,374 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Estatísticas de uma feature no conjunto de treino
+type FeatureStats struct {
+	Mean float64 `json:"mean"`
+	Std  float64 `json:"std"`
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+}
+
+// Estatísticas do conjunto de treino (ensaio_*.csv) gravadas com o modelo
+type TrainingStats struct {
+	Samples     int                     `json:"samples"`
+	Features    map[string]FeatureStats `json:"features"`
+	ClassPriors map[string]float64      `json:"class_priors"`
+
+	// Política de fora de distribuição (opcional): quando mais de
+	// MaxOODFeatures features estiverem fora da faixa do treino, o
+	// qc_status do teste é forçado para OODQCStatus
+	MaxOODFeatures int    `json:"max_ood_features,omitempty"`
+	OODQCStatus    string `json:"ood_qc_status,omitempty"`
+}
+
+/*
+	Agregados de drift de uma versão de modelo: contagem, soma e soma dos
+	quadrados de cada feature e a contagem de cada classe prevista.
+	Cada StoreTest grava apenas a parcela do próprio teste em uma chave com
+	o ID da transação, sem ler o agregado, para que testes concorrentes do
+	mesmo modelo não conflitem (MVCC). GetDriftReport soma as parcelas
+*/
+type DriftAggregate struct {
+	ModelKey     string                       `json:"model_key"`
+	ModelVersion int                          `json:"model_version"`
+	Samples      int                          `json:"samples"`
+	Features     map[string]*FeatureAggregate `json:"features"`
+	Classes      map[string]int               `json:"classes"`
+}
+
+// Agregado de uma feature recebida nos testes
+type FeatureAggregate struct {
+	Count           int     `json:"count"`
+	Sum             float64 `json:"sum"`
+	SumSquares      float64 `json:"sum_squares"`
+	Min             float64 `json:"min"`
+	Max             float64 `json:"max"`
+	OutOfRangeCount int     `json:"out_of_range_count"`
+}
+
+// Drift de uma feature em relação ao treino
+type FeatureDrift struct {
+	Feature         string  `json:"feature"`
+	Count           int     `json:"count"`
+	Mean            float64 `json:"mean"`
+	Std             float64 `json:"std"`
+	Min             float64 `json:"min"`
+	Max             float64 `json:"max"`
+	TrainingMean    float64 `json:"training_mean"`
+	TrainingStd     float64 `json:"training_std"`
+	DriftScore      float64 `json:"drift_score"`
+	OutOfRangeCount int     `json:"out_of_range_count"`
+}
+
+// Relatório de drift de uma versão de modelo
+type DriftReport struct {
+	ModelKey          string             `json:"model_key"`
+	ModelVersion      int                `json:"model_version"`
+	Samples           int                `json:"samples"`
+	Features          []FeatureDrift     `json:"features"`
+	ClassDistribution map[string]float64 `json:"class_distribution"`
+	ClassDrift        float64            `json:"class_drift"`
+}
+
+// Função que valida as estatísticas de treino recebidas com o modelo
+func parseTrainingStats(statsJSON string) (*TrainingStats, error) {
+	var stats TrainingStats
+	if err := json.Unmarshal([]byte(statsJSON), &stats); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar estatísticas de treino: %v", err)
+	}
+
+	columns := strings.Split(baseHeader, ",")
+	for _, column := range columns {
+		feature, ok := stats.Features[column]
+		if !ok {
+			return nil, fmt.Errorf("estatísticas de treino sem a feature %s", column)
+		}
+		if feature.Std < 0 || feature.Min > feature.Max {
+			return nil, fmt.Errorf("estatísticas invalidas para a feature %s", column)
+		}
+	}
+
+	if stats.MaxOODFeatures < 0 {
+		return nil, fmt.Errorf("max_ood_features não pode ser negativo")
+	}
+	if stats.MaxOODFeatures > 0 && stats.OODQCStatus == "" {
+		return nil, fmt.Errorf("ood_qc_status é obrigatório quando max_ood_features é informado")
+	}
+
+	return &stats, nil
+}
+
+/*
+	Função que converte a linha CSV de predição em um mapa feature -> valor
+	Valores não numéricos são ignorados
+*/
+func parseFeatureRow(csvRow string) map[string]float64 {
+	columns := strings.Split(baseHeader, ",")
+	values := strings.Split(csvRow, ",")
+
+	features := map[string]float64{}
+	for i, column := range columns {
+		if i >= len(values) {
+			break
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(values[i]), 64)
+		if err != nil {
+			continue
+		}
+		features[column] = value
+	}
+
+	return features
+}
+
+// Função que monta a chave da parcela de drift gravada por uma transação
+func driftDeltaKey(ctx contractapi.TransactionContextInterface, modelKey string, version int) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(
+		"drift~modelo~versao~txid",
+		[]string{modelKey, strconv.Itoa(version), ctx.GetStub().GetTxID()},
+	)
+}
+
+// Função que cria agregados de drift vazios de uma versão de modelo
+func newDriftAggregate(modelKey string, version int) *DriftAggregate {
+	return &DriftAggregate{
+		ModelKey:     modelKey,
+		ModelVersion: version,
+		Features:     map[string]*FeatureAggregate{},
+		Classes:      map[string]int{},
+	}
+}
+
+// Função que soma as parcelas gravadas pelos testes de uma versão de modelo (vazios se não houver)
+func getDriftAggregate(ctx contractapi.TransactionContextInterface, modelKey string, version int) (*DriftAggregate, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(
+		"drift~modelo~versao~txid",
+		[]string{modelKey, strconv.Itoa(version)},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	aggregate := newDriftAggregate(modelKey, version)
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var delta DriftAggregate
+		if err := json.Unmarshal(response.Value, &delta); err != nil {
+			return nil, fmt.Errorf("erro ao deserializar parcela de drift %s: %v", response.Key, err)
+		}
+		aggregate.merge(&delta)
+	}
+
+	return aggregate, nil
+}
+
+/*
+	Função que soma as features de um teste e a classe prevista aos
+	agregados. Valores fora da faixa [min, max] do treino são contados
+	quando a versão do modelo possui estatísticas de treino
+*/
+func (a *DriftAggregate) add(stats *TrainingStats, features map[string]float64, predictedClass string) {
+	a.Samples++
+	a.Classes[predictedClass]++
+
+	for column, value := range features {
+		feature, ok := a.Features[column]
+		if !ok {
+			feature = &FeatureAggregate{Min: value, Max: value}
+			a.Features[column] = feature
+		}
+
+		feature.Count++
+		feature.Sum += value
+		feature.SumSquares += value * value
+		feature.Min = math.Min(feature.Min, value)
+		feature.Max = math.Max(feature.Max, value)
+
+		if stats != nil {
+			if training, ok := stats.Features[column]; ok && (value < training.Min || value > training.Max) {
+				feature.OutOfRangeCount++
+			}
+		}
+	}
+}
+
+// Função que soma aos agregados a parcela gravada por outra transação
+func (a *DriftAggregate) merge(delta *DriftAggregate) {
+	a.Samples += delta.Samples
+	for class, count := range delta.Classes {
+		a.Classes[class] += count
+	}
+
+	for column, other := range delta.Features {
+		if other == nil || other.Count == 0 {
+			continue
+		}
+
+		feature, ok := a.Features[column]
+		if !ok {
+			feature = &FeatureAggregate{Min: other.Min, Max: other.Max}
+			a.Features[column] = feature
+		}
+
+		feature.Count += other.Count
+		feature.Sum += other.Sum
+		feature.SumSquares += other.SumSquares
+		feature.Min = math.Min(feature.Min, other.Min)
+		feature.Max = math.Max(feature.Max, other.Max)
+		feature.OutOfRangeCount += other.OutOfRangeCount
+	}
+}
+
+/*
+	Função que grava a parcela de drift do teste para cada modelo usado na
+	predição. predictions associa cada modelKey à classe prevista pelo
+	modelo. A parcela é gravada sem ler os agregados já existentes
+*/
+func updateDriftAggregates(ctx contractapi.TransactionContextInterface, predictStr string, predictions map[string]string) error {
+	features := parseFeatureRow(predictStr)
+
+	// Percorre os modelos em ordem fixa para manter a execução determinística
+	modelKeys := make([]string, 0, len(predictions))
+	for modelKey := range predictions {
+		modelKeys = append(modelKeys, modelKey)
+	}
+	sort.Strings(modelKeys)
+
+	for _, modelKey := range modelKeys {
+		model, err := getModel(ctx, modelKey)
+		if err != nil {
+			return err
+		}
+
+		key, err := driftDeltaKey(ctx, modelKey, model.Version)
+		if err != nil {
+			return err
+		}
+
+		aggregate := newDriftAggregate(modelKey, model.Version)
+		aggregate.add(model.TrainingStats, features, predictions[modelKey])
+
+		bytes, err := json.Marshal(aggregate)
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.GetStub().PutState(key, bytes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+	Função que calcula o relatório de drift a partir das estatísticas de
+	treino e dos agregados dos testes recebidos. O drift_score de cada
+	feature é a diferença entre as médias em desvios-padrão do treino (ou a
+	diferença absoluta, quando o desvio do treino é zero). class_drift é a
+	distância de variação total entre as classes previstas e as priors do treino
+*/
+func computeDriftReport(stats *TrainingStats, aggregate *DriftAggregate) *DriftReport {
+	report := &DriftReport{
+		ModelKey:          aggregate.ModelKey,
+		ModelVersion:      aggregate.ModelVersion,
+		Samples:           aggregate.Samples,
+		Features:          []FeatureDrift{},
+		ClassDistribution: map[string]float64{},
+	}
+
+	columns := strings.Split(baseHeader, ",")
+	for _, column := range columns {
+		training := stats.Features[column]
+		drift := FeatureDrift{
+			Feature:      column,
+			TrainingMean: training.Mean,
+			TrainingStd:  training.Std,
+		}
+
+		if feature, ok := aggregate.Features[column]; ok && feature.Count > 0 {
+			count := float64(feature.Count)
+			drift.Count = feature.Count
+			drift.Min = feature.Min
+			drift.Max = feature.Max
+			drift.OutOfRangeCount = feature.OutOfRangeCount
+			drift.Mean = feature.Sum / count
+
+			// Variância populacional; o máximo com zero absorve o erro de arredondamento
+			drift.Std = math.Sqrt(math.Max(feature.SumSquares/count-drift.Mean*drift.Mean, 0))
+
+			diff := math.Abs(drift.Mean - training.Mean)
+			if training.Std > 0 {
+				drift.DriftScore = diff / training.Std
+			} else {
+				drift.DriftScore = diff
+			}
+		}
+
+		report.Features = append(report.Features, drift)
+	}
+
+	if aggregate.Samples == 0 {
+		return report
+	}
+
+	// Distribuição das classes previstas
+	for class, count := range aggregate.Classes {
+		report.ClassDistribution[class] = float64(count) / float64(aggregate.Samples)
+	}
+
+	// Distância de variação total entre previstas e priors do treino
+	classes := map[string]bool{}
+	for class := range report.ClassDistribution {
+		classes[class] = true
+	}
+	for class := range stats.ClassPriors {
+		classes[class] = true
+	}
+	for class := range classes {
+		report.ClassDrift += math.Abs(report.ClassDistribution[class] - stats.ClassPriors[class])
+	}
+	report.ClassDrift /= 2
+
+	return report
+}
+
+/*
+	Função que retorna o relatório de drift da versão ativa de um modelo,
+	comparando os agregados das features recebidas nos testes com as
+	estatísticas de treino gravadas junto ao modelo. Percorre as parcelas
+	gravadas por cada StoreTest da versão
+*/
+func (m *ModelContract) GetDriftReport(ctx contractapi.TransactionContextInterface, modelKey string) (*DriftReport, error) {
+	model, err := getModel(ctx, modelKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if model.TrainingStats == nil {
+		return nil, fmt.Errorf("modelo %s versão %d não possui estatísticas de treino", modelKey, model.Version)
+	}
+
+	aggregate, err := getDriftAggregate(ctx, modelKey, model.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	return computeDriftReport(model.TrainingStats, aggregate), nil
+}
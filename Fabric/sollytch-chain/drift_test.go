@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+func trainingStatsFixture() *TrainingStats {
+	stats := &TrainingStats{
+		Samples:     100,
+		Features:    map[string]FeatureStats{},
+		ClassPriors: map[string]float64{"negativo": 0.75, "positivo": 0.25},
+	}
+	for _, column := range strings.Split(baseHeader, ",") {
+		stats.Features[column] = FeatureStats{Mean: 10, Std: 2, Min: 5, Max: 15}
+	}
+	return stats
+}
+
+func TestComputeDriftReport(t *testing.T) {
+	stats := trainingStatsFixture()
+	aggregate := &DriftAggregate{
+		ModelKey:     "result_class",
+		ModelVersion: 1,
+		Features:     map[string]*FeatureAggregate{},
+		Classes:      map[string]int{},
+	}
+	aggregate.add(stats, map[string]float64{"sample_pH": 12}, "positivo")
+	aggregate.add(stats, map[string]float64{"sample_pH": 16}, "positivo")
+
+	// Os agregados sobrevivem à serialização no ledger
+	bytes, err := json.Marshal(aggregate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored DriftAggregate
+	if err := json.Unmarshal(bytes, &stored); err != nil {
+		t.Fatal(err)
+	}
+
+	report := computeDriftReport(stats, &stored)
+
+	if report.Samples != 2 {
+		t.Fatalf("expected 2 samples, got %d", report.Samples)
+	}
+
+	var ph *FeatureDrift
+	for i := range report.Features {
+		if report.Features[i].Feature == "sample_pH" {
+			ph = &report.Features[i]
+		}
+	}
+	if ph == nil {
+		t.Fatal("sample_pH missing from report")
+	}
+
+	if ph.Count != 2 || ph.Mean != 14 || ph.Std != 2 || ph.Min != 12 || ph.Max != 16 {
+		t.Errorf("unexpected running statistics: %+v", ph)
+	}
+	if ph.DriftScore != 2 {
+		t.Errorf("expected drift score 2, got %f", ph.DriftScore)
+	}
+	if ph.OutOfRangeCount != 1 {
+		t.Errorf("expected 1 out-of-range value, got %d", ph.OutOfRangeCount)
+	}
+
+	// All predictions are "positivo": |1-0.25| + |0-0.75| over 2
+	if math.Abs(report.ClassDrift-0.75) > 1e-9 {
+		t.Errorf("expected class drift 0.75, got %f", report.ClassDrift)
+	}
+}
+
+func TestDriftReportFoldsPerTransactionDeltas(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-chain", nil)
+	ctx := newTestContext(stub, newIdentity("Org1MSP", "reader", nil))
+	contract := new(ModelContract)
+
+	putModel := func(version int) {
+		bytes, err := json.Marshal(ModelBytes{ModelKey: "result_class", Version: version, TrainingStats: trainingStatsFixture()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustTx(t, stub, func() error { return stub.PutState("result_class", bytes) })
+	}
+	row := func(ph string) string {
+		features := make([]string, len(strings.Split(baseHeader, ",")))
+		for i, column := range strings.Split(baseHeader, ",") {
+			features[i] = "?"
+			if column == "sample_pH" {
+				features[i] = ph
+			}
+		}
+		return strings.Join(features, ",")
+	}
+	// Each StoreTest is a separate transaction with its own ID
+	record := func(txID string, ph string, class string) {
+		stub.MockTransactionStart(txID)
+		defer stub.MockTransactionEnd(txID)
+		if err := updateDriftAggregates(ctx, row(ph), map[string]string{"result_class": class}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	putModel(1)
+	record("tx1", "12", "positivo")
+	record("tx2", "16", "positivo")
+
+	deltas, err := stub.GetStateByPartialCompositeKey("drift~modelo~versao~txid", []string{"result_class", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for deltas.HasNext() {
+		if _, err := deltas.Next(); err != nil {
+			t.Fatal(err)
+		}
+		count++
+	}
+	deltas.Close()
+	if count != 2 {
+		t.Fatalf("expected one delta key per transaction, got %d", count)
+	}
+
+	// Tests of another version are not counted
+	putModel(2)
+	record("tx3", "30", "negativo")
+	putModel(1)
+
+	report, err := contract.GetDriftReport(ctx, "result_class")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Samples != 2 || report.ClassDistribution["positivo"] != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	for _, feature := range report.Features {
+		if feature.Feature == "sample_pH" && (feature.Count != 2 || feature.Mean != 14 || feature.Min != 12 || feature.Max != 16 || feature.OutOfRangeCount != 1) {
+			t.Errorf("unexpected sample_pH drift: %+v", feature)
+		}
+	}
+}
+
+func TestParseFeatureRowSkipsNonNumeric(t *testing.T) {
+	row := "-22.8,-43.2,42,24.87,466.3,66.2,6.79,3.1,26.3,19.6,80.8,308.2,0.1,27.2,23,?,9.76,31.76,2.82,1,0"
+
+	features := parseFeatureRow(row)
+	if _, ok := features["image_blur_score"]; ok {
+		t.Error("expected image_blur_score to be skipped")
+	}
+	if features["sample_pH"] != 6.79 {
+		t.Errorf("expected sample_pH 6.79, got %f", features["sample_pH"])
+	}
+}
+
+func TestOutOfDistributionFeatures(t *testing.T) {
+	features := map[string]float64{
+		"sample_turbidity_NTU": 150,
+		"sample_pH":            7,
+		"lighting_lux":         1,
+	}
+
+	offending := outOfDistributionFeatures(trainingStatsFixture(), features)
+
+	// Ordered as in baseHeader
+	expected := []string{"sample_turbidity_NTU", "lighting_lux"}
+	if strings.Join(offending, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, offending)
+	}
+}
+
+func TestForcedQCStatusIsNotADiscrepancy(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-chain", nil)
+	ctx := newTestContext(stub, newIdentity("Org1MSP", "reader", nil))
+
+	stats := trainingStatsFixture()
+	stats.MaxOODFeatures = 1
+	stats.OODQCStatus = "revisar"
+	for _, modelKey := range oodModelKeys {
+		bytes, err := json.Marshal(ModelBytes{ModelKey: modelKey, Version: 1, TrainingStats: stats})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustTx(t, stub, func() error { return stub.PutState(modelKey, bytes) })
+	}
+
+	// The reader agrees with the model; two features exceed the policy limit
+	record := &TestRecord{
+		TestID:           "TEST-00001",
+		QCStatus:         "ok",
+		ReportedQCStatus: "ok",
+	}
+	features := make([]string, len(strings.Split(baseHeader, ",")))
+	for i := range features {
+		features[i] = "10"
+	}
+	features[0], features[1] = "100", "100"
+
+	mustTx(t, stub, func() error { return checkPredictions(ctx, record, strings.Join(features, ",")) })
+
+	if record.QCStatus != "revisar" || !record.QCStatusForced {
+		t.Errorf("expected qc_status forced to revisar, got %s (forced %v)", record.QCStatus, record.QCStatusForced)
+	}
+	if record.HasDiscrepancy || len(record.Discrepancies) != 0 {
+		t.Errorf("expected no discrepancy for a forced qc_status, got %+v", record.Discrepancies)
+	}
+}
+
+func TestStoreTestUpdatesDriftReport(t *testing.T) {
+	f := newStoreTestFixture(t)
+
+	f.mustStore(t, "TEST-00001", storeTestJSON(t, "TEST-00001", nil))
+	f.mustStore(t, "TEST-00002", storeTestJSON(t, "TEST-00002", map[string]interface{}{"sample_pH": 8.79}))
+	// Rejected tests do not reach the drift aggregates
+	f.assertRejected(t, "TEST-00003", storeTestJSON(t, "TEST-00003", map[string]interface{}{"device_id": "DEV-999"}), "DEV-999")
+
+	for _, modelKey := range []string{"acao_recomendada", "result_class", "qc_status"} {
+		deltas, err := f.stub.GetStateByPartialCompositeKey("drift~modelo~versao~txid", []string{modelKey})
+		if err != nil {
+			t.Fatal(err)
+		}
+		txIDs := []string{}
+		for deltas.HasNext() {
+			response, err := deltas.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, parts, err := f.stub.SplitCompositeKey(response.Key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			txIDs = append(txIDs, parts[2])
+		}
+		deltas.Close()
+		if strings.Join(txIDs, ",") != "TEST-00001,TEST-00002" {
+			t.Errorf("expected one delta per stored test for %s, got %v", modelKey, txIDs)
+		}
+
+		report, err := new(ModelContract).GetDriftReport(f.ctx, modelKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Samples != 2 {
+			t.Errorf("expected 2 samples for %s, got %d", modelKey, report.Samples)
+		}
+		for _, feature := range report.Features {
+			if feature.Feature == "sample_pH" && (feature.Count != 2 || math.Abs(feature.Mean-7.79) > 1e-9) {
+				t.Errorf("unexpected sample_pH drift for %s: %+v", modelKey, feature)
+			}
+		}
+	}
+}
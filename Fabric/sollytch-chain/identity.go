@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Atributo do certificado (Fabric CA) que define o papel do cliente
+const roleAttribute = "sollytch.role"
+
+// Papel exigido para registrar leitores e operadores e migrar testes
+const adminRole = "admin"
+
+/*
+	Função que verifica se o cliente que submeteu a transação possui
+	o papel informado no atributo "sollytch.role" do seu certificado
+*/
+func requireRole(ctx contractapi.TransactionContextInterface, role string) error {
+	if err := ctx.GetClientIdentity().AssertAttributeValue(roleAttribute, role); err != nil {
+		return fmt.Errorf("acesso negado: cliente não possui o papel %s", role)
+	}
+
+	return nil
+}
+
+// Função que retorna o MSP ID da organização do cliente que submeteu a transação
+func callerMSP(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("erro ao obter MSP do cliente: %v", err)
+	}
+
+	return mspID, nil
+}
+
+/*
+	Função que identifica o cliente que submeteu a transação no formato
+	"MSP:ID", onde ID é o identificador único do certificado do cliente
+*/
+func callerID(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := callerMSP(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("erro ao obter identidade do cliente: %v", err)
+	}
+
+	return mspID + ":" + clientID, nil
+}
+
+// Função que retorna o timestamp da transação atual em UTC
+func txTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
+}
+
+/*
+	Função que interpreta datas recebidas dos clientes
+	Aceita tanto o formato RFC3339 quanto apenas a data (AAAA-MM-DD)
+*/
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("data invalida %q: use AAAA-MM-DD ou RFC3339", value)
+	}
+
+	return t.UTC(), nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Identidade de cliente usada nos testes no lugar do certificado real
+type fakeIdentity struct {
+	mspID string
+	id    string
+	attrs map[string]string
+}
+
+func (f *fakeIdentity) GetID() (string, error) { return f.id, nil }
+
+func (f *fakeIdentity) GetMSPID() (string, error) { return f.mspID, nil }
+
+func (f *fakeIdentity) GetAttributeValue(attr string) (string, bool, error) {
+	value, ok := f.attrs[attr]
+	return value, ok, nil
+}
+
+func (f *fakeIdentity) AssertAttributeValue(attr string, value string) error {
+	if actual, ok := f.attrs[attr]; !ok || actual != value {
+		return fmt.Errorf("attribute %s is not %s", attr, value)
+	}
+	return nil
+}
+
+func (f *fakeIdentity) GetX509Certificate() (*x509.Certificate, error) { return nil, nil }
+
+func newIdentity(mspID string, id string, attrs map[string]string) *fakeIdentity {
+	if attrs == nil {
+		attrs = map[string]string{}
+	}
+	return &fakeIdentity{mspID: mspID, id: id, attrs: attrs}
+}
+
+// Contexto de transação sobre um MockStub com a identidade informada
+func newTestContext(stub *shimtest.MockStub, identity *fakeIdentity) *contractapi.TransactionContext {
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(stub)
+	ctx.SetClientIdentity(identity)
+	return ctx
+}
+
+// Executa fn dentro de uma transação do MockStub, como o peer faria
+func inTx(t *testing.T, stub *shimtest.MockStub, fn func() error) error {
+	t.Helper()
+	stub.MockTransactionStart(t.Name())
+	defer stub.MockTransactionEnd(t.Name())
+	return fn()
+}
+
+// Como inTx, com o timestamp da transação fixado em at
+func inTxAt(t *testing.T, stub *shimtest.MockStub, at time.Time, fn func() error) error {
+	t.Helper()
+	stub.MockTransactionStart(t.Name())
+	stub.TxTimestamp = timestamppb.New(at)
+	defer stub.MockTransactionEnd(t.Name())
+	return fn()
+}
+
+// Como inTx, mas falha o teste em caso de erro
+func mustTx(t *testing.T, stub *shimtest.MockStub, fn func() error) {
+	t.Helper()
+	if err := inTx(t, stub, fn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCallerIDAndRole(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-chain", nil)
+	ctx := newTestContext(stub, newIdentity("Org1MSP", "alice", map[string]string{roleAttribute: reviewerRole}))
+
+	id, err := callerID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "Org1MSP:alice" {
+		t.Errorf("expected Org1MSP:alice, got %s", id)
+	}
+
+	if err := requireRole(ctx, reviewerRole); err != nil {
+		t.Errorf("expected reviewer role to be accepted: %v", err)
+	}
+	if err := requireRole(ctx, certifierRole); err == nil {
+		t.Error("expected missing certifier role to be rejected")
+	}
+}
@@ -6,29 +6,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
 	"github.com/sjwhitworth/golearn/base"
 	"github.com/sjwhitworth/golearn/trees"
 )
 
-type NullFloat64 float64
-
-func (nf *NullFloat64) UnmarshalJSON(b []byte) error {
-	if string(b) == "null" {
-		*nf = 0
-		return nil
-	}
-	var f float64
-	if err := json.Unmarshal(b, &f); err != nil {
-		return err
-	}
-	*nf = NullFloat64(f)
-	return nil
-}
-
 // struct json dos modelos de machine learning
 type ModelBytes struct {
 	//trackers
@@ -40,6 +27,15 @@ type ModelBytes struct {
 
 	//conteudo
 	ModelData  string `json:"modelData"`
+
+	//estatisticas do conjunto de treino, usadas no monitoramento de drift
+	TrainingStats *TrainingStats `json:"trainingStats,omitempty"`
+
+	//modelos enviados em partes (ver modelUpload.go)
+	Chunked    bool   `json:"chunked,omitempty"`
+	UploadID   string `json:"uploadId,omitempty"`
+	ChunkCount int    `json:"chunkCount,omitempty"`
+	SHA256     string `json:"sha256,omitempty"`
 }
 
 // struct json do hash da planilha
@@ -60,6 +56,7 @@ type LoteRecord struct{
 type TestRecord struct {
 	//trackers
 	Version 		          int         `json:"version"`
+	SchemaVersion             int         `json:"schema_version"`
 	LastUpdatedAt             string      `json:"last_updated_at"`
 	CreatedAt                 string      `json:"created_at"`
 
@@ -69,31 +66,35 @@ type TestRecord struct {
 
 	//conteudo
 	Timestamp                 string      `json:"timestamp"`
-	Lat                       float64     `json:"lat"`
-	Lon                       float64     `json:"lon"`
+	Lat                       NullFloat64 `json:"lat"`
+	Lon                       NullFloat64 `json:"lon"`
 	GeoHash                   string      `json:"geo_hash"`
 	OperatorID                string      `json:"operator_id"`
 	OperatorDID               string      `json:"operator_did"`
 	MatrixType                string      `json:"matrix_type"`
 	ReagentLot                string      `json:"reagent_lot"`
 	ExpiryDaysLeft            int         `json:"expiry_days_left"`
-	DistanceMM                float64     `json:"distance_mm"`
-	TimeToMigrateS            float64     `json:"time_to_migrate_s"`
-	ControlLineOK             bool        `json:"control_line_ok"`
-	SampleVolumeUL            float64     `json:"sample_volume_uL"`
-	SamplePH                  float64     `json:"sample_pH"`
-	SampleTurbidityNTU        float64     `json:"sample_turbidity_NTU"`
-	SampleTempC               float64     `json:"sample_temp_C"`
-	AmbientTC                 float64     `json:"ambient_T_C"`
-	AmbientRHPct              float64     `json:"ambient_RH_pct"`
-	LightingLux               float64     `json:"lighting_lux"`
-	TiltDeg                   float64     `json:"tilt_deg"`
-	PreincubationTimeS        float64     `json:"preincubation_time_s"`
-	TimeSinceSamplingMin      float64     `json:"time_since_sampling_min"`
+	ReagentExpired            bool        `json:"reagent_expired"`
+	DistanceMM                NullFloat64 `json:"distance_mm"`
+	TimeToMigrateS            NullFloat64 `json:"time_to_migrate_s"`
+	ControlLineOK             NullBool    `json:"control_line_ok"`
+	SampleVolumeUL            NullFloat64 `json:"sample_volume_uL"`
+	SamplePH                  NullFloat64 `json:"sample_pH"`
+	SampleTurbidityNTU        NullFloat64 `json:"sample_turbidity_NTU"`
+	SampleTempC               NullFloat64 `json:"sample_temp_C"`
+	AmbientTC                 NullFloat64 `json:"ambient_T_C"`
+	AmbientRHPct              NullFloat64 `json:"ambient_RH_pct"`
+	LightingLux               NullFloat64 `json:"lighting_lux"`
+	TiltDeg                   NullFloat64 `json:"tilt_deg"`
+	PreincubationTimeS        NullFloat64 `json:"preincubation_time_s"`
+	TimeSinceSamplingMin      NullFloat64 `json:"time_since_sampling_min"`
 	StorageCondition          string      `json:"storage_condition"`
-	PrefilterUsed             bool        `json:"prefilter_used"`
-	ImageTaken                bool        `json:"image_taken"`
+	PrefilterUsed             NullBool    `json:"prefilter_used"`
+	ImageTaken                NullBool    `json:"image_taken"`
+	// Informado pelo cliente: StoreTest não confere este valor com a
+	// imagem registrada no sollytch-image (ver blurScore da ccapi)
 	ImageBlurScore            NullFloat64 `json:"image_blur_score"`
+	DeviceID                  string      `json:"device_id"`
 	DeviceFWVersion           string      `json:"device_fw_version"`
 	ProdutoID                 string      `json:"produto_id"`
 	KitCalibrationID          string      `json:"kit_calibration_id"`
@@ -101,15 +102,39 @@ type TestRecord struct {
 	CadeiaFrioStatus          bool        `json:"cadeia_frio_status"`
 	TempoTransporteHoras      float64     `json:"tempo_transporte_horas"`
 	CondicaoTransporte        string      `json:"condicao_transporte"`
+	ShipmentID                string      `json:"shipment_id"`
 	EstimatedConcentrationPpb float64     `json:"estimated_concentration_ppb"`
 	IncertezaEstimativaPpb    float64     `json:"incerteza_estimativa_ppb"`
+	ConcentrationOutOfRange   bool        `json:"concentration_out_of_range"`
 	AcaoRecomendada           string      `json:"acao_recomendada"`
 	ResultClass               string      `json:"result_class"`
 	QCStatus                  string      `json:"qc_status"`
-}
 
-type SmartContract struct {
-	contractapi.Contract
+	//fora de distribuição em relação ao treino dos modelos
+	OutOfDistribution         bool          `json:"out_of_distribution"`
+	OODFeatures               []string      `json:"ood_features"`
+	QCStatusForced            bool          `json:"qc_status_forced"`
+
+	//features opcionais ausentes preenchidas antes da predição
+	ImputedFeatures           []string      `json:"imputed_features"`
+
+	//resultados informados pelo cliente e divergências com a predição
+	ReportedAcaoRecomendada   string        `json:"reported_acao_recomendada"`
+	ReportedResultClass       string        `json:"reported_result_class"`
+	ReportedQCStatus          string        `json:"reported_qc_status"`
+	HasDiscrepancy            bool          `json:"has_discrepancy"`
+	Discrepancies             []Discrepancy `json:"discrepancies"`
+
+	//revisão
+	ReviewStatus              string        `json:"review_status"`
+	Reviews                   []ReviewEntry `json:"reviews"`
+	SubmittedBy               string        `json:"submitted_by"`
+	LastUpdatedBy             string        `json:"last_updated_by"`
+
+	//assinatura do payload
+	Signature                 string      `json:"signature"`
+	SignatureKeyID            string      `json:"signature_key_id"`
+	SignerType                string      `json:"signer_type"`
 }
 
 /*
@@ -117,7 +142,7 @@ type SmartContract struct {
 	Utiliza o hash da planilha como chave principal (state key) e o lote (casseteLot)
 	como parte de uma chave composta para indexação e busca
 */
-func (c *SmartContract) StorePlanilha(ctx contractapi.TransactionContextInterface, casseteLot string, hashPlanilha string) error {
+func (c *PlanilhaContract) StorePlanilha(ctx contractapi.TransactionContextInterface, casseteLot string, hashPlanilha string) error {
 	// Valida se os parâmetros obrigatórios foram informados
 	if casseteLot == "" || hashPlanilha == "" {
 		return fmt.Errorf("casseteLot e hashPlanilha são obrigatórios")
@@ -139,7 +164,7 @@ func (c *SmartContract) StorePlanilha(ctx contractapi.TransactionContextInterfac
 	).UTC().Format(time.RFC3339)
 	
 	// Verifica se já existe um registro para esse hash no ledger
-	exists, err := c.PlanilhaExists(ctx, planilhaKey)
+	exists, err := c.planilhaExists(ctx, planilhaKey)
 	if err != nil {
 		return err
 	}
@@ -205,7 +230,7 @@ func (c *SmartContract) StorePlanilha(ctx contractapi.TransactionContextInterfac
 	Recebe o número do lote (casseteLot) e, a partir da chave composta
 	"lote~planilha", localiza todos os hashes vinculados a esse lote
 */
-func (c *SmartContract) GetPlanilhasByLote(ctx contractapi.TransactionContextInterface, casseteLot string) ([]*LoteRecord, error) {
+func (c *PlanilhaContract) GetPlanilhasByLote(ctx contractapi.TransactionContextInterface, casseteLot string) ([]*LoteRecord, error) {
 	// Valida se o número do lote foi informado
 	if casseteLot == "" {
 		return nil, fmt.Errorf("casseteLot não pode ser vazio")
@@ -261,7 +286,7 @@ func (c *SmartContract) GetPlanilhasByLote(ctx contractapi.TransactionContextInt
 	Busca diretamente no ledger pela chave principal (hashPlanilha)
 	e retorna um único objeto LoteRecord
 */
-func (c *SmartContract) GetPlanilhaByHash(ctx contractapi.TransactionContextInterface, hashPlanilha string) (*LoteRecord, error) {
+func (c *PlanilhaContract) GetPlanilhaByHash(ctx contractapi.TransactionContextInterface, hashPlanilha string) (*LoteRecord, error) {
 	// Valida se o hash foi informado
 	if hashPlanilha == "" {
 		return nil, fmt.Errorf("hashPlanilha não pode ser vazio")
@@ -290,7 +315,7 @@ func (c *SmartContract) GetPlanilhaByHash(ctx contractapi.TransactionContextInte
 	Função que verifica se já existe um registro de planilha no ledger
 	Recebe a chave principal (hash da planilha) e retorna true caso exista
 */
-func (c *SmartContract) PlanilhaExists(ctx contractapi.TransactionContextInterface, planilhaKey string) (bool, error) {
+func (c *PlanilhaContract) planilhaExists(ctx contractapi.TransactionContextInterface, planilhaKey string) (bool, error) {
 	// Consulta o estado no ledger
 	data, err := ctx.GetStub().GetState(planilhaKey)
 	if err != nil {
@@ -304,41 +329,36 @@ func (c *SmartContract) PlanilhaExists(ctx contractapi.TransactionContextInterfa
 /*
 	Função responsável por armazenar ou atualizar um modelo de Machine Learning no ledger
 	Armazena os bytes do modelo (em Base64), controla versionamento e registra
-	a data de atualização para uso posterior em predições
+	a data de atualização para uso posterior em predições. trainingStatsJSON
+	(opcional) traz as estatísticas do conjunto de treino usadas em GetDriftReport
 */
-func (s *SmartContract) StoreModel(ctx contractapi.TransactionContextInterface, modelKey string, modelBase64 string) error {
+func (m *ModelContract) StoreModel(ctx contractapi.TransactionContextInterface, modelKey string, modelBase64 string, trainingStatsJSON string) error {
 	// Valida se os parâmetros obrigatórios foram informados
 	if modelKey == "" || modelBase64 == "" {
 		return fmt.Errorf("modelKey e modelData nao podem ser vazios")
 	}
 
 	// Permite apenas chaves de modelo previamente definidas
-	switch modelKey {
-	case "acao_recomendada", "result_class", "qc_status":
-		// Chaves válidas
-	default:
-		return fmt.Errorf("modelKey invalido")
+	if err := validateModelKey(modelKey); err != nil {
+		return err
 	}
 
-	stub := ctx.GetStub()
-
-	// Verifica se já existe um modelo armazenado com essa chave
-	existingBytes, err := stub.GetState(modelKey)
-	if err != nil {
-		return fmt.Errorf("erro ao buscar modelo existente: %v", err)
+	// Valida as estatísticas de treino, quando informadas
+	var trainingStats *TrainingStats
+	if trainingStatsJSON != "" {
+		stats, err := parseTrainingStats(trainingStatsJSON)
+		if err != nil {
+			return err
+		}
+		trainingStats = stats
 	}
 
-	// Define versão inicial como 1
-	version := 1
+	stub := ctx.GetStub()
 
-	// Caso já exista, incrementa a versão com base na anterior
-	if existingBytes != nil {
-		var existingModel ModelBytes
-		err = json.Unmarshal(existingBytes, &existingModel)
-		if err != nil {
-			return fmt.Errorf("erro ao decodificar modelo existente: %v", err)
-		}
-		version = existingModel.Version + 1
+	// Define a versão do novo modelo com base na anterior
+	version, err := nextModelVersion(ctx, modelKey)
+	if err != nil {
+		return err
 	}
 
 	// Obtém o timestamp da transação atual
@@ -349,9 +369,10 @@ func (s *SmartContract) StoreModel(ctx contractapi.TransactionContextInterface,
     
 	// Cria a estrutura do modelo com versionamento e data de atualização
 	model := ModelBytes{
-		ModelKey:  modelKey,
-		ModelData: modelBase64,
-		Version:   version,
+		ModelKey:      modelKey,
+		ModelData:     modelBase64,
+		Version:       version,
+		TrainingStats: trainingStats,
 		UpdatedAt: time.Unix(
 			txTime.Seconds,
 			int64(txTime.Nanos),
@@ -368,12 +389,45 @@ func (s *SmartContract) StoreModel(ctx contractapi.TransactionContextInterface,
 	return stub.PutState(modelKey, bytes)
 }
 
+// Função que valida se a chave de modelo é uma das previamente definidas
+func validateModelKey(modelKey string) error {
+	switch modelKey {
+	case "acao_recomendada", "result_class", "qc_status":
+		// Chaves válidas
+		return nil
+	default:
+		return fmt.Errorf("modelKey invalido")
+	}
+}
+
 /*
-	Função que recupera os bytes de um modelo armazenado no ledger
-	Busca pelo modelKey, desserializa a estrutura ModelBytes e
-	decodifica o conteúdo Base64 para retornar os bytes originais do modelo
+	Função que calcula a próxima versão de um modelo
+	A versão inicial é 1; caso já exista, incrementa a versão anterior
 */
-func (s *SmartContract) getModelBytes(ctx contractapi.TransactionContextInterface, modelKey string) ([]byte, error) {
+func nextModelVersion(ctx contractapi.TransactionContextInterface, modelKey string) (int, error) {
+	// Verifica se já existe um modelo armazenado com essa chave
+	existingBytes, err := ctx.GetStub().GetState(modelKey)
+	if err != nil {
+		return 0, fmt.Errorf("erro ao buscar modelo existente: %v", err)
+	}
+
+	if existingBytes == nil {
+		return 1, nil
+	}
+
+	var existingModel ModelBytes
+	if err := json.Unmarshal(existingBytes, &existingModel); err != nil {
+		return 0, fmt.Errorf("erro ao decodificar modelo existente: %v", err)
+	}
+
+	return existingModel.Version + 1, nil
+}
+
+/*
+	Função que recupera o registro de um modelo armazenado no ledger
+	Busca pelo modelKey e desserializa a estrutura ModelBytes
+*/
+func getModel(ctx contractapi.TransactionContextInterface, modelKey string) (*ModelBytes, error) {
 	// Consulta o modelo no ledger pela chave
 	data, err := ctx.GetStub().GetState(modelKey)
 	if err != nil {
@@ -389,6 +443,27 @@ func (s *SmartContract) getModelBytes(ctx contractapi.TransactionContextInterfac
 		return nil, err
 	}
 
+	return &stored, nil
+}
+
+/*
+	Função que recupera os bytes de um modelo armazenado no ledger
+	Busca pelo modelKey, desserializa a estrutura ModelBytes e
+	decodifica o conteúdo Base64 para retornar os bytes originais do modelo.
+	Modelos enviados em partes são remontados a partir dos chunks em toda
+	leitura, conferindo o SHA-256 gravado na finalização, para que o
+	conjunto de leitura seja o mesmo em todos os peers
+*/
+func getModelBytes(ctx contractapi.TransactionContextInterface, modelKey string) ([]byte, error) {
+	stored, err := getModel(ctx, modelKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if stored.Chunked {
+		return assembleModelChunks(ctx, stored.UploadID, stored.ChunkCount, stored.SHA256)
+	}
+
 	// Decodifica o conteúdo Base64 para bytes binários originais
 	return base64.StdEncoding.DecodeString(stored.ModelData)
 }
@@ -399,9 +474,9 @@ func (s *SmartContract) getModelBytes(ctx contractapi.TransactionContextInterfac
 	de arquivos e utiliza o método Load para reconstruir o modelo
 	em memória para uso em predições
 */
-func loadID3ModelFromLedger(ctx contractapi.TransactionContextInterface, s *SmartContract, modelKey string) (*trees.ID3DecisionTree, error) {
+func loadID3ModelFromLedger(ctx contractapi.TransactionContextInterface, modelKey string) (*trees.ID3DecisionTree, error) {
 	// Obtém os bytes do modelo armazenado
-	bytes, err := s.getModelBytes(ctx, modelKey)
+	bytes, err := getModelBytes(ctx, modelKey)
 	if err != nil {
 		return nil, err
 	}
@@ -450,6 +525,81 @@ var baseHeader =
 		"tempo_transporte_horas,estimated_concentration_ppb," +
 		"incerteza_estimativa_ppb,control_line_ok,controle_interno_result"
 
+// Marcador de valor ausente na linha de predição
+const missingValue = "?"
+
+// Codificação de controle_interno_result usada no treino dos modelos
+var controleInternoEncoder = map[string]int{
+	"ok":      2,
+	"fail":    1,
+	"invalid": 0,
+}
+
+/*
+	Função que monta a linha CSV de predição, na ordem do baseHeader, a
+	partir do registro já validado no ledger. Campos opcionais ausentes
+	(null) são marcados com "?" em vez de virarem 0
+*/
+func buildPredictRow(record *TestRecord) string {
+	// Valores desconhecidos de controle_interno_result seguem o padrão do treino (0)
+	controle := controleInternoEncoder[record.ControleInternoResult]
+
+	values := []string{
+		record.Lat.predictValue(),
+		record.Lon.predictValue(),
+		strconv.Itoa(record.ExpiryDaysLeft),
+		record.DistanceMM.predictValue(),
+		record.TimeToMigrateS.predictValue(),
+		record.SampleVolumeUL.predictValue(),
+		record.SamplePH.predictValue(),
+		record.SampleTurbidityNTU.predictValue(),
+		record.SampleTempC.predictValue(),
+		record.AmbientTC.predictValue(),
+		record.AmbientRHPct.predictValue(),
+		record.LightingLux.predictValue(),
+		record.TiltDeg.predictValue(),
+		record.PreincubationTimeS.predictValue(),
+		record.TimeSinceSamplingMin.predictValue(),
+		record.ImageBlurScore.predictValue(),
+		strconv.FormatFloat(record.TempoTransporteHoras, 'f', -1, 64),
+		strconv.FormatFloat(record.EstimatedConcentrationPpb, 'f', -1, 64),
+		strconv.FormatFloat(record.IncertezaEstimativaPpb, 'f', -1, 64),
+		record.ControlLineOK.predictValue(),
+		strconv.Itoa(controle),
+	}
+
+	return strings.Join(values, ",")
+}
+
+/*
+	Função que substitui os valores ausentes da linha de predição, já que
+	os modelos ID3 não aceitam "?" nas features. Usa a média de treino da
+	feature (estatísticas do primeiro modelo que as possuir) ou 0, o mesmo
+	preenchimento usado pelo cliente antes da predição on-chain.
+	Retorna a linha preenchida e as features imputadas
+*/
+func imputePredictRow(stats *TrainingStats, csvRow string) (string, []string) {
+	columns := strings.Split(baseHeader, ",")
+	values := strings.Split(csvRow, ",")
+	imputed := []string{}
+
+	for i, column := range columns {
+		if i >= len(values) || values[i] != missingValue {
+			continue
+		}
+
+		fill := 0.0
+		if stats != nil {
+			fill = stats.Features[column].Mean
+		}
+
+		values[i] = strconv.FormatFloat(fill, 'f', -1, 64)
+		imputed = append(imputed, column)
+	}
+
+	return strings.Join(values, ","), imputed
+}
+
 /*
 	Função responsável por realizar a predição.
 	Monta um CSV temporário contendo o cabeçalho completo + variável alvo,
@@ -484,22 +634,28 @@ func predictFromCSV(model *trees.ID3DecisionTree, target string, csvRow string)
 	Recebe:
 	- testID: identificador único do teste
 	- jsonStr: JSON com os dados estruturados do teste
-	- predictStr: string CSV com os atributos necessários para predição
+	- signature: assinatura destacada (base64) sobre o JSON canônico do teste
+	- keyID: operator_did ou device_id cuja chave registrada assinou o teste
 
 	A função:
 	1) Valida se o teste já existe
-	2) Converte o JSON em struct
-	3) Carrega os 3 modelos de ML do ledger
-	4) Executa as predições das três variáveis-alvo
-	   (acao_recomendada, result_class e qc_status)
-	5) Armazena o registro completo com versionamento e timestamp
-	6) Cria uma chave composta para indexação por lote
+	2) Converte o JSON em struct, verifica a assinatura do payload, a
+	   certificação do operador e valida o leitor e seu firmware
+	3) Valida o lote de reagente e recalcula expiry_days_left, e deriva
+	   os dados de cadeia de frio do transporte vinculado e a concentração
+	   estimada pela calibração do kit
+	4) Monta a linha de predição a partir do registro, marcando campos
+	   ausentes (null) com "?" e preenchendo-os com a média de treino
+	   apenas para os modelos, e carrega os 3 modelos de ML do ledger
+	5) Executa as predições das três variáveis-alvo
+	   (acao_recomendada, result_class e qc_status), guardando os valores
+	   informados pelo cliente como reported_*, marcando features fora da
+	   distribuição de treino e divergências
+	6) Armazena o registro completo com versionamento e timestamp,
+	   pendente de revisão (ReviewTest) e aprovação (ApproveTest)
+	7) Cria uma chave composta para indexação por lote
 */
-func (s *SmartContract) StoreTest(ctx contractapi.TransactionContextInterface, testID string, jsonStr string, predictStr string) error {
-<<<<<<< HEAD
-=======
-	start := time.Now()
->>>>>>> 0ca5e186a99edbc44ac93ef8343b4947887e3ee4
+func (t *TestContract) StoreTest(ctx contractapi.TransactionContextInterface, testID string, jsonStr string, signature string, keyID string) error {
 	// Verifica se já existe um teste com o mesmo ID
 	existing, err := ctx.GetStub().GetState(testID)
 	if err != nil {
@@ -515,61 +671,130 @@ func (s *SmartContract) StoreTest(ctx contractapi.TransactionContextInterface, t
 		return fmt.Errorf("erro ao decodificar JSON: %v", err)
 	}
 
-	// Define explicitamente o ID do teste
-	record.TestID = testID
+	// Verifica a assinatura do operador ou do leitor sobre o JSON recebido,
+	// que deve conter o mesmo test_id informado na transação
+	if err := verifyTestSignature(ctx, testID, &record, jsonStr, signature, keyID); err != nil {
+		return err
+	}
+
+	// Pega o timestamp da transação
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Rejeita operadores sem certificação válida para a matriz do teste
+	if err := checkOperatorCertification(ctx, &record, now); err != nil {
+		return err
+	}
+
+	// Rejeita leitores revogados ou com firmware não aprovado
+	if err := checkDevice(ctx, &record); err != nil {
+		return err
+	}
+
+	// Valida o lote de reagente e recalcula os dias até o vencimento
+	if err := applyReagentLot(ctx, &record, now); err != nil {
+		return err
+	}
+
+	// Deriva cadeia de frio e tempo de transporte do transporte vinculado
+	if err := applyShipment(ctx, &record); err != nil {
+		return err
+	}
+
+	// Calcula concentração e incerteza pela curva de calibração do kit
+	if err := applyCalibration(ctx, &record, now); err != nil {
+		return err
+	}
+
+	// Monta a linha de predição a partir do registro; campos ausentes
+	// ficam marcados para o drift e a verificação de fora de distribuição
+	predictStr := buildPredictRow(&record)
+
+	// Preenche os ausentes apenas na linha usada pelos modelos
+	imputationStats, err := imputationStats(ctx)
+	if err != nil {
+		return err
+	}
+	modelRow, imputed := imputePredictRow(imputationStats, predictStr)
+	record.ImputedFeatures = imputed
 
 	// Carrega os modelos de Machine Learning armazenados no ledger
-	modeloAcao, err := loadID3ModelFromLedger(ctx, s, "acao_recomendada")
+	modeloAcao, err := loadID3ModelFromLedger(ctx, "acao_recomendada")
 	if err != nil {
 		return err
 	}
 
-	modeloResult, err := loadID3ModelFromLedger(ctx, s, "result_class")
+	modeloResult, err := loadID3ModelFromLedger(ctx, "result_class")
 	if err != nil {
 		return err
 	}
 
-	modeloQc, err := loadID3ModelFromLedger(ctx, s, "qc_status")
+	modeloQc, err := loadID3ModelFromLedger(ctx, "qc_status")
 	if err != nil {
 		return err
 	}
 
+	// Guarda os resultados calculados localmente pelo leitor, se enviados
+	record.ReportedAcaoRecomendada = record.AcaoRecomendada
+	record.ReportedResultClass = record.ResultClass
+	record.ReportedQCStatus = record.QCStatus
+
 	// Executa as predições para as três últimas colunas da "planilha",
 	// preenchendo automaticamente os campos derivados por ML
 	record.AcaoRecomendada, err =
-		predictFromCSV(modeloAcao, "acao_recomendada", predictStr)
+		predictFromCSV(modeloAcao, "acao_recomendada", modelRow)
 	if err != nil {
 		return err
 	}
 
 	record.ResultClass, err =
-		predictFromCSV(modeloResult, "result_class", predictStr)
+		predictFromCSV(modeloResult, "result_class", modelRow)
 	if err != nil {
 		return err
 	}
 
 	record.QCStatus, err =
-		predictFromCSV(modeloQc, "qc_status", predictStr)
+		predictFromCSV(modeloQc, "qc_status", modelRow)
 	if err != nil {
 		return err
 	}
 
-	// Pega o timestamp da transação
-	txTime, err := ctx.GetStub().GetTxTimestamp()
-	if err != nil {
+	// Registra as features recebidas para o monitoramento de drift
+	if err := updateDriftAggregates(ctx, predictStr, map[string]string{
+		"acao_recomendada": record.AcaoRecomendada,
+		"result_class":     record.ResultClass,
+		"qc_status":        record.QCStatus,
+	}); err != nil {
 		return err
 	}
 
-	timestamp := time.Unix(
-		txTime.Seconds,
-		int64(txTime.Nanos),
-	).UTC().Format(time.RFC3339)
+	// Compara o resultado informado e aplica a política de fora de distribuição
+	if err := checkPredictions(ctx, &record, predictStr); err != nil {
+		return err
+	}
+
+	timestamp := now.Format(time.RFC3339)
 
 	// Define controle de versão e datas
+	record.SchemaVersion = currentTestSchema
 	record.Version = 0
 	record.CreatedAt = timestamp
 	record.LastUpdatedAt = timestamp
 
+	// Identifica quem submeteu o teste, que fica impedido de revisá-lo
+	submittedBy, err := callerID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Todo teste novo aguarda revisão e aprovação
+	record.ReviewStatus = reviewPending
+	record.Reviews = []ReviewEntry{}
+	record.SubmittedBy = submittedBy
+	record.LastUpdatedBy = ""
+
 	// Serializa o registro completo
 	bytes, err := json.Marshal(record)
 	if err != nil {
@@ -590,23 +815,17 @@ func (s *SmartContract) StoreTest(ctx contractapi.TransactionContextInterface, t
 		return err
 	}
 
-	// Armazena o indice no ledger
-<<<<<<< HEAD
-=======
-	elapsed := time.Since(start).Seconds()
-	fmt.Printf("BENCHMARK_METRIC: { \"function\": \"StoreTest\", \"testId\": \"%s\", \"executionTime\": %.6f, \"timestamp\": \"%s\" }\n",
-		testID, elapsed, time.Now().Format(time.RFC3339Nano))
+	// Indexa e emite as divergências encontradas
+	if err := recordDiscrepancies(ctx, &record); err != nil {
+		return err
+	}
 
->>>>>>> 0ca5e186a99edbc44ac93ef8343b4947887e3ee4
+	// Armazena o indice no ledger
 	return ctx.GetStub().PutState(indexKey, []byte{0x00})
 }
 
-/*
-	Função que consulta um teste específico pelo seu ID
-	Realiza busca no ledger utilizando a chave principal (testID)
-	retorna um unico objeto TestRecord
-*/
-func (s *SmartContract) GetTestByID(ctx contractapi.TransactionContextInterface, testID string,) (*TestRecord, error) {
+// Função que carrega um teste do ledger pelo seu ID, convertendo esquemas antigos
+func getTestRecord(ctx contractapi.TransactionContextInterface, testID string) (*TestRecord, error) {
 	// Valida o testID obrigatório
 	if testID == "" {
 		return nil, fmt.Errorf("testID não pode ser vazio")
@@ -621,13 +840,17 @@ func (s *SmartContract) GetTestByID(ctx contractapi.TransactionContextInterface,
 		return nil, fmt.Errorf("teste %s não encontrado", testID)
 	}
 
-	// Desserializa os dados armazenados
-	var record TestRecord
-	if err := json.Unmarshal(data, &record); err != nil {
-		return nil, err
-	}
+	// Desserializa os dados armazenados, convertendo esquemas antigos
+	return decodeTestRecord(data)
+}
 
-	return &record, nil
+/*
+	Função que consulta um teste específico pelo seu ID
+	Realiza busca no ledger utilizando a chave principal (testID)
+	retorna um unico objeto TestRecord
+*/
+func (t *TestContract) GetTestByID(ctx contractapi.TransactionContextInterface, testID string) (*TestRecord, error) {
+	return getTestRecord(ctx, testID)
 }
 
 /*
@@ -635,7 +858,7 @@ func (s *SmartContract) GetTestByID(ctx contractapi.TransactionContextInterface,
 	Utiliza chave composta "lote~teste" para localizar todos os testIDs
 	vinculados ao cassetteLot e, para cada um, realiza a consulta individual
 */
-func (s *SmartContract) GetTestsByLote(ctx contractapi.TransactionContextInterface, cassetteLot string) ([]*TestRecord, error) {
+func (t *TestContract) GetTestsByLote(ctx contractapi.TransactionContextInterface, cassetteLot string) ([]*TestRecord, error) {
 	// Valida se o lote foi informado
 	if cassetteLot == "" {
 		return nil, fmt.Errorf("cassetteLot não pode ser vazio")
@@ -669,7 +892,7 @@ func (s *SmartContract) GetTestsByLote(ctx contractapi.TransactionContextInterfa
 		testID := parts[1]
 
 		// Recupera o teste individual pelo ID
-		test, err := s.GetTestByID(ctx, testID)
+		test, err := getTestRecord(ctx, testID)
 		if err != nil {
 			return nil, err
 		}
@@ -683,13 +906,12 @@ func (s *SmartContract) GetTestsByLote(ctx contractapi.TransactionContextInterfa
 /*
 	Função responsável por atualizar um teste já existente no ledger
 	esta função NÃO executa novamente as predições
-	com os modelos de Machine Learning, apenas atualiza o teste com a string json recebida
+	com os modelos de Machine Learning, apenas atualiza o teste com a string json recebida.
+	Os campos conferidos pelos validadores de StoreTest (ver changedLockedFields)
+	não podem ser alterados, e a assinatura preservada é a da gravação original.
+	O teste alterado volta para revisão e quem o alterou não pode aprová-lo
 */
-func (s *SmartContract) UpdateTest(ctx contractapi.TransactionContextInterface, testID string, fullJSON string) error {
-<<<<<<< HEAD
-=======
-	start := time.Now()
->>>>>>> 0ca5e186a99edbc44ac93ef8343b4947887e3ee4
+func (t *TestContract) UpdateTest(ctx contractapi.TransactionContextInterface, testID string, fullJSON string) error {
 	// Busca o teste existente no ledger
 	existingBytes, err := ctx.GetStub().GetState(testID)
 	if err != nil {
@@ -699,9 +921,9 @@ func (s *SmartContract) UpdateTest(ctx contractapi.TransactionContextInterface,
 		return fmt.Errorf("teste %s nao encontrado", testID)
 	}
 
-	// Desserializa o registro atual armazenado
-	var existing TestRecord
-	if err := json.Unmarshal(existingBytes, &existing); err != nil {
+	// Desserializa o registro atual armazenado, em qualquer versão do esquema
+	existing, err := decodeTestRecord(existingBytes)
+	if err != nil {
 		return err
 	}
 
@@ -711,6 +933,11 @@ func (s *SmartContract) UpdateTest(ctx contractapi.TransactionContextInterface,
 		return fmt.Errorf("json invalido: %v", err)
 	}
 
+	// Rejeita alterações nos campos validados na gravação original
+	if changed := changedLockedFields(existing, &updated); len(changed) > 0 {
+		return fmt.Errorf("campos validados na gravação não podem ser alterados: %s", strings.Join(changed, ", "))
+	}
+
 	// Obtém timestamp da transação atual
 	txTime, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
@@ -724,67 +951,115 @@ func (s *SmartContract) UpdateTest(ctx contractapi.TransactionContextInterface,
 
 	// Mantém integridade dos metadados controlados pelo ledger
 	updated.TestID = testID
+	updated.SchemaVersion = currentTestSchema        // Grava no esquema atual
 	updated.Version = existing.Version + 1           // Incrementa versão
 	updated.CreatedAt = existing.CreatedAt           // Preserva data original
 	updated.LastUpdatedAt = now                      // Atualiza data de modificação
 
-	// Caso o lote tenha sido alterado, atualiza o índice composto
-	if existing.CassetteLot != updated.CassetteLot {
-		// Remove índice antigo
-		oldIndexKey, err := ctx.GetStub().CreateCompositeKey(
-			"lote~teste",
-			[]string{existing.CassetteLot, testID},
-		)
-		if err != nil {
-			return err
-		}
-
-		if err := ctx.GetStub().DelState(oldIndexKey); err != nil {
-			return err
-		}
-
-		// Cria novo índice com o lote atualizado
-		newIndexKey, err := ctx.GetStub().CreateCompositeKey(
-			"lote~teste",
-			[]string{updated.CassetteLot, testID},
-		)
-		if err != nil {
-			return err
-		}
-
-		if err := ctx.GetStub().PutState(newIndexKey, []byte{0x00}); err != nil {
-			return err
-		}
+	// Identifica quem alterou o teste, que fica impedido de revisá-lo
+	updatedBy, err := callerID(ctx)
+	if err != nil {
+		return err
 	}
 
+	// Preserva os resultados informados e as divergências da gravação original
+	updated.ReportedAcaoRecomendada = existing.ReportedAcaoRecomendada
+	updated.ReportedResultClass = existing.ReportedResultClass
+	updated.ReportedQCStatus = existing.ReportedQCStatus
+	updated.HasDiscrepancy = existing.HasDiscrepancy
+	updated.Discrepancies = existing.Discrepancies
+
+	// Preserva os campos derivados pelo ledger na gravação original
+	// (lote de reagente, transporte, calibração, assinatura, fora de
+	// distribuição e imputação), que não podem ser alterados pelo cliente
+	updated.ExpiryDaysLeft = existing.ExpiryDaysLeft
+	updated.ReagentExpired = existing.ReagentExpired
+	updated.CadeiaFrioStatus = existing.CadeiaFrioStatus
+	updated.TempoTransporteHoras = existing.TempoTransporteHoras
+	updated.CondicaoTransporte = existing.CondicaoTransporte
+	updated.EstimatedConcentrationPpb = existing.EstimatedConcentrationPpb
+	updated.IncertezaEstimativaPpb = existing.IncertezaEstimativaPpb
+	updated.ConcentrationOutOfRange = existing.ConcentrationOutOfRange
+	updated.Signature = existing.Signature
+	updated.SignatureKeyID = existing.SignatureKeyID
+	updated.SignerType = existing.SignerType
+	updated.OutOfDistribution = existing.OutOfDistribution
+	updated.OODFeatures = existing.OODFeatures
+	updated.QCStatusForced = existing.QCStatusForced
+	updated.ImputedFeatures = existing.ImputedFeatures
+
+	// Preserva o histórico de revisão e exige nova revisão da versão alterada
+	updated.Reviews = existing.Reviews
+	updated.SubmittedBy = existing.SubmittedBy
+	updated.ReviewStatus = reviewAmended
+	updated.LastUpdatedBy = updatedBy
+
 	// Serializa o registro atualizado
 	bytes, err := json.Marshal(updated)
 	if err != nil {
 		return err
 	}
 
-<<<<<<< HEAD
-=======
-	elapsed := time.Since(start).Seconds()
-
-	fmt.Printf("BENCHMARK_METRIC: { \"function\": \"UpdateTest\", \"testId\": \"%s\", \"executionTime\": %.6f, \"timestamp\": \"%s\" }\n", 
-        testID, elapsed, time.Now().Format(time.RFC3339Nano))
-
->>>>>>> 0ca5e186a99edbc44ac93ef8343b4947887e3ee4
 	// Persiste o novo estado do teste no ledger
 	return ctx.GetStub().PutState(testID, bytes)
 }
 
+/*
+	Função que lista os campos conferidos pelos validadores de StoreTest
+	(operador, leitor, lote de reagente, transporte e calibração) que
+	diferem entre o teste gravado e a atualização recebida. Alterá-los
+	exigiria validar o teste de novo e invalidaria os campos derivados
+*/
+func changedLockedFields(existing *TestRecord, updated *TestRecord) []string {
+	fields := []struct {
+		name    string
+		changed bool
+	}{
+		{"cassette_lot", existing.CassetteLot != updated.CassetteLot},
+		{"operator_id", existing.OperatorID != updated.OperatorID},
+		{"operator_did", existing.OperatorDID != updated.OperatorDID},
+		{"matrix_type", existing.MatrixType != updated.MatrixType},
+		{"device_id", existing.DeviceID != updated.DeviceID},
+		{"device_fw_version", existing.DeviceFWVersion != updated.DeviceFWVersion},
+		{"reagent_lot", existing.ReagentLot != updated.ReagentLot},
+		{"shipment_id", existing.ShipmentID != updated.ShipmentID},
+		{"kit_calibration_id", existing.KitCalibrationID != updated.KitCalibrationID},
+		{"distance_mm", existing.DistanceMM != updated.DistanceMM},
+		{"time_to_migrate_s", existing.TimeToMigrateS != updated.TimeToMigrateS},
+	}
+
+	changed := []string{}
+	for _, field := range fields {
+		if field.changed {
+			changed = append(changed, field.name)
+		}
+	}
+
+	return changed
+}
+
 // main inicia a execução do chaincode no blockchain
 func main() {
-	// Cria uma nova instância do chaincode
-	chaincode, err := contractapi.NewChaincode(new(SmartContract))
+	// Cria uma nova instância do chaincode com os contratos tests,
+	// planilhas, models e registry (o primeiro é o contrato padrão)
+	chaincode, err := contractapi.NewChaincode(newContracts()...)
 	if err != nil {
 		panic(fmt.Sprintf("erro criando chaincode: %v", err))
 	}
+
+	chaincode.Info = metadata.InfoMetadata{
+		Title:   "sollytch-chain",
+		Version: chaincodeVersion,
+	}
 	
-	// Inicia o chaincode e aguarda por transações
-	if err := chaincode.Start(); err != nil {
+	// Inicia o chaincode e aguarda por transações, como serviço externo
+	// (RUN_CCAAS=true) ou conectado ao peer
+	if os.Getenv("RUN_CCAAS") == "true" {
+		err = runCCaaS(chaincode)
+	} else {
+		err = chaincode.Start()
+	}
+	if err != nil {
 		panic(fmt.Sprintf("erro iniciando chaincode: %v", err))
 	}
 }
\ No newline at end of file
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestUpdateTestPreservesLedgerFields(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-chain", nil)
+	ctx := newTestContext(stub, newIdentity("Org1MSP", "editor", nil))
+
+	stored := TestRecord{
+		SchemaVersion:             currentTestSchema,
+		TestID:                    "TEST-00001",
+		CassetteLot:               "C22009",
+		ExpiryDaysLeft:            120,
+		ReagentExpired:            false,
+		CadeiaFrioStatus:          true,
+		TempoTransporteHoras:      36,
+		CondicaoTransporte:        "refrigerado",
+		EstimatedConcentrationPpb: 1.8,
+		IncertezaEstimativaPpb:    0.2,
+		ConcentrationOutOfRange:   false,
+		Signature:                 "c2lnbmF0dXJl",
+		SignatureKeyID:            "did:sollytch:op-1",
+		SignerType:                signerOperator,
+		OutOfDistribution:         true,
+		OODFeatures:               []string{"sample_pH"},
+		QCStatusForced:            true,
+		ImputedFeatures:           []string{"image_blur_score"},
+		SubmittedBy:               "Org1MSP:submitter",
+		Reviews:                   []ReviewEntry{},
+		Discrepancies:             []Discrepancy{},
+	}
+	bytes, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustTx(t, stub, func() error { return stub.PutState(stored.TestID, bytes) })
+
+	// Cada campo derivado pelo ledger recebe outro valor no JSON do cliente
+	overwrite := map[string]interface{}{
+		"cassette_lot":                "C22009",
+		"expiry_days_left":            999,
+		"reagent_expired":             true,
+		"cadeia_frio_status":          false,
+		"tempo_transporte_horas":      1,
+		"condicao_transporte":         "ambiente",
+		"estimated_concentration_ppb": 99,
+		"incerteza_estimativa_ppb":    0,
+		"concentration_out_of_range":  true,
+		"signature":                   "forjada",
+		"signature_key_id":            "did:sollytch:outro",
+		"signer_type":                 signerDevice,
+		"out_of_distribution":         false,
+		"ood_features":                []string{},
+		"qc_status_forced":            false,
+		"imputed_features":            []string{},
+		"submitted_by":                "Org1MSP:editor",
+	}
+	updateJSON, err := json.Marshal(overwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contract := new(TestContract)
+	mustTx(t, stub, func() error { return contract.UpdateTest(ctx, stored.TestID, string(updateJSON)) })
+
+	var updated TestRecord
+	if err := json.Unmarshal(stub.State[stored.TestID], &updated); err != nil {
+		t.Fatal(err)
+	}
+
+	checks := []struct {
+		field    string
+		got      interface{}
+		expected interface{}
+	}{
+		{"expiry_days_left", updated.ExpiryDaysLeft, stored.ExpiryDaysLeft},
+		{"reagent_expired", updated.ReagentExpired, stored.ReagentExpired},
+		{"cadeia_frio_status", updated.CadeiaFrioStatus, stored.CadeiaFrioStatus},
+		{"tempo_transporte_horas", updated.TempoTransporteHoras, stored.TempoTransporteHoras},
+		{"condicao_transporte", updated.CondicaoTransporte, stored.CondicaoTransporte},
+		{"estimated_concentration_ppb", updated.EstimatedConcentrationPpb, stored.EstimatedConcentrationPpb},
+		{"incerteza_estimativa_ppb", updated.IncertezaEstimativaPpb, stored.IncertezaEstimativaPpb},
+		{"concentration_out_of_range", updated.ConcentrationOutOfRange, stored.ConcentrationOutOfRange},
+		{"signature", updated.Signature, stored.Signature},
+		{"signature_key_id", updated.SignatureKeyID, stored.SignatureKeyID},
+		{"signer_type", updated.SignerType, stored.SignerType},
+		{"out_of_distribution", updated.OutOfDistribution, stored.OutOfDistribution},
+		{"ood_features", updated.OODFeatures, stored.OODFeatures},
+		{"qc_status_forced", updated.QCStatusForced, stored.QCStatusForced},
+		{"imputed_features", updated.ImputedFeatures, stored.ImputedFeatures},
+		{"submitted_by", updated.SubmittedBy, stored.SubmittedBy},
+	}
+	for _, check := range checks {
+		if !reflect.DeepEqual(check.got, check.expected) {
+			t.Errorf("%s: expected %v to be preserved, got %v", check.field, check.expected, check.got)
+		}
+	}
+
+	if updated.Version != 1 || updated.ReviewStatus != reviewAmended || updated.LastUpdatedBy != "Org1MSP:editor" {
+		t.Errorf("unexpected update metadata: version %d, status %s, by %s", updated.Version, updated.ReviewStatus, updated.LastUpdatedBy)
+	}
+}
+
+func TestUpdateTestRejectsValidatedFields(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-chain", nil)
+	ctx := newTestContext(stub, newIdentity("Org1MSP", "editor", nil))
+	contract := new(TestContract)
+
+	stored := TestRecord{
+		SchemaVersion:    currentTestSchema,
+		TestID:           "TEST-00001",
+		CassetteLot:      "C22009",
+		OperatorID:       "OP-1",
+		OperatorDID:      "did:sollytch:op-1",
+		MatrixType:       "agua",
+		DeviceID:         "LEITOR-001",
+		DeviceFWVersion:  "1.2.0",
+		ReagentLot:       "R-001",
+		ShipmentID:       "SHIP-001",
+		KitCalibrationID: "CAL-001",
+		DistanceMM:       newNullFloat64(12.5),
+		TimeToMigrateS:   newNullFloat64(300),
+		SamplePH:         newNullFloat64(7),
+	}
+	bytes, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustTx(t, stub, func() error { return stub.PutState(stored.TestID, bytes) })
+
+	tests := []struct {
+		field string
+		value interface{}
+	}{
+		{"cassette_lot", "C99999"},
+		{"operator_id", "OP-2"},
+		{"operator_did", "did:sollytch:op-2"},
+		{"matrix_type", "solo"},
+		{"device_id", "LEITOR-002"},
+		{"device_fw_version", "9.9.9"},
+		{"reagent_lot", "R-002"},
+		{"shipment_id", "SHIP-002"},
+		{"kit_calibration_id", "CAL-002"},
+		{"distance_mm", 20.0},
+		{"time_to_migrate_s", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.field, func(t *testing.T) {
+			var fields map[string]interface{}
+			if err := json.Unmarshal(bytes, &fields); err != nil {
+				t.Fatal(err)
+			}
+			fields[test.field] = test.value
+			updateJSON, _ := json.Marshal(fields)
+
+			err := inTx(t, stub, func() error { return contract.UpdateTest(ctx, stored.TestID, string(updateJSON)) })
+			if err == nil || !strings.Contains(err.Error(), test.field) {
+				t.Errorf("expected change to %s to be rejected, got %v", test.field, err)
+			}
+		})
+	}
+
+	// Demais campos continuam editáveis
+	var fields map[string]interface{}
+	if err := json.Unmarshal(bytes, &fields); err != nil {
+		t.Fatal(err)
+	}
+	fields["sample_pH"] = 6.5
+	updateJSON, _ := json.Marshal(fields)
+	mustTx(t, stub, func() error { return contract.UpdateTest(ctx, stored.TestID, string(updateJSON)) })
+
+	updated, err := getTestRecord(ctx, stored.TestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.SamplePH != newNullFloat64(6.5) || updated.Version != 1 {
+		t.Errorf("expected sample_pH to be updated, got %+v", updated.SamplePH)
+	}
+}
+
+/*
+	Ledger com tudo o que o StoreTest consulta: os três modelos de
+	modelos/, o operador OP04 certificado para "agua", o leitor DEV-001,
+	o lote de reagente R24010, o transporte SHP-001 e a calibração CAL1050
+	do lote de cassete C22009. O teste de storeTestJSON passa por todos os
+	validadores
+*/
+type storeTestFixture struct {
+	stub        *shimtest.MockStub
+	ctx         *contractapi.TransactionContext
+	contract    *TestContract
+	operatorKey *ecdsa.PrivateKey
+	now         time.Time
+}
+
+func newStoreTestFixture(t *testing.T) *storeTestFixture {
+	t.Helper()
+	stub := shimtest.NewMockStub("sollytch-chain", nil)
+	registry := new(RegistryContract)
+	now := time.Now().UTC()
+	day := 24 * time.Hour
+	date := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+
+	admin := newTestContext(stub, newIdentity("Org1MSP", "admin", map[string]string{roleAttribute: adminRole}))
+	supplier := newTestContext(stub, newIdentity("Org1MSP", "supplier", map[string]string{roleAttribute: supplierRole}))
+	certifier := newTestContext(stub, newIdentity("Org1MSP", "certifier", map[string]string{roleAttribute: certifierRole}))
+
+	stats, err := json.Marshal(trainingStatsFixture())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, modelKey := range []string{"acao_recomendada", "result_class", "qc_status"} {
+		model, err := os.ReadFile(filepath.Join("modelos", modelKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustTx(t, stub, func() error {
+			return new(ModelContract).StoreModel(admin, modelKey, base64.StdEncoding.EncodeToString(model), string(stats))
+		})
+	}
+
+	operatorKey, operatorPEM := newSigningKey(t)
+	operator, err := json.Marshal(Operator{OperatorID: "OP04", OperatorDID: "did:bio:OP04", PublicKey: operatorPEM})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustTx(t, stub, func() error { return registry.RegisterOperator(admin, string(operator)) })
+	mustTx(t, stub, func() error { return registry.CertifyOperator(certifier, "OP04", "agua", date(30*day)) })
+
+	_, devicePEM := newSigningKey(t)
+	device, err := json.Marshal(Device{DeviceID: "DEV-001", PublicKey: devicePEM, AllowedFirmware: []string{"1.0.4"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustTx(t, stub, func() error { return registry.RegisterDevice(admin, string(device)) })
+
+	mustTx(t, stub, func() error {
+		return registry.RegisterReagentLot(supplier, fmt.Sprintf(`{"lot_id":"R24010","manufacturer":"Sollytch",`+
+			`"manufacture_date":%q,"expiry_date":%q,"storage_min_temp_C":2,"storage_max_temp_C":8}`, date(-60*day), date(42*day)))
+	})
+	mustTx(t, stub, func() error {
+		return registry.RegisterShipment(supplier, fmt.Sprintf(`{"shipment_id":"SHP-001","reagent_lot":"R24010",`+
+			`"cassette_lot":"C22009","condicao_transporte":"protegido","dispatched_at":%q,"delivered_at":%q,`+
+			`"readings":[{"timestamp":%q,"temp_C":4},{"timestamp":%q,"temp_C":5}]}`,
+			date(-10*day), date(-10*day+6*time.Hour), date(-10*day), date(-10*day+3*time.Hour)))
+	})
+	mustTx(t, stub, func() error {
+		return registry.RegisterCalibration(supplier, fmt.Sprintf(`{"calibration_id":"CAL1050","kit_lot":"C22009",`+
+			`"valid_from":%q,"valid_until":%q,"model_type":"linear","coefficients":[1,1,0],`+
+			`"distance_min_mm":0,"distance_max_mm":40,"time_min_s":0,"time_max_s":900,"uncertainty_abs_ppb":1}`,
+			date(-30*day), date(30*day)))
+	})
+
+	return &storeTestFixture{
+		stub:        stub,
+		ctx:         newTestContext(stub, newIdentity("Org1MSP", "OP04", nil)),
+		contract:    new(TestContract),
+		operatorKey: operatorKey,
+		now:         now,
+	}
+}
+
+// JSON de um teste válido com test_id testID; changes altera ou remove (nil) campos
+func storeTestJSON(t *testing.T, testID string, changes map[string]interface{}) string {
+	t.Helper()
+	fields := map[string]interface{}{
+		"test_id":                 testID,
+		"lat":                     -22.87496,
+		"lon":                     -43.246872,
+		"operator_id":             "OP04",
+		"operator_did":            "did:bio:OP04",
+		"matrix_type":             "agua",
+		"cassette_lot":            "C22009",
+		"reagent_lot":             "R24010",
+		"shipment_id":             "SHP-001",
+		"kit_calibration_id":      "CAL1050",
+		"device_id":               "DEV-001",
+		"device_fw_version":       "1.0.4",
+		"distance_mm":             24.87,
+		"time_to_migrate_s":       466.3,
+		"control_line_ok":         true,
+		"sample_pH":               6.79,
+		"controle_interno_result": "falha_controle_negativo",
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(fields, key)
+			continue
+		}
+		fields[key] = value
+	}
+
+	bytes, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bytes)
+}
+
+// Assina jsonStr com a chave do operador e submete o StoreTest em uma transação própria
+func (f *storeTestFixture) store(t *testing.T, testID string, jsonStr string) error {
+	t.Helper()
+	signature := signTestJSON(t, f.operatorKey, jsonStr)
+
+	f.stub.MockTransactionStart(testID)
+	defer f.stub.MockTransactionEnd(testID)
+	return f.contract.StoreTest(f.ctx, testID, jsonStr, signature, "did:bio:OP04")
+}
+
+// Como store, mas falha o teste em caso de erro e retorna o registro gravado
+func (f *storeTestFixture) mustStore(t *testing.T, testID string, jsonStr string) *TestRecord {
+	t.Helper()
+	if err := f.store(t, testID, jsonStr); err != nil {
+		t.Fatal(err)
+	}
+	record, err := getTestRecord(f.ctx, testID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+// Verifica que o StoreTest foi rejeitado com a mensagem esperada e nada foi gravado
+func (f *storeTestFixture) assertRejected(t *testing.T, testID string, jsonStr string, message string) {
+	t.Helper()
+	err := f.store(t, testID, jsonStr)
+	if err == nil || !strings.Contains(err.Error(), message) {
+		t.Fatalf("expected StoreTest to fail with %q, got %v", message, err)
+	}
+	if _, ok := f.stub.State[testID]; ok {
+		t.Errorf("expected rejected test %s not to be stored", testID)
+	}
+}
+
+func TestStoreTest(t *testing.T) {
+	f := newStoreTestFixture(t)
+
+	record := f.mustStore(t, "TEST-00001", storeTestJSON(t, "TEST-00001", nil))
+
+	if record.ReviewStatus != reviewPending || record.SubmittedBy != "Org1MSP:OP04" || record.SignerType != signerOperator {
+		t.Errorf("unexpected stored test: %+v", record)
+	}
+	if record.AcaoRecomendada == "" || record.ResultClass == "" || record.QCStatus == "" {
+		t.Errorf("expected the three predictions, got %q %q %q", record.AcaoRecomendada, record.ResultClass, record.QCStatus)
+	}
+
+	indexKey, err := f.stub.CreateCompositeKey("lote~teste", []string{"C22009", "TEST-00001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.stub.State[indexKey]; !ok {
+		t.Error("expected test to be indexed by cassette lot")
+	}
+
+	if err := f.store(t, "TEST-00001", storeTestJSON(t, "TEST-00001", nil)); err == nil || !strings.Contains(err.Error(), "ja existe") {
+		t.Errorf("expected duplicate test to be rejected, got %v", err)
+	}
+}
+
+func TestStoreTestReagentLot(t *testing.T) {
+	f := newStoreTestFixture(t)
+
+	// expiry_days_left vem do lote registrado, não do cliente
+	record := f.mustStore(t, "TEST-00001", storeTestJSON(t, "TEST-00001", map[string]interface{}{"expiry_days_left": 999}))
+	if record.ExpiryDaysLeft != 41 || record.ReagentExpired {
+		t.Errorf("expected 41 days left on a valid lot, got %d (expired %v)", record.ExpiryDaysLeft, record.ReagentExpired)
+	}
+
+	f.assertRejected(t, "TEST-00002", storeTestJSON(t, "TEST-00002", map[string]interface{}{"reagent_lot": "R99999"}), "R99999 não registrado")
+	f.assertRejected(t, "TEST-00003", storeTestJSON(t, "TEST-00003", map[string]interface{}{"reagent_lot": nil}), "reagent_lot é obrigatório")
+}
+
+func TestStoreTestIDMustMatchSignedJSON(t *testing.T) {
+	f := newStoreTestFixture(t)
+
+	// O JSON assinado para TEST-00001 não pode ser gravado como outro teste
+	signed := storeTestJSON(t, "TEST-00001", nil)
+	f.assertRejected(t, "TEST-00002", signed, "test_id")
+	f.assertRejected(t, "TEST-00003", storeTestJSON(t, "TEST-00003", map[string]interface{}{"test_id": nil}), "test_id")
+
+	// Assinatura de outro conteúdo
+	f.stub.MockTransactionStart("TEST-00001")
+	err := f.contract.StoreTest(f.ctx, "TEST-00001", signed, signTestJSON(t, f.operatorKey, storeTestJSON(t, "TEST-00001", map[string]interface{}{"sample_pH": 7})), "did:bio:OP04")
+	f.stub.MockTransactionEnd("TEST-00001")
+	if err == nil {
+		t.Error("expected signature over other content to be rejected")
+	}
+
+	f.mustStore(t, "TEST-00001", signed)
+}
+
+// Os validadores rodam na ordem do StoreTest: assinatura, operador, leitor,
+// lote de reagente, transporte e calibração. Um teste que falha em todos
+// é rejeitado pelo primeiro e, corrigido um a um, pelo seguinte
+func TestStoreTestValidatorOrder(t *testing.T) {
+	f := newStoreTestFixture(t)
+
+	steps := []struct {
+		field   string
+		invalid interface{}
+		message string
+	}{
+		{"matrix_type", "solo", "não certificado"},
+		{"device_fw_version", "0.0.1", "firmware 0.0.1"},
+		{"reagent_lot", "R99999", "lote de reagente"},
+		{"shipment_id", "SHP-999", "transporte SHP-999"},
+		{"kit_calibration_id", "CAL-999", "calibração CAL-999"},
+	}
+
+	changes := map[string]interface{}{}
+	for _, step := range steps {
+		changes[step.field] = step.invalid
+	}
+	for _, step := range steps {
+		f.assertRejected(t, "TEST-00001", storeTestJSON(t, "TEST-00001", changes), step.message)
+		delete(changes, step.field)
+	}
+
+	f.mustStore(t, "TEST-00001", storeTestJSON(t, "TEST-00001", changes))
+}
+
+func TestStoreTestDiscrepancies(t *testing.T) {
+	f := newStoreTestFixture(t)
+
+	// A reader that agrees with the model does not create a discrepancy
+	predicted := f.mustStore(t, "TEST-00001", storeTestJSON(t, "TEST-00001", nil))
+	agreeing := f.mustStore(t, "TEST-00002", storeTestJSON(t, "TEST-00002", map[string]interface{}{
+		"result_class": predicted.ResultClass,
+	}))
+	if agreeing.HasDiscrepancy || len(agreeing.Discrepancies) != 0 {
+		t.Errorf("expected no discrepancy, got %+v", agreeing.Discrepancies)
+	}
+	if len(f.stub.ChaincodeEventsChannel) != 0 {
+		t.Error("expected no event for tests without discrepancy")
+	}
+
+	reported := "positivo"
+	if predicted.ResultClass == reported {
+		reported = "negativo"
+	}
+	record := f.mustStore(t, "TEST-00003", storeTestJSON(t, "TEST-00003", map[string]interface{}{
+		"result_class": reported,
+	}))
+	expected := []Discrepancy{{Field: "result_class", Reported: reported, Predicted: predicted.ResultClass}}
+	if !record.HasDiscrepancy || !reflect.DeepEqual(record.Discrepancies, expected) {
+		t.Errorf("expected %+v, got %+v", expected, record.Discrepancies)
+	}
+
+	select {
+	case event := <-f.stub.ChaincodeEventsChannel:
+		var payload discrepancyEventPayload
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			t.Fatal(err)
+		}
+		if event.EventName != discrepancyEvent || payload.TestID != "TEST-00003" || payload.CassetteLot != "C22009" {
+			t.Errorf("unexpected event %s: %+v", event.EventName, payload)
+		}
+	default:
+		t.Error("expected TestDiscrepancy event")
+	}
+
+	for _, lot := range []string{"C22009", ""} {
+		results, err := f.contract.GetDiscrepancies(f.ctx, lot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].TestID != "TEST-00003" {
+			t.Errorf("expected only TEST-00003 for lot %q, got %d results", lot, len(results))
+		}
+	}
+
+	results, err := f.contract.GetDiscrepancies(f.ctx, "C22010")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected no discrepancies for another lot, got %d", len(results))
+	}
+}
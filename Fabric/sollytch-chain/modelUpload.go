@@ -0,0 +1,318 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Papel exigido para enviar modelos em partes
+const modelPublisherRole = "model_publisher"
+
+// Situações de um envio de modelo em partes
+const (
+	uploadOpen      = "aberto"
+	uploadFinalized = "finalizado"
+)
+
+/*
+	struct json de um envio de modelo em partes
+	Cada chunk é gravado em uma chave própria ("modelo~chunk"), de forma que
+	PutModelChunk não altera a sessão e chunks podem ser enviados em
+	transações concorrentes sem conflito de escrita
+*/
+type ModelUpload struct {
+	//trackers
+	StartedAt   string `json:"started_at"`
+	StartedBy   string `json:"started_by"`
+	FinalizedAt string `json:"finalized_at,omitempty"`
+
+	//chave de busca
+	UploadID string `json:"upload_id"`
+
+	//conteudo
+	ModelKey       string         `json:"model_key"`
+	TotalChunks    int            `json:"total_chunks"`
+	DeclaredSHA256 string         `json:"declared_sha256"`
+	TrainingStats  *TrainingStats `json:"training_stats,omitempty"`
+	Status         string         `json:"status"`
+	ModelVersion   int            `json:"model_version,omitempty"`
+}
+
+// Função que monta a chave de estado de um envio de modelo
+func modelUploadKey(ctx contractapi.TransactionContextInterface, uploadID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("envio~modelo", []string{uploadID})
+}
+
+// Função que monta a chave de um chunk; o índice tem largura fixa para manter a ordem
+func modelChunkKey(ctx contractapi.TransactionContextInterface, uploadID string, index int) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("modelo~chunk", []string{uploadID, fmt.Sprintf("%06d", index)})
+}
+
+// Função que carrega um envio de modelo pelo seu ID
+func getModelUpload(ctx contractapi.TransactionContextInterface, uploadID string) (*ModelUpload, error) {
+	key, err := modelUploadKey(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("envio de modelo %s não encontrado", uploadID)
+	}
+
+	var upload ModelUpload
+	if err := json.Unmarshal(data, &upload); err != nil {
+		return nil, err
+	}
+
+	return &upload, nil
+}
+
+/*
+	Função que carrega um envio de modelo em aberto iniciado pelo cliente
+	que submeteu a transação, com o papel "model_publisher"
+*/
+func loadOwnedModelUpload(ctx contractapi.TransactionContextInterface, uploadID string) (*ModelUpload, error) {
+	if err := requireRole(ctx, modelPublisherRole); err != nil {
+		return nil, err
+	}
+
+	upload, err := getModelUpload(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if upload.StartedBy != caller {
+		return nil, fmt.Errorf("acesso negado: envio de modelo %s iniciado por %s", uploadID, upload.StartedBy)
+	}
+
+	if upload.Status != uploadOpen {
+		return nil, fmt.Errorf("envio de modelo %s ja finalizado", uploadID)
+	}
+
+	return upload, nil
+}
+
+// Função que grava um envio de modelo no ledger
+func putModelUpload(ctx contractapi.TransactionContextInterface, upload *ModelUpload) error {
+	key, err := modelUploadKey(ctx, upload.UploadID)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, bytes)
+}
+
+/*
+	Função que lê os chunks de um envio em ordem e os concatena
+	Falha se faltar algum chunk ou se o SHA-256 do resultado não
+	corresponder ao esperado
+*/
+func assembleModelChunks(ctx contractapi.TransactionContextInterface, uploadID string, totalChunks int, expectedSHA256 string) ([]byte, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("modelo~chunk", []string{uploadID})
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	var model []byte
+	index := 0
+
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, parts, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		// Os chunks vêm ordenados pelo índice; qualquer salto indica falta
+		if parts[1] != fmt.Sprintf("%06d", index) {
+			return nil, fmt.Errorf("chunk %d do envio %s não encontrado", index, uploadID)
+		}
+
+		model = append(model, response.Value...)
+		index++
+	}
+
+	if index != totalChunks {
+		return nil, fmt.Errorf("envio %s possui %d de %d chunks", uploadID, index, totalChunks)
+	}
+
+	sum := sha256.Sum256(model)
+	if hex.EncodeToString(sum[:]) != expectedSHA256 {
+		return nil, fmt.Errorf("SHA-256 do modelo não corresponde ao declarado")
+	}
+
+	return model, nil
+}
+
+/*
+	Função que inicia o envio de um modelo em partes
+	Recebe o total de chunks, o SHA-256 (hex) do modelo completo e, opcionalmente,
+	as estatísticas de treino. O modelo só passa a ser usado após FinalizeModelUpload
+	Exige o papel "model_publisher"; apenas quem iniciou o envio pode
+	enviar os chunks e finalizá-lo
+*/
+func (m *ModelContract) BeginModelUpload(ctx contractapi.TransactionContextInterface, uploadID string, modelKey string, totalChunks int, sha256Hex string, trainingStatsJSON string) error {
+	// Garante que o cliente pode publicar modelos
+	if err := requireRole(ctx, modelPublisherRole); err != nil {
+		return err
+	}
+
+	if uploadID == "" {
+		return fmt.Errorf("uploadID não pode ser vazio")
+	}
+	if err := validateModelKey(modelKey); err != nil {
+		return err
+	}
+	if totalChunks <= 0 {
+		return fmt.Errorf("totalChunks deve ser positivo")
+	}
+
+	declared, err := hex.DecodeString(sha256Hex)
+	if err != nil || len(declared) != sha256.Size {
+		return fmt.Errorf("sha256 deve ser um hash SHA-256 em hexadecimal")
+	}
+
+	var trainingStats *TrainingStats
+	if trainingStatsJSON != "" {
+		trainingStats, err = parseTrainingStats(trainingStatsJSON)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := getModelUpload(ctx, uploadID); err == nil {
+		return fmt.Errorf("envio de modelo %s ja existe", uploadID)
+	}
+
+	startedBy, err := callerID(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	return putModelUpload(ctx, &ModelUpload{
+		UploadID:       uploadID,
+		ModelKey:       modelKey,
+		TotalChunks:    totalChunks,
+		DeclaredSHA256: strings.ToLower(sha256Hex),
+		TrainingStats:  trainingStats,
+		Status:         uploadOpen,
+		StartedAt:      now.Format(time.RFC3339),
+		StartedBy:      startedBy,
+	})
+}
+
+/*
+	Função que grava um chunk (base64) de um envio de modelo em aberto
+	Chunks podem ser reenviados enquanto o envio não for finalizado,
+	apenas pelo cliente que iniciou o envio
+*/
+func (m *ModelContract) PutModelChunk(ctx contractapi.TransactionContextInterface, uploadID string, index int, chunkBase64 string) error {
+	upload, err := loadOwnedModelUpload(ctx, uploadID)
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= upload.TotalChunks {
+		return fmt.Errorf("index deve estar entre 0 e %d", upload.TotalChunks-1)
+	}
+
+	chunk, err := base64.StdEncoding.DecodeString(chunkBase64)
+	if err != nil {
+		return fmt.Errorf("chunk não está em base64: %v", err)
+	}
+	if len(chunk) == 0 {
+		return fmt.Errorf("chunk não pode ser vazio")
+	}
+
+	key, err := modelChunkKey(ctx, uploadID, index)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, chunk)
+}
+
+/*
+	Função que finaliza um envio de modelo em partes
+	Verifica se todos os chunks foram recebidos e se o SHA-256 do modelo
+	remontado corresponde ao declarado; só então grava a nova versão do
+	modelo, que passa a ser usada nas predições. Apenas o cliente que
+	iniciou o envio pode finalizá-lo
+*/
+func (m *ModelContract) FinalizeModelUpload(ctx contractapi.TransactionContextInterface, uploadID string) error {
+	upload, err := loadOwnedModelUpload(ctx, uploadID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := assembleModelChunks(ctx, uploadID, upload.TotalChunks, upload.DeclaredSHA256); err != nil {
+		return err
+	}
+
+	version, err := nextModelVersion(ctx, upload.ModelKey)
+	if err != nil {
+		return err
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+	timestamp := now.Format(time.RFC3339)
+
+	// O registro do modelo referencia os chunks em vez de conter os bytes
+	model := ModelBytes{
+		ModelKey:      upload.ModelKey,
+		Version:       version,
+		UpdatedAt:     timestamp,
+		TrainingStats: upload.TrainingStats,
+		Chunked:       true,
+		UploadID:      uploadID,
+		ChunkCount:    upload.TotalChunks,
+		SHA256:        upload.DeclaredSHA256,
+	}
+
+	bytes, err := json.Marshal(model)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(upload.ModelKey, bytes); err != nil {
+		return err
+	}
+
+	upload.Status = uploadFinalized
+	upload.FinalizedAt = timestamp
+	upload.ModelVersion = version
+
+	return putModelUpload(ctx, upload)
+}
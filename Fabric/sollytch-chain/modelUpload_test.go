@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Cliente com o papel de publicação de modelos que conduz os envios dos testes
+func publisherContext(stub *shimtest.MockStub) *contractapi.TransactionContext {
+	return newTestContext(stub, newIdentity("Org1MSP", "trainer", map[string]string{roleAttribute: modelPublisherRole}))
+}
+
+// Inicia um envio de modelo em três partes com o SHA-256 informado
+func beginTestUpload(t *testing.T, stub *shimtest.MockStub, contract *ModelContract, uploadID string, sha string) {
+	t.Helper()
+	ctx := publisherContext(stub)
+	mustTx(t, stub, func() error {
+		return contract.BeginModelUpload(ctx, uploadID, "qc_status", 3, sha, "")
+	})
+}
+
+func putTestChunk(t *testing.T, stub *shimtest.MockStub, contract *ModelContract, uploadID string, index int, chunk []byte) {
+	t.Helper()
+	ctx := publisherContext(stub)
+	mustTx(t, stub, func() error {
+		return contract.PutModelChunk(ctx, uploadID, index, base64.StdEncoding.EncodeToString(chunk))
+	})
+}
+
+func TestChunkedModelUpload(t *testing.T) {
+	chunks := [][]byte{[]byte("primeiro-"), []byte("segundo-"), []byte("terceiro")}
+	model := bytes.Join(chunks, nil)
+	sum := sha256.Sum256(model)
+	sha := hex.EncodeToString(sum[:])
+
+	contract := new(ModelContract)
+
+	t.Run("out of order chunks", func(t *testing.T) {
+		stub := shimtest.NewMockStub("sollytch-chain", nil)
+		ctx := publisherContext(stub)
+
+		beginTestUpload(t, stub, contract, "upload-1", sha)
+		for _, index := range []int{2, 0, 1} {
+			putTestChunk(t, stub, contract, "upload-1", index, chunks[index])
+		}
+		mustTx(t, stub, func() error { return contract.FinalizeModelUpload(ctx, "upload-1") })
+
+		var loaded []byte
+		mustTx(t, stub, func() error {
+			var err error
+			loaded, err = getModelBytes(ctx, "qc_status")
+			return err
+		})
+		if !bytes.Equal(loaded, model) {
+			t.Fatalf("expected %q, got %q", model, loaded)
+		}
+
+		// Toda leitura remonta o modelo a partir dos chunks gravados
+		chunkKey, err := stub.CreateCompositeKey("modelo~chunk", []string{"upload-1", "000001"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustTx(t, stub, func() error { return stub.DelState(chunkKey) })
+		err = inTx(t, stub, func() error {
+			_, err := getModelBytes(ctx, "qc_status")
+			return err
+		})
+		if err == nil || !strings.Contains(err.Error(), "chunk 1") {
+			t.Errorf("expected model to be reassembled from the ledger, got %v", err)
+		}
+	})
+
+	t.Run("missing chunk", func(t *testing.T) {
+		stub := shimtest.NewMockStub("sollytch-chain", nil)
+		ctx := publisherContext(stub)
+
+		beginTestUpload(t, stub, contract, "upload-2", sha)
+		putTestChunk(t, stub, contract, "upload-2", 0, chunks[0])
+		putTestChunk(t, stub, contract, "upload-2", 2, chunks[2])
+
+		err := inTx(t, stub, func() error { return contract.FinalizeModelUpload(ctx, "upload-2") })
+		if err == nil || !strings.Contains(err.Error(), "chunk 1") {
+			t.Fatalf("expected missing chunk 1 to be rejected, got %v", err)
+		}
+		if _, ok := stub.State["qc_status"]; ok {
+			t.Error("model must not be stored when a chunk is missing")
+		}
+	})
+
+	t.Run("hash mismatch", func(t *testing.T) {
+		stub := shimtest.NewMockStub("sollytch-chain", nil)
+		ctx := publisherContext(stub)
+
+		other := sha256.Sum256([]byte("outro modelo"))
+		beginTestUpload(t, stub, contract, "upload-3", hex.EncodeToString(other[:]))
+		for index, chunk := range chunks {
+			putTestChunk(t, stub, contract, "upload-3", index, chunk)
+		}
+
+		err := inTx(t, stub, func() error { return contract.FinalizeModelUpload(ctx, "upload-3") })
+		if err == nil || !strings.Contains(err.Error(), "SHA-256") {
+			t.Fatalf("expected hash mismatch to be rejected, got %v", err)
+		}
+		if _, ok := stub.State["qc_status"]; ok {
+			t.Error("model must not be stored when the hash does not match")
+		}
+	})
+}
+
+func TestModelUploadPermissions(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-chain", nil)
+	contract := new(ModelContract)
+	publisher := publisherContext(stub)
+	chunk := base64.StdEncoding.EncodeToString([]byte("modelo"))
+	sum := sha256.Sum256([]byte("modelo"))
+	sha := hex.EncodeToString(sum[:])
+
+	// Clients without the publisher role cannot start an upload
+	trainer := newTestContext(stub, newIdentity("Org1MSP", "trainer", nil))
+	if err := inTx(t, stub, func() error { return contract.BeginModelUpload(trainer, "upload-1", "qc_status", 1, sha, "") }); err == nil {
+		t.Fatal("expected upload without publisher role to be rejected")
+	}
+
+	mustTx(t, stub, func() error { return contract.BeginModelUpload(publisher, "upload-1", "qc_status", 1, sha, "") })
+
+	// Only the identity that started the upload can send chunks and finalize it
+	others := map[string]*contractapi.TransactionContext{
+		"without role":       trainer,
+		"other publisher":    newTestContext(stub, newIdentity("Org1MSP", "outro", map[string]string{roleAttribute: modelPublisherRole})),
+		"other organisation": newTestContext(stub, newIdentity("Org2MSP", "trainer", map[string]string{roleAttribute: modelPublisherRole})),
+	}
+	for name, ctx := range others {
+		t.Run(name, func(t *testing.T) {
+			if err := inTx(t, stub, func() error { return contract.PutModelChunk(ctx, "upload-1", 0, chunk) }); err == nil {
+				t.Error("expected chunk to be rejected")
+			}
+		})
+	}
+
+	mustTx(t, stub, func() error { return contract.PutModelChunk(publisher, "upload-1", 0, chunk) })
+	for name, ctx := range others {
+		t.Run(name, func(t *testing.T) {
+			if err := inTx(t, stub, func() error { return contract.FinalizeModelUpload(ctx, "upload-1") }); err == nil {
+				t.Error("expected finalization to be rejected")
+			}
+		})
+	}
+	mustTx(t, stub, func() error { return contract.FinalizeModelUpload(publisher, "upload-1") })
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+/*
+	Número opcional dos sensores. Diferente de um float64 comum, mantém a
+	distinção entre um valor ausente (null no JSON) e uma leitura real igual a 0
+*/
+type NullFloat64 struct {
+	Float64 float64
+	Valid   bool
+}
+
+// Função que cria um NullFloat64 válido
+func newNullFloat64(value float64) NullFloat64 {
+	return NullFloat64{Float64: value, Valid: true}
+}
+
+func (nf NullFloat64) MarshalJSON() ([]byte, error) {
+	if !nf.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(nf.Float64)
+}
+
+func (nf *NullFloat64) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*nf = NullFloat64{}
+		return nil
+	}
+
+	var f float64
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	*nf = newNullFloat64(f)
+	return nil
+}
+
+// Função que formata o valor para a linha de predição, ou o marcador de ausente
+func (nf NullFloat64) predictValue() string {
+	if !nf.Valid {
+		return missingValue
+	}
+	return strconv.FormatFloat(nf.Float64, 'f', -1, 64)
+}
+
+// Booleano opcional dos sensores, com a mesma distinção entre null e false
+type NullBool struct {
+	Bool  bool
+	Valid bool
+}
+
+func (nb NullBool) MarshalJSON() ([]byte, error) {
+	if !nb.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(nb.Bool)
+}
+
+func (nb *NullBool) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*nb = NullBool{}
+		return nil
+	}
+
+	var v bool
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*nb = NullBool{Bool: v, Valid: true}
+	return nil
+}
+
+// Função que formata o booleano como 1/0 para a linha de predição, ou o marcador de ausente
+func (nb NullBool) predictValue() string {
+	if !nb.Valid {
+		return missingValue
+	}
+	if nb.Bool {
+		return "1"
+	}
+	return "0"
+}
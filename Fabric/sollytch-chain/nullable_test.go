@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNullableRoundTrip(t *testing.T) {
+	var record TestRecord
+	input := `{"lat":null,"lon":0,"tilt_deg":1.5,"control_line_ok":null,"prefilter_used":false}`
+	if err := json.Unmarshal([]byte(input), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	if record.Lat.Valid {
+		t.Error("expected null lat to be invalid")
+	}
+	if !record.Lon.Valid || record.Lon.Float64 != 0 {
+		t.Errorf("expected real zero lon, got %+v", record.Lon)
+	}
+	if record.ControlLineOK.Valid {
+		t.Error("expected null control_line_ok to be invalid")
+	}
+	if !record.PrefilterUsed.Valid || record.PrefilterUsed.Bool {
+		t.Errorf("expected real false prefilter_used, got %+v", record.PrefilterUsed)
+	}
+	if record.SamplePH.Valid {
+		t.Error("expected absent sample_pH to be invalid")
+	}
+
+	bytes, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(bytes, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["lat"] != nil || fields["sample_pH"] != nil || fields["control_line_ok"] != nil {
+		t.Errorf("expected missing values to be stored as null: %s", bytes)
+	}
+	if fields["lon"] != 0.0 || fields["prefilter_used"] != false {
+		t.Errorf("expected real values to be preserved: %s", bytes)
+	}
+}
+
+func TestBuildPredictRow(t *testing.T) {
+	record := TestRecord{
+		Lat:                   newNullFloat64(-23.5),
+		DistanceMM:            newNullFloat64(12),
+		ControlLineOK:         NullBool{Bool: true, Valid: true},
+		ControleInternoResult: "ok",
+		ExpiryDaysLeft:        30,
+	}
+
+	values := strings.Split(buildPredictRow(&record), ",")
+	columns := strings.Split(baseHeader, ",")
+	if len(values) != len(columns) {
+		t.Fatalf("expected %d columns, got %d", len(columns), len(values))
+	}
+
+	row := map[string]string{}
+	for i, column := range columns {
+		row[column] = values[i]
+	}
+
+	expected := map[string]string{
+		"lat":                     "-23.5",
+		"lon":                     missingValue,
+		"expiry_days_left":        "30",
+		"distance_mm":             "12",
+		"image_blur_score":        missingValue,
+		"control_line_ok":         "1",
+		"controle_interno_result": "2",
+	}
+	for column, value := range expected {
+		if row[column] != value {
+			t.Errorf("expected %s=%s, got %s", column, value, row[column])
+		}
+	}
+}
+
+func TestImputePredictRow(t *testing.T) {
+	record := TestRecord{Lat: newNullFloat64(-23.5)}
+	row := buildPredictRow(&record)
+
+	stats := trainingStatsFixture()
+	filled, imputed := imputePredictRow(stats, row)
+
+	if strings.Contains(filled, missingValue) {
+		t.Errorf("expected no missing markers after imputation: %s", filled)
+	}
+	for _, feature := range imputed {
+		if feature == "lat" {
+			t.Error("expected present lat not to be imputed")
+		}
+	}
+
+	features := parseFeatureRow(filled)
+	if features["lon"] != stats.Features["lon"].Mean {
+		t.Errorf("expected lon to be filled with training mean, got %v", features["lon"])
+	}
+
+	filled, _ = imputePredictRow(nil, row)
+	if parseFeatureRow(filled)["lon"] != 0 {
+		t.Errorf("expected lon to be filled with 0 without training stats: %s", filled)
+	}
+}
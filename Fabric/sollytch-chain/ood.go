@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Modelos consultados, em ordem fixa, na verificação de fora de distribuição
+var oodModelKeys = []string{"acao_recomendada", "result_class", "qc_status"}
+
+/*
+	Função que retorna as features cujo valor está fora da faixa
+	[min, max] observada no treino, na ordem do baseHeader
+*/
+func outOfDistributionFeatures(stats *TrainingStats, features map[string]float64) []string {
+	offending := []string{}
+
+	for _, column := range strings.Split(baseHeader, ",") {
+		training, ok := stats.Features[column]
+		if !ok {
+			continue
+		}
+
+		value, ok := features[column]
+		if !ok {
+			continue
+		}
+
+		if value < training.Min || value > training.Max {
+			offending = append(offending, column)
+		}
+	}
+
+	return offending
+}
+
+/*
+	Função que marca o teste como fora de distribuição quando alguma feature
+	está fora das faixas de treino dos modelos usados. Se o modelo qc_status
+	define uma política (max_ood_features), o qc_status é forçado para o
+	valor de revisão quando o limite é ultrapassado
+*/
+func applyOutOfDistribution(ctx contractapi.TransactionContextInterface, record *TestRecord, predictStr string) error {
+	features := parseFeatureRow(predictStr)
+
+	seen := map[string]bool{}
+	record.OODFeatures = []string{}
+
+	for _, modelKey := range oodModelKeys {
+		model, err := getModel(ctx, modelKey)
+		if err != nil {
+			return err
+		}
+		if model.TrainingStats == nil {
+			continue
+		}
+
+		for _, feature := range outOfDistributionFeatures(model.TrainingStats, features) {
+			if !seen[feature] {
+				seen[feature] = true
+				record.OODFeatures = append(record.OODFeatures, feature)
+			}
+		}
+	}
+
+	record.OutOfDistribution = len(record.OODFeatures) > 0
+
+	// Aplica a política de revisão definida junto ao modelo de qc_status
+	qcModel, err := getModel(ctx, "qc_status")
+	if err != nil {
+		return err
+	}
+
+	policy := qcModel.TrainingStats
+	if policy != nil && policy.MaxOODFeatures > 0 && len(record.OODFeatures) > policy.MaxOODFeatures {
+		record.QCStatus = policy.OODQCStatus
+		record.QCStatusForced = true
+	}
+
+	return nil
+}
+
+/*
+	Função que compara o resultado informado pelo cliente com as predições e
+	só depois aplica a verificação de fora de distribuição. A comparação usa
+	o qc_status previsto pelo modelo, para que um valor forçado pela política
+	de revisão não seja registrado como divergência do leitor
+*/
+func checkPredictions(ctx contractapi.TransactionContextInterface, record *TestRecord, predictStr string) error {
+	// Marca divergências entre o resultado informado e o previsto
+	record.Discrepancies = compareReported(record)
+	record.HasDiscrepancy = len(record.Discrepancies) > 0
+
+	// Marca features fora das faixas de treino e aplica a política de revisão
+	return applyOutOfDistribution(ctx, record, predictStr)
+}
+
+/*
+	Função que retorna as estatísticas de treino usadas para preencher
+	features ausentes: as do primeiro modelo, na ordem de oodModelKeys,
+	que as possuir. Retorna nil quando nenhum modelo tem estatísticas
+*/
+func imputationStats(ctx contractapi.TransactionContextInterface) (*TrainingStats, error) {
+	for _, modelKey := range oodModelKeys {
+		model, err := getModel(ctx, modelKey)
+		if err != nil {
+			return nil, err
+		}
+		if model.TrainingStats != nil {
+			return model.TrainingStats, nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// struct json do operador registrado
+type Operator struct {
+	//trackers
+	Version       int    `json:"version"`
+	LastUpdatedAt string `json:"last_updated_at"`
+	CreatedAt     string `json:"created_at"`
+
+	//chaves de busca
+	OperatorID  string `json:"operator_id"`
+	OperatorDID string `json:"operator_did"`
+
+	//conteudo
+	OrgMSP         string          `json:"org_msp"`
+	PublicKey      string          `json:"public_key"`
+	Certifications []Certification `json:"certifications"`
+}
+
+// Certificação de um operador para um tipo de matriz
+type Certification struct {
+	MatrixType  string `json:"matrix_type"`
+	CertifiedAt string `json:"certified_at"`
+	CertifiedBy string `json:"certified_by"`
+	ExpiresAt   string `json:"expires_at"`
+}
+
+// Certificação que vence dentro do período consultado
+type ExpiringCertification struct {
+	OperatorID  string `json:"operator_id"`
+	OperatorDID string `json:"operator_did"`
+	OrgMSP      string `json:"org_msp"`
+	MatrixType  string `json:"matrix_type"`
+	ExpiresAt   string `json:"expires_at"`
+	DaysLeft    int    `json:"days_left"`
+}
+
+// Papel exigido para certificar operadores
+const certifierRole = "certifier"
+
+// Função que monta a chave de estado de um operador
+func operatorKey(ctx contractapi.TransactionContextInterface, operatorID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("operador", []string{operatorID})
+}
+
+/*
+	Função responsável por registrar ou atualizar um operador no ledger
+	Exige o papel "admin". A organização do cliente passa a ser a
+	organização do operador, e somente ela pode alterar o registro.
+	Certificações informadas no JSON são ignoradas (use CertifyOperator).
+	Um índice "did~operador" permite localizar o operador a partir do seu DID
+*/
+func (r *RegistryContract) RegisterOperator(ctx contractapi.TransactionContextInterface, operatorJSON string) error {
+	// Garante que o cliente é um administrador
+	if err := requireRole(ctx, adminRole); err != nil {
+		return err
+	}
+
+	var operator Operator
+	if err := json.Unmarshal([]byte(operatorJSON), &operator); err != nil {
+		return fmt.Errorf("erro ao decodificar JSON: %v", err)
+	}
+
+	if operator.OperatorID == "" || operator.OperatorDID == "" {
+		return fmt.Errorf("operator_id e operator_did são obrigatórios")
+	}
+	if _, err := parsePublicKeyPEM(operator.PublicKey); err != nil {
+		return err
+	}
+
+	mspID, err := callerMSP(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+	timestamp := now.Format(time.RFC3339)
+
+	key, err := operatorKey(ctx, operator.OperatorID)
+	if err != nil {
+		return err
+	}
+
+	existingBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return err
+	}
+
+	if existingBytes != nil {
+		var existing Operator
+		if err := json.Unmarshal(existingBytes, &existing); err != nil {
+			return err
+		}
+
+		if existing.OrgMSP != mspID {
+			return fmt.Errorf("operador %s pertence a %s", operator.OperatorID, existing.OrgMSP)
+		}
+		if existing.OperatorDID != operator.OperatorDID {
+			return fmt.Errorf("operator_did do operador %s não pode ser alterado", operator.OperatorID)
+		}
+
+		// Certificações só são alteradas por CertifyOperator
+		operator.Certifications = existing.Certifications
+		operator.Version = existing.Version + 1
+		operator.CreatedAt = existing.CreatedAt
+	} else {
+		// O DID não pode estar associado a outro operador
+		if _, err := getOperatorByDID(ctx, operator.OperatorDID); err == nil {
+			return fmt.Errorf("operator_did %s ja registrado", operator.OperatorDID)
+		}
+
+		indexKey, err := ctx.GetStub().CreateCompositeKey(
+			"did~operador",
+			[]string{operator.OperatorDID, operator.OperatorID},
+		)
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.GetStub().PutState(indexKey, []byte{0x00}); err != nil {
+			return err
+		}
+
+		operator.Certifications = []Certification{}
+		operator.Version = 0
+		operator.CreatedAt = timestamp
+	}
+
+	operator.OrgMSP = mspID
+	operator.LastUpdatedAt = timestamp
+
+	bytes, err := json.Marshal(operator)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, bytes)
+}
+
+// Função que carrega um operador do ledger pelo seu ID
+func getOperator(ctx contractapi.TransactionContextInterface, operatorID string) (*Operator, error) {
+	if operatorID == "" {
+		return nil, fmt.Errorf("operatorID não pode ser vazio")
+	}
+
+	key, err := operatorKey(ctx, operatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao acessar o ledger: %v", err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("operador %s não registrado", operatorID)
+	}
+
+	var operator Operator
+	if err := json.Unmarshal(data, &operator); err != nil {
+		return nil, fmt.Errorf("erro ao deserializar operador: %v", err)
+	}
+
+	return &operator, nil
+}
+
+// Função que consulta um operador pelo seu ID
+func (r *RegistryContract) GetOperator(ctx contractapi.TransactionContextInterface, operatorID string) (*Operator, error) {
+	return getOperator(ctx, operatorID)
+}
+
+// Função que carrega um operador do ledger pelo seu DID, via índice "did~operador"
+func getOperatorByDID(ctx contractapi.TransactionContextInterface, operatorDID string) (*Operator, error) {
+	if operatorDID == "" {
+		return nil, fmt.Errorf("operatorDID não pode ser vazio")
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(
+		"did~operador",
+		[]string{operatorDID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	if !iterator.HasNext() {
+		return nil, fmt.Errorf("operador com DID %s não registrado", operatorDID)
+	}
+
+	response, err := iterator.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	_, parts, err := ctx.GetStub().SplitCompositeKey(response.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return getOperator(ctx, parts[1])
+}
+
+/*
+	Função que consulta um operador a partir do seu DID
+	Utiliza o índice "did~operador" para localizar o operatorID
+*/
+func (r *RegistryContract) GetOperatorByDID(ctx contractapi.TransactionContextInterface, operatorDID string) (*Operator, error) {
+	return getOperatorByDID(ctx, operatorDID)
+}
+
+/*
+	Função que certifica (ou renova a certificação de) um operador para um
+	tipo de matriz até a data de vencimento informada. Exige o papel
+	"certifier" e que o cliente seja da mesma organização do operador
+*/
+func (r *RegistryContract) CertifyOperator(ctx contractapi.TransactionContextInterface, operatorID string, matrixType string, expiresAt string) error {
+	if err := requireRole(ctx, certifierRole); err != nil {
+		return err
+	}
+
+	if matrixType == "" {
+		return fmt.Errorf("matrixType não pode ser vazio")
+	}
+
+	operator, err := getOperator(ctx, operatorID)
+	if err != nil {
+		return err
+	}
+
+	mspID, err := callerMSP(ctx)
+	if err != nil {
+		return err
+	}
+	if operator.OrgMSP != mspID {
+		return fmt.Errorf("operador %s pertence a %s", operatorID, operator.OrgMSP)
+	}
+
+	expiry, err := parseDate(expiresAt)
+	if err != nil {
+		return fmt.Errorf("expiresAt: %v", err)
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+	if !expiry.After(now) {
+		return fmt.Errorf("expiresAt deve ser posterior à data da transação")
+	}
+
+	certifiedBy, err := callerID(ctx)
+	if err != nil {
+		return err
+	}
+
+	timestamp := now.Format(time.RFC3339)
+	certification := Certification{
+		MatrixType:  matrixType,
+		CertifiedAt: timestamp,
+		CertifiedBy: certifiedBy,
+		ExpiresAt:   expiry.Format(time.RFC3339),
+	}
+
+	// Substitui a certificação existente para a matriz, se houver
+	replaced := false
+	for i := range operator.Certifications {
+		if operator.Certifications[i].MatrixType == matrixType {
+			operator.Certifications[i] = certification
+			replaced = true
+		}
+	}
+	if !replaced {
+		operator.Certifications = append(operator.Certifications, certification)
+	}
+
+	operator.Version++
+	operator.LastUpdatedAt = timestamp
+
+	key, err := operatorKey(ctx, operatorID)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.Marshal(operator)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, bytes)
+}
+
+/*
+	Função que rejeita testes cujo operador não está registrado ou não
+	possui certificação válida para o matrix_type do teste na data da transação
+*/
+func checkOperatorCertification(ctx contractapi.TransactionContextInterface, record *TestRecord, now time.Time) error {
+	if record.OperatorID == "" {
+		return fmt.Errorf("operator_id é obrigatório")
+	}
+
+	operator, err := getOperator(ctx, record.OperatorID)
+	if err != nil {
+		return err
+	}
+
+	if record.OperatorDID != operator.OperatorDID {
+		return fmt.Errorf("operator_did %s não corresponde ao operador %s", record.OperatorDID, record.OperatorID)
+	}
+
+	for _, certification := range operator.Certifications {
+		if certification.MatrixType != record.MatrixType {
+			continue
+		}
+
+		expiry, err := parseDate(certification.ExpiresAt)
+		if err != nil {
+			return err
+		}
+		if now.Before(expiry) {
+			return nil
+		}
+
+		return fmt.Errorf("certificação do operador %s para %s venceu em %s", record.OperatorID, record.MatrixType, certification.ExpiresAt)
+	}
+
+	return fmt.Errorf("operador %s não certificado para matrix_type %s", record.OperatorID, record.MatrixType)
+}
+
+/*
+	Função que lista as certificações que vencem nos próximos N dias,
+	contados a partir do timestamp da transação. Certificações já vencidas
+	não são incluídas. O resultado é ordenado pela data de vencimento
+*/
+func (r *RegistryContract) GetExpiringCertifications(ctx contractapi.TransactionContextInterface, days int) ([]*ExpiringCertification, error) {
+	if days < 0 {
+		return nil, fmt.Errorf("days não pode ser negativo")
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	limit := now.Add(time.Duration(days) * 24 * time.Hour)
+
+	// Percorre todos os operadores registrados
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("operador", []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	results := []*ExpiringCertification{}
+
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var operator Operator
+		if err := json.Unmarshal(response.Value, &operator); err != nil {
+			return nil, err
+		}
+
+		for _, certification := range operator.Certifications {
+			expiry, err := parseDate(certification.ExpiresAt)
+			if err != nil {
+				return nil, err
+			}
+
+			if !expiry.After(now) || expiry.After(limit) {
+				continue
+			}
+
+			results = append(results, &ExpiringCertification{
+				OperatorID:  operator.OperatorID,
+				OperatorDID: operator.OperatorDID,
+				OrgMSP:      operator.OrgMSP,
+				MatrixType:  certification.MatrixType,
+				ExpiresAt:   certification.ExpiresAt,
+				DaysLeft:    int(math.Floor(expiry.Sub(now).Hours() / 24)),
+			})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].ExpiresAt < results[j].ExpiresAt
+	})
+
+	return results, nil
+}
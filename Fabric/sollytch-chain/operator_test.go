@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+// Registra o operador OP04 (Org1MSP) com uma chave nova
+func registerTestOperator(t *testing.T, stub *shimtest.MockStub) {
+	t.Helper()
+	_, publicKey := newSigningKey(t)
+	operatorJSON, err := json.Marshal(Operator{OperatorID: "OP04", OperatorDID: "did:bio:OP04", PublicKey: publicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := newTestContext(stub, newIdentity("Org1MSP", "admin", map[string]string{roleAttribute: adminRole}))
+	mustTx(t, stub, func() error { return new(RegistryContract).RegisterOperator(admin, string(operatorJSON)) })
+}
+
+func TestRegisterOperatorRequiresAdmin(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-chain", nil)
+	registry := new(RegistryContract)
+	_, publicKey := newSigningKey(t)
+	operatorJSON, err := json.Marshal(Operator{OperatorID: "OP04", OperatorDID: "did:bio:OP04", PublicKey: publicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operator := newTestContext(stub, newIdentity("Org1MSP", "OP04", nil))
+	if err := inTx(t, stub, func() error { return registry.RegisterOperator(operator, string(operatorJSON)) }); err == nil {
+		t.Fatal("expected operator registration without admin role to be rejected")
+	}
+
+	admin := newTestContext(stub, newIdentity("Org1MSP", "admin", map[string]string{roleAttribute: adminRole}))
+	mustTx(t, stub, func() error { return registry.RegisterOperator(admin, string(operatorJSON)) })
+
+	// An admin of another organisation cannot take over the operator
+	otherAdmin := newTestContext(stub, newIdentity("Org2MSP", "admin", map[string]string{roleAttribute: adminRole}))
+	if err := inTx(t, stub, func() error { return registry.RegisterOperator(otherAdmin, string(operatorJSON)) }); err == nil {
+		t.Error("expected update from another organisation to be rejected")
+	}
+}
+
+func TestOperatorCertifications(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-chain", nil)
+	registry := new(RegistryContract)
+	registerTestOperator(t, stub)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	soon := now.Add(5 * 24 * time.Hour)
+	later := now.Add(60 * 24 * time.Hour)
+
+	certifier := newTestContext(stub, newIdentity("Org1MSP", "certifier", map[string]string{roleAttribute: certifierRole}))
+	mustTx(t, stub, func() error { return registry.CertifyOperator(certifier, "OP04", "solo", soon.Format(time.RFC3339)) })
+	mustTx(t, stub, func() error { return registry.CertifyOperator(certifier, "OP04", "agua", later.Format(time.RFC3339)) })
+
+	record := &TestRecord{OperatorID: "OP04", OperatorDID: "did:bio:OP04"}
+	check := func(matrixType string, at time.Time) error {
+		record.MatrixType = matrixType
+		return inTxAt(t, stub, at, func() error { return checkOperatorCertification(certifier, record, at) })
+	}
+
+	// Valid and expiring-soon certifications are accepted until they expire
+	if err := check("agua", now); err != nil {
+		t.Errorf("expected valid certification to be accepted: %v", err)
+	}
+	if err := check("solo", now); err != nil {
+		t.Errorf("expected certification expiring soon to be accepted: %v", err)
+	}
+
+	// Expired certifications and uncertified matrices are rejected
+	if err := check("solo", soon.Add(time.Hour)); err == nil || !strings.Contains(err.Error(), "venceu") {
+		t.Errorf("expected expired certification to be rejected, got %v", err)
+	}
+	if err := check("ar", now); err == nil {
+		t.Error("expected uncertified matrix type to be rejected")
+	}
+
+	record.OperatorDID = "did:bio:OUTRO"
+	if err := check("agua", now); err == nil {
+		t.Error("expected mismatched operator_did to be rejected")
+	}
+
+	// Only the certification expiring within the window is listed
+	var expiring []*ExpiringCertification
+	err := inTxAt(t, stub, now, func() (err error) {
+		expiring, err = registry.GetExpiringCertifications(certifier, 7)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expiring) != 1 || expiring[0].MatrixType != "solo" || expiring[0].DaysLeft != 5 {
+		t.Fatalf("expected only solo expiring in 5 days, got %+v", expiring)
+	}
+
+	// Once expired, a certification is no longer listed as expiring
+	err = inTxAt(t, stub, soon.Add(time.Hour), func() (err error) {
+		expiring, err = registry.GetExpiringCertifications(certifier, 60)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expiring) != 1 || expiring[0].MatrixType != "agua" {
+		t.Errorf("expected only agua to be listed after solo expired, got %+v", expiring)
+	}
+}
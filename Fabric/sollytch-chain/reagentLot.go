@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Papel exigido para registrar lotes de reagente
+const supplierRole = "supplier"
+
+// struct json do lote de reagente registrado pelo fornecedor
+type ReagentLot struct {
+	//trackers
+	Version       int    `json:"version"`
+	LastUpdatedAt string `json:"last_updated_at"`
+	CreatedAt     string `json:"created_at"`
+	SupplierMSP   string `json:"supplier_msp"`
+
+	//chave de busca
+	LotID string `json:"lot_id"`
+
+	//conteudo
+	Manufacturer        string  `json:"manufacturer"`
+	ManufactureDate     string  `json:"manufacture_date"`
+	ExpiryDate          string  `json:"expiry_date"`
+	StorageRequirements string  `json:"storage_requirements"`
+	StorageMinTempC     float64 `json:"storage_min_temp_C"`
+	StorageMaxTempC     float64 `json:"storage_max_temp_C"`
+}
+
+// Função que monta a chave de estado de um lote de reagente
+func reagentLotKey(ctx contractapi.TransactionContextInterface, lotID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("reagente", []string{lotID})
+}
+
+/*
+	Função responsável por registrar ou atualizar um lote de reagente no ledger
+	Apenas clientes com o papel "supplier" podem registrar lotes, e um lote
+	já existente só pode ser alterado pela mesma organização que o registrou
+*/
+func (r *RegistryContract) RegisterReagentLot(ctx contractapi.TransactionContextInterface, lotJSON string) error {
+	// Garante que o cliente é um fornecedor
+	if err := requireRole(ctx, supplierRole); err != nil {
+		return err
+	}
+
+	mspID, err := callerMSP(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Converte o JSON recebido para struct
+	var lot ReagentLot
+	if err := json.Unmarshal([]byte(lotJSON), &lot); err != nil {
+		return fmt.Errorf("erro ao decodificar JSON: %v", err)
+	}
+
+	// Valida campos obrigatórios e datas
+	if lot.LotID == "" || lot.Manufacturer == "" {
+		return fmt.Errorf("lot_id e manufacturer são obrigatórios")
+	}
+
+	manufactured, err := parseDate(lot.ManufactureDate)
+	if err != nil {
+		return fmt.Errorf("manufacture_date: %v", err)
+	}
+
+	expiry, err := parseDate(lot.ExpiryDate)
+	if err != nil {
+		return fmt.Errorf("expiry_date: %v", err)
+	}
+
+	if !expiry.After(manufactured) {
+		return fmt.Errorf("expiry_date deve ser posterior a manufacture_date")
+	}
+
+	if lot.StorageMinTempC > lot.StorageMaxTempC {
+		return fmt.Errorf("storage_min_temp_C não pode ser maior que storage_max_temp_C")
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+	timestamp := now.Format(time.RFC3339)
+
+	key, err := reagentLotKey(ctx, lot.LotID)
+	if err != nil {
+		return err
+	}
+
+	// Verifica se o lote já foi registrado anteriormente
+	existingBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return err
+	}
+
+	if existingBytes != nil {
+		var existing ReagentLot
+		if err := json.Unmarshal(existingBytes, &existing); err != nil {
+			return err
+		}
+
+		// Somente a organização que registrou o lote pode alterá-lo
+		if existing.SupplierMSP != mspID {
+			return fmt.Errorf("lote %s pertence a %s", lot.LotID, existing.SupplierMSP)
+		}
+
+		lot.Version = existing.Version + 1
+		lot.CreatedAt = existing.CreatedAt
+	} else {
+		lot.Version = 0
+		lot.CreatedAt = timestamp
+	}
+
+	lot.SupplierMSP = mspID
+	lot.LastUpdatedAt = timestamp
+
+	bytes, err := json.Marshal(lot)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, bytes)
+}
+
+// Função que carrega um lote de reagente do ledger pelo seu ID
+func getReagentLot(ctx contractapi.TransactionContextInterface, lotID string) (*ReagentLot, error) {
+	if lotID == "" {
+		return nil, fmt.Errorf("lotID não pode ser vazio")
+	}
+
+	key, err := reagentLotKey(ctx, lotID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao acessar o ledger: %v", err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("lote de reagente %s não registrado", lotID)
+	}
+
+	var lot ReagentLot
+	if err := json.Unmarshal(data, &lot); err != nil {
+		return nil, fmt.Errorf("erro ao deserializar lote de reagente: %v", err)
+	}
+
+	return &lot, nil
+}
+
+/*
+	Função que consulta um lote de reagente pelo seu ID
+	Retorna erro caso o lote não tenha sido registrado
+*/
+func (r *RegistryContract) GetReagentLot(ctx contractapi.TransactionContextInterface, lotID string) (*ReagentLot, error) {
+	return getReagentLot(ctx, lotID)
+}
+
+/*
+	Função que aplica as regras de validade do reagente a um teste
+	Rejeita testes com lote de reagente não registrado, recalcula
+	expiry_days_left a partir do timestamp da transação (descartando o
+	valor informado pelo cliente) e marca testes feitos com reagente vencido
+*/
+func applyReagentLot(ctx contractapi.TransactionContextInterface, record *TestRecord, now time.Time) error {
+	if record.ReagentLot == "" {
+		return fmt.Errorf("reagent_lot é obrigatório")
+	}
+
+	lot, err := getReagentLot(ctx, record.ReagentLot)
+	if err != nil {
+		return err
+	}
+
+	expiry, err := parseDate(lot.ExpiryDate)
+	if err != nil {
+		return err
+	}
+
+	// Dias restantes até o vencimento, negativo quando já vencido
+	record.ExpiryDaysLeft = int(math.Floor(expiry.Sub(now).Hours() / 24))
+	record.ReagentExpired = !now.Before(expiry)
+
+	return nil
+}
+
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Estados da revisão de um teste
+const (
+	reviewPending  = "pendente_revisao"
+	reviewApproved = "aprovado"
+	reviewRejected = "rejeitado"
+	reviewAmended  = "alterado"
+)
+
+// Ações registradas no histórico de revisão
+const (
+	reviewActionAccept  = "revisao_aceita"
+	reviewActionReject  = "revisao_rejeitada"
+	reviewActionApprove = "aprovacao"
+)
+
+// Papel exigido para revisar e aprovar testes
+const reviewerRole = "reviewer"
+
+// Atributo do certificado que associa o cliente a um operador registrado
+const operatorAttribute = "sollytch.operator_id"
+
+// Entrada do histórico de revisão de um teste
+type ReviewEntry struct {
+	Action     string `json:"action"`
+	By         string `json:"by"`
+	OperatorID string `json:"operator_id,omitempty"`
+	Comments   string `json:"comments"`
+	At         string `json:"at"`
+	// versão do teste que foi revisada
+	TestVersion int `json:"test_version"`
+}
+
+/*
+	Função que carrega um teste e garante que o cliente pode atuar como
+	revisor dele: possui o papel "reviewer" e o atributo
+	"sollytch.operator_id", não é o operador que executou o teste, não foi
+	quem o submeteu e não foi quem fez a última alteração via UpdateTest
+*/
+func loadTestForReview(ctx contractapi.TransactionContextInterface, testID string) (*TestRecord, string, string, error) {
+	if err := requireRole(ctx, reviewerRole); err != nil {
+		return nil, "", "", err
+	}
+
+	record, err := getTestRecord(ctx, testID)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	reviewer, err := callerID(ctx)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	// Sem o atributo não é possível descartar que o revisor seja o operador
+	operatorID, found, err := ctx.GetClientIdentity().GetAttributeValue(operatorAttribute)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if !found || operatorID == "" {
+		return nil, "", "", fmt.Errorf("acesso negado: cliente não possui o atributo %s", operatorAttribute)
+	}
+
+	if operatorID == record.OperatorID {
+		return nil, "", "", fmt.Errorf("o operador %s não pode revisar o próprio teste", operatorID)
+	}
+	if reviewer == record.SubmittedBy {
+		return nil, "", "", fmt.Errorf("quem submeteu o teste não pode revisá-lo")
+	}
+	if reviewer == record.LastUpdatedBy {
+		return nil, "", "", fmt.Errorf("quem alterou o teste por último não pode revisá-lo")
+	}
+
+	return record, reviewer, operatorID, nil
+}
+
+// Função que grava o teste revisado no ledger
+func putReviewedTest(ctx contractapi.TransactionContextInterface, record *TestRecord) error {
+	bytes, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(record.TestID, bytes)
+}
+
+/*
+	Função que registra a revisão de um teste pendente
+	Uma revisão aceita mantém o teste pendente, aguardando a aprovação por
+	uma segunda pessoa; uma revisão rejeitada encerra o teste como rejeitado
+	até que ele seja corrigido via UpdateTest
+*/
+func (t *TestContract) ReviewTest(ctx contractapi.TransactionContextInterface, testID string, accepted bool, comments string) error {
+	record, reviewer, operatorID, err := loadTestForReview(ctx, testID)
+	if err != nil {
+		return err
+	}
+
+	if record.ReviewStatus != reviewPending && record.ReviewStatus != reviewAmended {
+		return fmt.Errorf("teste %s não está pendente de revisão (%s)", testID, record.ReviewStatus)
+	}
+
+	if !accepted && comments == "" {
+		return fmt.Errorf("comments é obrigatório ao rejeitar um teste")
+	}
+
+	// Cada pessoa revisa uma mesma versão do teste uma única vez
+	for _, entry := range record.Reviews {
+		if entry.By == reviewer && entry.TestVersion == record.Version {
+			return fmt.Errorf("teste %s ja revisado por este cliente na versão %d", testID, record.Version)
+		}
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	action := reviewActionAccept
+	if !accepted {
+		action = reviewActionReject
+		record.ReviewStatus = reviewRejected
+	}
+
+	record.Reviews = append(record.Reviews, ReviewEntry{
+		Action:      action,
+		By:          reviewer,
+		OperatorID:  operatorID,
+		Comments:    comments,
+		At:          now.Format(time.RFC3339),
+		TestVersion: record.Version,
+	})
+
+	return putReviewedTest(ctx, record)
+}
+
+/*
+	Função que aprova (assina) um teste já revisado
+	Exige uma revisão aceita na versão atual do teste, feita por outra
+	pessoa: o aprovador não pode ser o revisor, o operador do teste nem
+	quem fez a última alteração
+*/
+func (t *TestContract) ApproveTest(ctx contractapi.TransactionContextInterface, testID string, comments string) error {
+	record, approver, operatorID, err := loadTestForReview(ctx, testID)
+	if err != nil {
+		return err
+	}
+
+	if record.ReviewStatus != reviewPending && record.ReviewStatus != reviewAmended {
+		return fmt.Errorf("teste %s não está pendente de revisão (%s)", testID, record.ReviewStatus)
+	}
+
+	// Procura uma revisão aceita da versão atual feita por outra pessoa
+	reviewed := false
+	for _, entry := range record.Reviews {
+		if entry.Action != reviewActionAccept || entry.TestVersion != record.Version {
+			continue
+		}
+		if entry.By == approver {
+			return fmt.Errorf("o aprovador não pode ser o mesmo revisor do teste")
+		}
+		reviewed = true
+	}
+	if !reviewed {
+		return fmt.Errorf("teste %s não possui revisão aceita na versão %d", testID, record.Version)
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	record.ReviewStatus = reviewApproved
+	record.Reviews = append(record.Reviews, ReviewEntry{
+		Action:      reviewActionApprove,
+		By:          approver,
+		OperatorID:  operatorID,
+		Comments:    comments,
+		At:          now.Format(time.RFC3339),
+		TestVersion: record.Version,
+	})
+
+	return putReviewedTest(ctx, record)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+// Revisor com o papel "reviewer" associado ao operador informado
+func newReviewer(id string, operatorID string) *fakeIdentity {
+	attrs := map[string]string{roleAttribute: reviewerRole}
+	if operatorID != "" {
+		attrs[operatorAttribute] = operatorID
+	}
+	return newIdentity("Org1MSP", id, attrs)
+}
+
+func TestReviewWorkflow(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-chain", nil)
+	contract := new(TestContract)
+
+	stored := TestRecord{
+		SchemaVersion: currentTestSchema,
+		TestID:        "TEST-00001",
+		OperatorID:    "OP04",
+		ReviewStatus:  reviewPending,
+		Reviews:       []ReviewEntry{},
+		SubmittedBy:   "Org1MSP:submitter",
+	}
+	bytes, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustTx(t, stub, func() error { return stub.PutState(stored.TestID, bytes) })
+
+	review := func(identity *fakeIdentity) error {
+		return inTx(t, stub, func() error {
+			return contract.ReviewTest(newTestContext(stub, identity), stored.TestID, true, "ok")
+		})
+	}
+	approve := func(identity *fakeIdentity) error {
+		return inTx(t, stub, func() error {
+			return contract.ApproveTest(newTestContext(stub, identity), stored.TestID, "ok")
+		})
+	}
+
+	// Whoever submitted the test cannot review or approve it
+	submitter := newReviewer("submitter", "OP07")
+	if err := review(submitter); err == nil {
+		t.Error("expected submitter to be rejected as reviewer")
+	}
+
+	// Reviewers must carry the operator attribute
+	if err := review(newReviewer("anonymous", "")); err == nil {
+		t.Error("expected reviewer without operator attribute to be rejected")
+	}
+
+	// The operator who ran the test cannot review it
+	if err := review(newReviewer("op04", "OP04")); err == nil {
+		t.Error("expected operator to be rejected as reviewer of their own test")
+	}
+
+	first := newReviewer("first", "OP05")
+	if err := review(first); err != nil {
+		t.Fatalf("expected review to be accepted: %v", err)
+	}
+
+	// The same person cannot review the same version twice or approve their own review
+	if err := review(first); err == nil {
+		t.Error("expected double review to be rejected")
+	}
+	if err := approve(first); err == nil {
+		t.Error("expected reviewer to be rejected as approver")
+	}
+	if err := approve(submitter); err == nil {
+		t.Error("expected submitter to be rejected as approver")
+	}
+
+	if err := approve(newReviewer("second", "OP06")); err != nil {
+		t.Fatalf("expected approval by a second person: %v", err)
+	}
+
+	record, err := getTestRecord(newTestContext(stub, first), stored.TestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ReviewStatus != reviewApproved || len(record.Reviews) != 2 {
+		t.Errorf("expected approved test with 2 review entries, got %s with %d", record.ReviewStatus, len(record.Reviews))
+	}
+}
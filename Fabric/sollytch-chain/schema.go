@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+/*
+	Versões do esquema do TestRecord gravado no ledger
+	- 1: layout original da planilha (registros sem schema_version)
+	- 2: registros com revisão, divergências, assinatura e sinalização
+	     de fora de distribuição
+	- 3: campos opcionais dos sensores gravados como null quando ausentes,
+	     com as features imputadas na predição
+*/
+const (
+	testSchemaV1      = 1
+	testSchemaV2      = 2
+	testSchemaV3      = 3
+	currentTestSchema = testSchemaV3
+)
+
+// Decodificadores por versão; cada um devolve o registro no esquema atual
+var testDecoders = map[int]func([]byte) (*TestRecord, error){
+	testSchemaV1: decodeTestV1,
+	testSchemaV2: decodeTestV2,
+	testSchemaV3: decodeTestV3,
+}
+
+// Resultado de uma página de migração de testes
+type MigrationResult struct {
+	Scanned  int    `json:"scanned"`
+	Migrated int    `json:"migrated"`
+	Bookmark string `json:"bookmark"`
+	Done     bool   `json:"done"`
+}
+
+/*
+	Função que decodifica um teste gravado em qualquer versão do esquema
+	Lê o schema_version (ausente nos registros antigos, tratados como v1)
+	e usa o decodificador correspondente
+*/
+func decodeTestRecord(data []byte) (*TestRecord, error) {
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+
+	version := header.SchemaVersion
+	if version == 0 {
+		version = testSchemaV1
+	}
+
+	decoder, ok := testDecoders[version]
+	if !ok {
+		return nil, fmt.Errorf("schema_version %d não suportado", version)
+	}
+
+	return decoder(data)
+}
+
+/*
+	Decodificador do esquema v1
+	Registros antigos nunca passaram por revisão: ficam pendentes, com as
+	listas vazias em vez de nulas
+*/
+func decodeTestV1(data []byte) (*TestRecord, error) {
+	var record TestRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+
+	if record.ReviewStatus == "" {
+		record.ReviewStatus = reviewPending
+	}
+	if record.Reviews == nil {
+		record.Reviews = []ReviewEntry{}
+	}
+	if record.Discrepancies == nil {
+		record.Discrepancies = []Discrepancy{}
+	}
+	if record.OODFeatures == nil {
+		record.OODFeatures = []string{}
+	}
+
+	upgradeLegacyNulls(&record)
+	return &record, nil
+}
+
+// Decodificador do esquema v2
+func decodeTestV2(data []byte) (*TestRecord, error) {
+	var record TestRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+
+	upgradeLegacyNulls(&record)
+	return &record, nil
+}
+
+// Decodificador do esquema v3 (atual)
+func decodeTestV3(data []byte) (*TestRecord, error) {
+	var record TestRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+
+	record.SchemaVersion = currentTestSchema
+	return &record, nil
+}
+
+/*
+	Função que adapta registros gravados antes do esquema v3
+	Até o v2 as features numéricas opcionais eram float64, e um null enviado
+	pelo leitor era gravado como 0. Como esse 0 não se distingue de uma
+	leitura real, todas elas passam a null e são imputadas na predição. Os
+	campos booleanos não são alterados: false era também o valor real mais
+	comum, e tratá-lo como ausente descartaria a maioria das leituras
+*/
+func upgradeLegacyNulls(record *TestRecord) {
+	coerced := []*NullFloat64{
+		&record.Lat,
+		&record.Lon,
+		&record.DistanceMM,
+		&record.TimeToMigrateS,
+		&record.SampleVolumeUL,
+		&record.SamplePH,
+		&record.SampleTurbidityNTU,
+		&record.SampleTempC,
+		&record.AmbientTC,
+		&record.AmbientRHPct,
+		&record.LightingLux,
+		&record.TiltDeg,
+		&record.PreincubationTimeS,
+		&record.TimeSinceSamplingMin,
+		&record.ImageBlurScore,
+	}
+	for _, field := range coerced {
+		if field.Valid && field.Float64 == 0 {
+			*field = NullFloat64{}
+		}
+	}
+	if record.ImputedFeatures == nil {
+		record.ImputedFeatures = []string{}
+	}
+
+	record.SchemaVersion = currentTestSchema
+}
+
+// Limite superior das chaves simples percorridas pela migração
+const migrationEndKey = string(utf8.MaxRune)
+
+// Função que monta a chave do cursor da migração de testes
+func migrationCursorKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("migracao~testes", []string{})
+}
+
+/*
+	Função que migra os testes gravados em esquemas antigos para o esquema
+	atual, em páginas, e é restrita a administradores. Percorre as chaves
+	simples do ledger a partir do cursor gravado pela página anterior (a
+	última chave processada), de forma que cada página lê apenas pageSize
+	chaves. Chaves que não são testes (modelos e planilhas) contam para a
+	página, mas não são alteradas. Ao terminar, o cursor é removido para
+	que uma nova migração comece do início
+*/
+func (t *TestContract) MigrateTests(ctx contractapi.TransactionContextInterface, pageSize int) (*MigrationResult, error) {
+	if err := requireRole(ctx, adminRole); err != nil {
+		return nil, err
+	}
+
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("pageSize deve ser positivo")
+	}
+
+	cursorKey, err := migrationCursorKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := ctx.GetStub().GetState(cursorKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// O intervalo inclui a chave inicial, que já foi processada
+	iterator, err := ctx.GetStub().GetStateByRange(string(cursor), migrationEndKey)
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	result := &MigrationResult{Bookmark: string(cursor)}
+
+	for iterator.HasNext() {
+		if result.Scanned == pageSize {
+			return result, ctx.GetStub().PutState(cursorKey, []byte(result.Bookmark))
+		}
+
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		if response.Key == string(cursor) {
+			continue
+		}
+
+		result.Scanned++
+		result.Bookmark = response.Key
+
+		if !isTestRecord(response.Key, response.Value) {
+			continue
+		}
+
+		migrated, err := migrateTestRecord(response.Value)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao migrar teste %s: %v", response.Key, err)
+		}
+		if migrated == nil {
+			continue
+		}
+
+		if err := ctx.GetStub().PutState(response.Key, migrated); err != nil {
+			return nil, err
+		}
+		result.Migrated++
+	}
+
+	result.Done = true
+	return result, ctx.GetStub().DelState(cursorKey)
+}
+
+// Função que identifica um teste entre as chaves simples: o test_id gravado é a própria chave
+func isTestRecord(key string, data []byte) bool {
+	var header struct {
+		TestID string `json:"test_id"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return false
+	}
+
+	return header.TestID == key
+}
+
+/*
+	Função que converte um teste para o esquema atual
+	Retorna nil quando o registro já está no esquema atual
+*/
+func migrateTestRecord(data []byte) ([]byte, error) {
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	if header.SchemaVersion == currentTestSchema {
+		return nil, nil
+	}
+
+	record, err := decodeTestRecord(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(record)
+}
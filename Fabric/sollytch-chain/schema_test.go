@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+func TestDecodeLegacyTestRecord(t *testing.T) {
+	legacy := []byte(`{"version":3,"test_id":"TEST-00001","cassette_lot":"C22009","result_class":"negativo"}`)
+
+	record, err := decodeTestRecord(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record.SchemaVersion != currentTestSchema {
+		t.Errorf("expected schema %d, got %d", currentTestSchema, record.SchemaVersion)
+	}
+	if record.ReviewStatus != reviewPending {
+		t.Errorf("expected legacy record to be pending review, got %q", record.ReviewStatus)
+	}
+	if record.Reviews == nil || record.Discrepancies == nil || record.OODFeatures == nil {
+		t.Error("expected legacy lists to be initialised")
+	}
+	if record.Version != 3 || record.ResultClass != "negativo" {
+		t.Errorf("expected original fields to be preserved: %+v", record)
+	}
+}
+
+func TestDecodeUnknownSchema(t *testing.T) {
+	if _, err := decodeTestRecord([]byte(`{"schema_version":99}`)); err == nil {
+		t.Fatal("expected unsupported schema to be rejected")
+	}
+}
+
+func TestMigrateTestRecord(t *testing.T) {
+	migrated, err := migrateTestRecord([]byte(`{"test_id":"TEST-00001"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var header struct {
+		SchemaVersion int    `json:"schema_version"`
+		ReviewStatus  string `json:"review_status"`
+	}
+	if err := json.Unmarshal(migrated, &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.SchemaVersion != currentTestSchema || header.ReviewStatus != reviewPending {
+		t.Errorf("unexpected migrated record: %s", migrated)
+	}
+
+	again, err := migrateTestRecord(migrated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != nil {
+		t.Error("expected current-schema record to be left untouched")
+	}
+}
+
+func TestMigrateTests(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-chain", nil)
+	admin := newTestContext(stub, newIdentity("Org1MSP", "admin", map[string]string{roleAttribute: adminRole}))
+	contract := new(TestContract)
+
+	legacy := map[string]string{
+		"TEST-00001": `{"test_id":"TEST-00001","cassette_lot":"C22009"}`,
+		"TEST-00002": `{"test_id":"TEST-00002","cassette_lot":"C22009"}`,
+		"TEST-00003": `{"test_id":"TEST-00003","cassette_lot":"C22010"}`,
+		"qc_status":  `{"modelKey":"qc_status","version":1}`,
+	}
+	mustTx(t, stub, func() error {
+		for key, value := range legacy {
+			if err := stub.PutState(key, []byte(value)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	reader := newTestContext(stub, newIdentity("Org1MSP", "reader", nil))
+	if err := inTx(t, stub, func() error {
+		_, err := contract.MigrateTests(reader, 10)
+		return err
+	}); err == nil {
+		t.Fatal("expected migration without the admin role to be rejected")
+	}
+
+	// Cada página continua do cursor gravado pela anterior
+	var first, second *MigrationResult
+	mustTx(t, stub, func() error {
+		var err error
+		first, err = contract.MigrateTests(admin, 2)
+		return err
+	})
+	if first.Scanned != 2 || first.Migrated != 2 || first.Done || first.Bookmark != "TEST-00002" {
+		t.Fatalf("unexpected first page: %+v", first)
+	}
+
+	mustTx(t, stub, func() error {
+		var err error
+		second, err = contract.MigrateTests(admin, 2)
+		return err
+	})
+	if second.Scanned != 2 || second.Migrated != 1 || !second.Done {
+		t.Fatalf("unexpected second page: %+v", second)
+	}
+
+	for key := range legacy {
+		if key == "qc_status" {
+			continue
+		}
+		record, err := decodeTestRecord(stub.State[key])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.SchemaVersion != currentTestSchema {
+			t.Errorf("%s: expected schema %d, got %d", key, currentTestSchema, record.SchemaVersion)
+		}
+	}
+	if string(stub.State["qc_status"]) != legacy["qc_status"] {
+		t.Errorf("expected model to be left untouched, got %s", stub.State["qc_status"])
+	}
+
+	cursorKey, _ := stub.CreateCompositeKey("migracao~testes", []string{})
+	if _, ok := stub.State[cursorKey]; ok {
+		t.Error("expected the migration cursor to be removed when done")
+	}
+}
+
+func TestDecodeLegacyZeros(t *testing.T) {
+	legacy := []byte(`{"schema_version":2,"lat":0,"sample_pH":0,"tilt_deg":0,"image_blur_score":0,"sample_temp_C":21.5,"prefilter_used":false}`)
+
+	record, err := decodeTestRecord(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Até o v2 um null era gravado como 0 em qualquer feature numérica
+	if record.Lat.Valid || record.SamplePH.Valid || record.TiltDeg.Valid || record.ImageBlurScore.Valid {
+		t.Errorf("expected legacy zeros to become null: %+v", record)
+	}
+	if !record.SampleTempC.Valid || record.SampleTempC.Float64 != 21.5 {
+		t.Errorf("expected non-zero reading to be kept, got %+v", record.SampleTempC)
+	}
+	if !record.PrefilterUsed.Valid || record.PrefilterUsed.Bool {
+		t.Errorf("expected legacy false to be kept, got %+v", record.PrefilterUsed)
+	}
+
+	// No esquema atual o 0 é uma leitura real
+	current, err := decodeTestRecord([]byte(`{"schema_version":3,"tilt_deg":0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !current.TiltDeg.Valid {
+		t.Error("expected zero tilt_deg in the current schema to be kept")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDrainingChaincode(t *testing.T) {
+	chaincode := &drainingChaincode{}
+
+	if !chaincode.begin() {
+		t.Fatal("expected transaction to be accepted before draining")
+	}
+
+	if chaincode.drain(10 * time.Millisecond) {
+		t.Error("expected drain to time out with a transaction in flight")
+	}
+	if chaincode.healthy() {
+		t.Error("expected chaincode to report unhealthy while draining")
+	}
+	if chaincode.begin() {
+		t.Error("expected new transactions to be rejected while draining")
+	}
+
+	chaincode.inFlight.Done()
+	if !chaincode.drain(time.Second) {
+		t.Error("expected drain to finish once in-flight transactions complete")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	t.Setenv("SHUTDOWN_TIMEOUT", "")
+	if timeout, err := shutdownTimeout(); err != nil || timeout != defaultShutdownTimeout {
+		t.Errorf("expected default timeout, got %v %v", timeout, err)
+	}
+
+	t.Setenv("SHUTDOWN_TIMEOUT", "abc")
+	if _, err := shutdownTimeout(); err == nil {
+		t.Error("expected invalid timeout to be rejected")
+	}
+}
+
+func TestGetTLSProperties(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"KEY_PATH": "key.pem", "CERT_PATH": "cert.pem", "CA_CERT_PATH": "ca.pem"}
+	for env, name := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(env, path)
+	}
+
+	// TLS is on unless explicitly disabled
+	t.Setenv("TLS_ENABLED", "")
+	props, err := getTLSProperties()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props.Disabled || string(props.Key) != "key.pem" || string(props.Cert) != "cert.pem" || string(props.ClientCACerts) != "ca.pem" {
+		t.Errorf("unexpected TLS properties: %+v", props)
+	}
+
+	t.Setenv("TLS_ENABLED", "false")
+	if props, err := getTLSProperties(); err != nil || !props.Disabled {
+		t.Errorf("expected TLS_ENABLED=false to disable TLS, got %+v %v", props, err)
+	}
+
+	// Missing certificates fail closed instead of falling back to plaintext
+	t.Setenv("TLS_ENABLED", "true")
+	t.Setenv("CA_CERT_PATH", filepath.Join(dir, "missing.pem"))
+	if _, err := getTLSProperties(); err == nil {
+		t.Error("expected a missing CA certificate to be rejected")
+	}
+}
+
+// server.go is copied in both chaincodes (see the comment at its top)
+func TestServerCopyInSync(t *testing.T) {
+	for _, name := range []string{"server.go", "server_test.go"} {
+		chain, err := os.ReadFile(filepath.Join("..", "sollytch-chain", name))
+		if err != nil {
+			t.Skipf("sibling chaincode not available: %v", err)
+		}
+		image, err := os.ReadFile(filepath.Join("..", "sollytch-image", name))
+		if err != nil {
+			t.Skipf("sibling chaincode not available: %v", err)
+		}
+
+		if !bytes.Equal(chain, image) {
+			t.Errorf("%s differs between sollytch-chain and sollytch-image", name)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Quantidade máxima de leituras armazenadas por completo no registro;
+// acima disso apenas o hash da série e o resumo são gravados no ledger
+const maxInlineReadings = 288
+
+// Leitura do registrador de temperatura (data logger)
+type TempReading struct {
+	Timestamp string  `json:"timestamp"`
+	TempC     float64 `json:"temp_C"`
+}
+
+// Intervalo contínuo em que a temperatura ficou fora da faixa permitida
+type Excursion struct {
+	Start       string  `json:"start"`
+	End         string  `json:"end"`
+	DurationMin float64 `json:"duration_min"`
+	PeakC       float64 `json:"peak_C"`
+}
+
+// struct json do transporte de um lote (cadeia de frio)
+type Shipment struct {
+	//trackers
+	Version       int    `json:"version"`
+	LastUpdatedAt string `json:"last_updated_at"`
+	CreatedAt     string `json:"created_at"`
+	RegisteredBy  string `json:"registered_by"`
+
+	//chave de busca
+	ShipmentID string `json:"shipment_id"`
+
+	//conteudo informado
+	ReagentLot         string        `json:"reagent_lot"`
+	CassetteLot        string        `json:"cassette_lot"`
+	Origin             string        `json:"origin"`
+	Destination        string        `json:"destination"`
+	DispatchedAt       string        `json:"dispatched_at"`
+	DeliveredAt        string        `json:"delivered_at"`
+	CondicaoTransporte string        `json:"condicao_transporte"`
+	MaxExcursionMin    float64       `json:"max_excursion_min"`
+	Readings           []TempReading `json:"readings,omitempty"`
+
+	//conteudo calculado no ledger
+	AllowedMinTempC      float64     `json:"allowed_min_temp_C"`
+	AllowedMaxTempC      float64     `json:"allowed_max_temp_C"`
+	ReadingsCount        int         `json:"readings_count"`
+	ReadingsHash         string      `json:"readings_hash"`
+	ObservedMinTempC     float64     `json:"observed_min_temp_C"`
+	ObservedMaxTempC     float64     `json:"observed_max_temp_C"`
+	Excursions           []Excursion `json:"excursions"`
+	ExcursionTotalMin    float64     `json:"excursion_total_min"`
+	CadeiaFrioStatus     bool        `json:"cadeia_frio_status"`
+	TempoTransporteHoras float64     `json:"tempo_transporte_horas"`
+}
+
+// Função que monta a chave de estado de um transporte
+func shipmentKey(ctx contractapi.TransactionContextInterface, shipmentID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("transporte", []string{shipmentID})
+}
+
+/*
+	Função que calcula as excursões de temperatura de uma série de leituras
+	As leituras devem estar ordenadas por timestamp. Uma excursão começa na
+	primeira leitura fora da faixa e termina na primeira leitura que volta
+	para a faixa; caso não volte, dura até a entrega (end)
+*/
+func computeExcursions(readings []TempReading, times []time.Time, minC float64, maxC float64, end time.Time) []Excursion {
+	excursions := []Excursion{}
+
+	var current *Excursion
+	var currentStart time.Time
+
+	for i, reading := range readings {
+		outside := reading.TempC < minC || reading.TempC > maxC
+
+		if outside {
+			if current == nil {
+				current = &Excursion{Start: reading.Timestamp, PeakC: reading.TempC}
+				currentStart = times[i]
+			}
+
+			// Guarda o valor mais distante da faixa permitida
+			if distanceFromRange(reading.TempC, minC, maxC) > distanceFromRange(current.PeakC, minC, maxC) {
+				current.PeakC = reading.TempC
+			}
+			continue
+		}
+
+		if current != nil {
+			current.End = reading.Timestamp
+			current.DurationMin = times[i].Sub(currentStart).Minutes()
+			excursions = append(excursions, *current)
+			current = nil
+		}
+	}
+
+	// Excursão que não retornou para a faixa: sem leitura posterior, a
+	// carga é considerada fora da faixa até a entrega
+	if current != nil {
+		current.End = end.Format(time.RFC3339)
+		current.DurationMin = end.Sub(currentStart).Minutes()
+		excursions = append(excursions, *current)
+	}
+
+	return excursions
+}
+
+// Função que retorna o quanto uma temperatura está fora da faixa (0 se dentro)
+func distanceFromRange(tempC float64, minC float64, maxC float64) float64 {
+	if tempC < minC {
+		return minC - tempC
+	}
+	if tempC > maxC {
+		return tempC - maxC
+	}
+	return 0
+}
+
+/*
+	Função responsável por registrar o transporte de um lote com as leituras
+	do registrador de temperatura. A faixa permitida vem do lote de reagente
+	registrado pelo fornecedor; as excursões, o status da cadeia de frio e o
+	tempo de transporte são calculados no ledger. Séries grandes são guardadas
+	apenas como hash SHA-256 junto com o resumo calculado
+	Exige o papel "supplier" e que o cliente seja da organização que
+	registrou o lote de reagente. Leituras fora do intervalo entre
+	dispatched_at e delivered_at são rejeitadas
+*/
+func (r *RegistryContract) RegisterShipment(ctx contractapi.TransactionContextInterface, shipmentJSON string) error {
+	// Garante que o cliente é um fornecedor
+	if err := requireRole(ctx, supplierRole); err != nil {
+		return err
+	}
+
+	mspID, err := callerMSP(ctx)
+	if err != nil {
+		return err
+	}
+
+	var shipment Shipment
+	if err := json.Unmarshal([]byte(shipmentJSON), &shipment); err != nil {
+		return fmt.Errorf("erro ao decodificar JSON: %v", err)
+	}
+
+	if shipment.ShipmentID == "" || shipment.ReagentLot == "" || shipment.CassetteLot == "" {
+		return fmt.Errorf("shipment_id, reagent_lot e cassette_lot são obrigatórios")
+	}
+	if len(shipment.Readings) == 0 {
+		return fmt.Errorf("readings não pode ser vazio")
+	}
+
+	key, err := shipmentKey(ctx, shipment.ShipmentID)
+	if err != nil {
+		return err
+	}
+
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("transporte %s ja existe", shipment.ShipmentID)
+	}
+
+	// A faixa de temperatura permitida é a do lote de reagente transportado
+	lot, err := getReagentLot(ctx, shipment.ReagentLot)
+	if err != nil {
+		return err
+	}
+	shipment.AllowedMinTempC = lot.StorageMinTempC
+	shipment.AllowedMaxTempC = lot.StorageMaxTempC
+
+	// Somente a organização que registrou o lote pode registrar seu transporte
+	if lot.SupplierMSP != mspID {
+		return fmt.Errorf("lote %s pertence a %s", lot.LotID, lot.SupplierMSP)
+	}
+
+	dispatched, err := parseDate(shipment.DispatchedAt)
+	if err != nil {
+		return fmt.Errorf("dispatched_at: %v", err)
+	}
+	delivered, err := parseDate(shipment.DeliveredAt)
+	if err != nil {
+		return fmt.Errorf("delivered_at: %v", err)
+	}
+	if delivered.Before(dispatched) {
+		return fmt.Errorf("delivered_at deve ser posterior a dispatched_at")
+	}
+
+	// Ordena as leituras por timestamp antes de calcular as excursões
+	times := make([]time.Time, len(shipment.Readings))
+	for i, reading := range shipment.Readings {
+		t, err := time.Parse(time.RFC3339, reading.Timestamp)
+		if err != nil {
+			return fmt.Errorf("leitura %d com timestamp invalido: %v", i, err)
+		}
+		if t.Before(dispatched) || t.After(delivered) {
+			return fmt.Errorf("leitura %d (%s) fora do intervalo do transporte", i, reading.Timestamp)
+		}
+		times[i] = t
+	}
+	sort.Sort(readingsByTime{shipment.Readings, times})
+
+	shipment.ObservedMinTempC = shipment.Readings[0].TempC
+	shipment.ObservedMaxTempC = shipment.Readings[0].TempC
+	for _, reading := range shipment.Readings {
+		if reading.TempC < shipment.ObservedMinTempC {
+			shipment.ObservedMinTempC = reading.TempC
+		}
+		if reading.TempC > shipment.ObservedMaxTempC {
+			shipment.ObservedMaxTempC = reading.TempC
+		}
+	}
+
+	shipment.Excursions = computeExcursions(shipment.Readings, times, shipment.AllowedMinTempC, shipment.AllowedMaxTempC, delivered)
+	shipment.ExcursionTotalMin = 0
+	for _, excursion := range shipment.Excursions {
+		shipment.ExcursionTotalMin += excursion.DurationMin
+	}
+
+	// Sem tolerância configurada, qualquer excursão quebra a cadeia de frio
+	shipment.CadeiaFrioStatus = len(shipment.Excursions) == 0 ||
+		(shipment.MaxExcursionMin > 0 && shipment.ExcursionTotalMin <= shipment.MaxExcursionMin)
+	shipment.TempoTransporteHoras = delivered.Sub(dispatched).Hours()
+
+	// Calcula o hash da série ordenada; séries grandes não ficam no estado
+	readingsBytes, err := json.Marshal(shipment.Readings)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(readingsBytes)
+	shipment.ReadingsHash = hex.EncodeToString(sum[:])
+	shipment.ReadingsCount = len(shipment.Readings)
+	if shipment.ReadingsCount > maxInlineReadings {
+		shipment.Readings = nil
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	shipment.Version = 0
+	shipment.RegisteredBy = mspID
+	shipment.CreatedAt = now.Format(time.RFC3339)
+	shipment.LastUpdatedAt = shipment.CreatedAt
+
+	bytes, err := json.Marshal(shipment)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, bytes)
+}
+
+// Função que carrega um transporte do ledger pelo seu ID
+func getShipment(ctx contractapi.TransactionContextInterface, shipmentID string) (*Shipment, error) {
+	if shipmentID == "" {
+		return nil, fmt.Errorf("shipmentID não pode ser vazio")
+	}
+
+	key, err := shipmentKey(ctx, shipmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao acessar o ledger: %v", err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("transporte %s não encontrado", shipmentID)
+	}
+
+	var shipment Shipment
+	if err := json.Unmarshal(data, &shipment); err != nil {
+		return nil, fmt.Errorf("erro ao deserializar transporte: %v", err)
+	}
+
+	return &shipment, nil
+}
+
+// Função que consulta um transporte pelo seu ID
+func (r *RegistryContract) GetShipment(ctx contractapi.TransactionContextInterface, shipmentID string) (*Shipment, error) {
+	return getShipment(ctx, shipmentID)
+}
+
+/*
+	Função que deriva os campos de cadeia de frio do teste a partir do
+	transporte vinculado, descartando os valores informados pelo operador.
+	O transporte precisa ser do mesmo lote de reagente e de cassete do teste
+*/
+func applyShipment(ctx contractapi.TransactionContextInterface, record *TestRecord) error {
+	if record.ShipmentID == "" {
+		return fmt.Errorf("shipment_id é obrigatório")
+	}
+
+	shipment, err := getShipment(ctx, record.ShipmentID)
+	if err != nil {
+		return err
+	}
+
+	if shipment.ReagentLot != record.ReagentLot {
+		return fmt.Errorf("transporte %s não corresponde ao lote de reagente %s", shipment.ShipmentID, record.ReagentLot)
+	}
+	if shipment.CassetteLot != record.CassetteLot {
+		return fmt.Errorf("transporte %s não corresponde ao lote de cassete %s", shipment.ShipmentID, record.CassetteLot)
+	}
+
+	record.CadeiaFrioStatus = shipment.CadeiaFrioStatus
+	record.TempoTransporteHoras = shipment.TempoTransporteHoras
+	record.CondicaoTransporte = shipment.CondicaoTransporte
+
+	return nil
+}
+
+// Ordenação das leituras mantendo os tempos já interpretados alinhados
+type readingsByTime struct {
+	readings []TempReading
+	times    []time.Time
+}
+
+func (r readingsByTime) Len() int           { return len(r.readings) }
+func (r readingsByTime) Less(i, j int) bool { return r.times[i].Before(r.times[j]) }
+func (r readingsByTime) Swap(i, j int) {
+	r.readings[i], r.readings[j] = r.readings[j], r.readings[i]
+	r.times[i], r.times[j] = r.times[j], r.times[i]
+}
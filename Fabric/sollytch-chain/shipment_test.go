@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+func parseReadings(t *testing.T, readings []TempReading) []time.Time {
+	times := make([]time.Time, len(readings))
+	for i, reading := range readings {
+		parsed, err := time.Parse(time.RFC3339, reading.Timestamp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		times[i] = parsed
+	}
+	return times
+}
+
+func TestComputeExcursions(t *testing.T) {
+	readings := []TempReading{
+		{Timestamp: "2025-07-15T10:00:00Z", TempC: 4},
+		{Timestamp: "2025-07-15T10:05:00Z", TempC: 9.5},
+		{Timestamp: "2025-07-15T10:10:00Z", TempC: 11},
+		{Timestamp: "2025-07-15T10:20:00Z", TempC: 6},
+		{Timestamp: "2025-07-15T10:30:00Z", TempC: 1},
+	}
+
+	delivered := time.Date(2025, 7, 15, 10, 45, 0, 0, time.UTC)
+	excursions := computeExcursions(readings, parseReadings(t, readings), 2, 8, delivered)
+
+	if len(excursions) != 2 {
+		t.Fatalf("expected 2 excursions, got %d", len(excursions))
+	}
+
+	first := excursions[0]
+	if first.Start != "2025-07-15T10:05:00Z" || first.End != "2025-07-15T10:20:00Z" {
+		t.Errorf("unexpected first excursion bounds: %+v", first)
+	}
+	if first.DurationMin != 15 || first.PeakC != 11 {
+		t.Errorf("unexpected first excursion summary: %+v", first)
+	}
+
+	// The last excursion never returns to range and lasts until delivery
+	last := excursions[1]
+	if last.Start != "2025-07-15T10:30:00Z" || last.End != "2025-07-15T10:45:00Z" {
+		t.Errorf("unexpected trailing excursion bounds: %+v", last)
+	}
+	if last.DurationMin != 15 || last.PeakC != 1 {
+		t.Errorf("unexpected trailing excursion summary: %+v", last)
+	}
+}
+
+func TestComputeExcursionsWithinRange(t *testing.T) {
+	readings := []TempReading{
+		{Timestamp: "2025-07-15T10:00:00Z", TempC: 3},
+		{Timestamp: "2025-07-15T11:00:00Z", TempC: 7.9},
+	}
+
+	delivered := time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC)
+	excursions := computeExcursions(readings, parseReadings(t, readings), 2, 8, delivered)
+	if len(excursions) != 0 {
+		t.Fatalf("expected no excursions, got %+v", excursions)
+	}
+}
+
+func TestRegisterShipment(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-chain", nil)
+	registry := new(RegistryContract)
+
+	supplier := newTestContext(stub, newIdentity("Org1MSP", "supplier", map[string]string{roleAttribute: supplierRole}))
+	mustTx(t, stub, func() error {
+		return registry.RegisterReagentLot(supplier, `{"lot_id":"R-001","manufacturer":"Sollytch",`+
+			`"manufacture_date":"2025-01-01","expiry_date":"2026-01-01",`+
+			`"storage_min_temp_C":2,"storage_max_temp_C":8}`)
+	})
+
+	shipment := Shipment{
+		ShipmentID:   "SHP-001",
+		ReagentLot:   "R-001",
+		CassetteLot:  "C22009",
+		DispatchedAt: "2025-07-15T08:00:00Z",
+		DeliveredAt:  "2025-07-15T12:00:00Z",
+		Readings: []TempReading{
+			{Timestamp: "2025-07-15T08:00:00Z", TempC: 4},
+			{Timestamp: "2025-07-15T11:00:00Z", TempC: 10},
+		},
+	}
+	marshal := func(s Shipment) string {
+		bytes, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(bytes)
+	}
+
+	// Clients without the supplier role, or from another organisation, are rejected
+	carrier := newTestContext(stub, newIdentity("Org1MSP", "carrier", nil))
+	if err := inTx(t, stub, func() error { return registry.RegisterShipment(carrier, marshal(shipment)) }); err == nil {
+		t.Error("expected shipment without supplier role to be rejected")
+	}
+	otherOrg := newTestContext(stub, newIdentity("Org2MSP", "supplier", map[string]string{roleAttribute: supplierRole}))
+	if err := inTx(t, stub, func() error { return registry.RegisterShipment(otherOrg, marshal(shipment)) }); err == nil {
+		t.Error("expected shipment of another organisation's lot to be rejected")
+	}
+
+	// The cassette lot is required to link tests to the shipment
+	noCassette := shipment
+	noCassette.CassetteLot = ""
+	if err := inTx(t, stub, func() error { return registry.RegisterShipment(supplier, marshal(noCassette)) }); err == nil {
+		t.Error("expected shipment without cassette_lot to be rejected")
+	}
+
+	// Readings after delivery are rejected
+	late := shipment
+	late.Readings = append([]TempReading{}, shipment.Readings...)
+	late.Readings = append(late.Readings, TempReading{Timestamp: "2025-07-15T13:00:00Z", TempC: 4})
+	if err := inTx(t, stub, func() error { return registry.RegisterShipment(supplier, marshal(late)) }); err == nil {
+		t.Error("expected reading after delivered_at to be rejected")
+	}
+
+	mustTx(t, stub, func() error { return registry.RegisterShipment(supplier, marshal(shipment)) })
+
+	var stored Shipment
+	key, err := stub.CreateCompositeKey("transporte", []string{"SHP-001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(stub.State[key], &stored); err != nil {
+		t.Fatal(err)
+	}
+
+	// The trailing excursion counts until delivery
+	if stored.ExcursionTotalMin != 60 || stored.CadeiaFrioStatus {
+		t.Errorf("expected 60 minutes of excursion and a broken cold chain, got %+v", stored)
+	}
+	if stored.RegisteredBy != "Org1MSP" {
+		t.Errorf("expected shipment registered by Org1MSP, got %s", stored.RegisteredBy)
+	}
+
+	// Tests only take the cold chain from a shipment of the same reagent and cassette lots
+	ctx := newTestContext(stub, newIdentity("Org1MSP", "operator", nil))
+	tests := []struct {
+		name   string
+		record TestRecord
+		ok     bool
+	}{
+		{"matching lots", TestRecord{ShipmentID: "SHP-001", ReagentLot: "R-001", CassetteLot: "C22009"}, true},
+		{"other reagent lot", TestRecord{ShipmentID: "SHP-001", ReagentLot: "R-002", CassetteLot: "C22009"}, false},
+		{"other cassette lot", TestRecord{ShipmentID: "SHP-001", ReagentLot: "R-001", CassetteLot: "C22010"}, false},
+		{"unknown shipment", TestRecord{ShipmentID: "SHP-002", ReagentLot: "R-001", CassetteLot: "C22009"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			record := test.record
+			err := applyShipment(ctx, &record)
+			if (err == nil) != test.ok {
+				t.Fatalf("expected ok=%v, got %v", test.ok, err)
+			}
+			if test.ok && (record.CadeiaFrioStatus || record.TempoTransporteHoras != 4) {
+				t.Errorf("expected cold chain fields from the shipment, got %+v", record)
+			}
+		})
+	}
+}
+
+func TestStoreTestShipment(t *testing.T) {
+	f := newStoreTestFixture(t)
+
+	// Cold chain fields come from SHP-001, not from the client
+	record := f.mustStore(t, "TEST-00001", storeTestJSON(t, "TEST-00001", map[string]interface{}{
+		"cadeia_frio_status":     false,
+		"tempo_transporte_horas": 99,
+		"condicao_transporte":    "ambiente",
+	}))
+	if !record.CadeiaFrioStatus || record.TempoTransporteHoras != 6 || record.CondicaoTransporte != "protegido" {
+		t.Errorf("expected cold chain from the shipment, got %v %v %s", record.CadeiaFrioStatus, record.TempoTransporteHoras, record.CondicaoTransporte)
+	}
+
+	f.assertRejected(t, "TEST-00002", storeTestJSON(t, "TEST-00002", map[string]interface{}{"shipment_id": "SHP-999"}), "transporte SHP-999 não encontrado")
+	f.assertRejected(t, "TEST-00003", storeTestJSON(t, "TEST-00003", map[string]interface{}{"shipment_id": nil}), "shipment_id é obrigatório")
+
+	// A calibration of the other cassette lot exists, so only the shipment can reject it
+	supplier := newTestContext(f.stub, newIdentity("Org1MSP", "supplier", map[string]string{roleAttribute: supplierRole}))
+	mustTx(t, f.stub, func() error {
+		return new(RegistryContract).RegisterCalibration(supplier, fmt.Sprintf(`{"calibration_id":"CAL1051","kit_lot":"C22010",`+
+			`"valid_from":%q,"valid_until":%q,"model_type":"linear","coefficients":[1,1,0],`+
+			`"distance_min_mm":0,"distance_max_mm":40,"time_min_s":0,"time_max_s":900}`,
+			f.now.Add(-time.Hour).Format(time.RFC3339), f.now.Add(time.Hour).Format(time.RFC3339)))
+	})
+	f.assertRejected(t, "TEST-00004", storeTestJSON(t, "TEST-00004", map[string]interface{}{
+		"cassette_lot":       "C22010",
+		"kit_calibration_id": "CAL1051",
+	}), "lote de cassete C22010")
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Tipos de assinante aceitos para o payload de um teste
+const (
+	signerOperator = "operador"
+	signerDevice   = "dispositivo"
+)
+
+/*
+	Função que gera a forma canônica do JSON de um teste, conforme o JSON
+	Canonicalization Scheme (RFC 8785): chaves ordenadas por unidades
+	UTF-16, sem espaços, strings com o escape mínimo e números serializados
+	como double no formato do ECMAScript (12.0 vira 12, 1e2 vira 100).
+	É sobre esses bytes que a assinatura destacada é feita; o cliente
+	(testSignature.js) gera a mesma forma com JSON.stringify
+*/
+func canonicalJSON(jsonStr string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(jsonStr)))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar JSON: %v", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("erro ao decodificar JSON: conteúdo após o valor")
+	}
+
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Função que escreve um valor decodificado na forma canônica do RFC 8785
+func writeCanonical(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		number, err := canonicalNumber(v)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(number)
+	case string:
+		writeCanonicalString(buf, v)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return lessUTF16(keys[i], keys[j]) })
+
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, key)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("tipo JSON inesperado: %T", value)
+	}
+	return nil
+}
+
+/*
+	Função que serializa um número como o Number.prototype.toString do
+	ECMAScript: o menor decimal que identifica o double, em notação
+	exponencial apenas abaixo de 1e-6 ou a partir de 1e21
+*/
+func canonicalNumber(number json.Number) (string, error) {
+	f, err := strconv.ParseFloat(string(number), 64)
+	if err != nil || math.IsInf(f, 0) {
+		return "", fmt.Errorf("número %s fora do intervalo de um double", number)
+	}
+	if f == 0 {
+		return "0", nil
+	}
+
+	abs := math.Abs(f)
+	if abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+
+	// O Go escreve o expoente com ao menos dois dígitos (1e-07); o
+	// ECMAScript não completa com zero (1e-7)
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	if n := len(s); s[n-4] == 'e' && s[n-2] == '0' {
+		s = s[:n-2] + s[n-1:]
+	}
+	return s, nil
+}
+
+/*
+	Função que escreve uma string com o escape do JSON.stringify: apenas
+	aspas, barra invertida e caracteres de controle, com \b, \t, \n, \f e \r
+	na forma curta e os demais como \u00xx em minúsculas
+*/
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\r':
+			buf.WriteString(`\r`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// Função que compara duas chaves pelas unidades UTF-16, a ordem do Array.sort do ECMAScript
+func lessUTF16(a string, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+/*
+	Função que verifica uma assinatura destacada (base64) sobre o payload
+	ECDSA usa SHA-256 com assinatura em ASN.1 DER; Ed25519 assina o
+	payload diretamente
+*/
+func verifySignature(publicKey interface{}, payload []byte, signatureB64 string) error {
+	signature, err := base64.StdEncoding.DecodeString(signatureB64)
+	if err != nil {
+		return fmt.Errorf("assinatura não está em base64: %v", err)
+	}
+
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(payload)
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return fmt.Errorf("assinatura ECDSA invalida")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, payload, signature) {
+			return fmt.Errorf("assinatura Ed25519 invalida")
+		}
+	default:
+		return fmt.Errorf("tipo de chave pública não suportado: %T", publicKey)
+	}
+
+	return nil
+}
+
+/*
+	Função que verifica a assinatura do payload de um teste
+	O keyID indica quem assinou: o operator_did do teste (chave registrada
+	para o operador) ou o device_id do teste (chave registrada para o leitor).
+	O test_id precisa estar no payload assinado e ser o testID da transação,
+	para que um payload assinado não seja regravado com outro ID.
+	A assinatura e a chave usada ficam gravadas no registro do teste
+*/
+func verifyTestSignature(ctx contractapi.TransactionContextInterface, testID string, record *TestRecord, jsonStr string, signatureB64 string, keyID string) error {
+	if signatureB64 == "" || keyID == "" {
+		return fmt.Errorf("signature e keyID são obrigatórios")
+	}
+
+	if record.TestID == "" {
+		return fmt.Errorf("test_id é obrigatório no JSON assinado")
+	}
+	if record.TestID != testID {
+		return fmt.Errorf("test_id do JSON (%s) difere de %s", record.TestID, testID)
+	}
+
+	var publicKeyPEM string
+	var signerType string
+
+	switch keyID {
+	case record.OperatorDID:
+		operator, err := getOperatorByDID(ctx, keyID)
+		if err != nil {
+			return err
+		}
+		if record.OperatorID != "" && record.OperatorID != operator.OperatorID {
+			return fmt.Errorf("operator_id %s não corresponde ao DID %s", record.OperatorID, keyID)
+		}
+		publicKeyPEM = operator.PublicKey
+		signerType = signerOperator
+	case record.DeviceID:
+		device, err := getDevice(ctx, keyID)
+		if err != nil {
+			return err
+		}
+		publicKeyPEM = device.PublicKey
+		signerType = signerDevice
+	default:
+		return fmt.Errorf("keyID %s não corresponde ao operator_did nem ao device_id do teste", keyID)
+	}
+
+	publicKey, err := parsePublicKeyPEM(publicKeyPEM)
+	if err != nil {
+		return err
+	}
+
+	payload, err := canonicalJSON(jsonStr)
+	if err != nil {
+		return err
+	}
+
+	if err := verifySignature(publicKey, payload, signatureB64); err != nil {
+		return err
+	}
+
+	record.Signature = signatureB64
+	record.SignatureKeyID = keyID
+	record.SignerType = signerType
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+const signedTestJSON = `{ "test_id": "TEST-00999", "lat": -22.87496, "operator_did": "did:bio:OP04" }`
+
+func TestCanonicalJSON(t *testing.T) {
+	canonical, err := canonicalJSON(signedTestJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"lat":-22.87496,"operator_did":"did:bio:OP04","test_id":"TEST-00999"}`
+	if string(canonical) != expected {
+		t.Errorf("expected %s, got %s", expected, canonical)
+	}
+}
+
+// The same vectors are checked by client/resources/testSignature.test.js
+func TestCanonicalJSONVectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/canonical_json.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vectors []struct {
+		Name      string `json:"name"`
+		Input     string `json:"input"`
+		Canonical string `json:"canonical"`
+	}
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, vector := range vectors {
+		t.Run(vector.Name, func(t *testing.T) {
+			canonical, err := canonicalJSON(vector.Input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(canonical) != vector.Canonical {
+				t.Errorf("expected %s, got %s", vector.Canonical, canonical)
+			}
+		})
+	}
+}
+
+func TestCanonicalJSONRejectsInvalidInput(t *testing.T) {
+	for _, input := range []string{`{"a":1e400}`, `{"a":1} {"b":2}`, `{"a":`} {
+		if _, err := canonicalJSON(input); err == nil {
+			t.Errorf("expected %s to be rejected", input)
+		}
+	}
+}
+
+func TestVerifySignatureECDSA(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := canonicalJSON(signedTestJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signatureB64 := base64.StdEncoding.EncodeToString(signature)
+
+	if err := verifySignature(&key.PublicKey, payload, signatureB64); err != nil {
+		t.Errorf("expected valid signature: %v", err)
+	}
+
+	if err := verifySignature(&key.PublicKey, append(payload, ' '), signatureB64); err == nil {
+		t.Error("expected tampered payload to be rejected")
+	}
+}
+
+func TestVerifySignatureEd25519(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := canonicalJSON(signedTestJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signatureB64 := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, payload))
+
+	if err := verifySignature(publicKey, payload, signatureB64); err != nil {
+		t.Errorf("expected valid signature: %v", err)
+	}
+
+	otherKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifySignature(otherKey, payload, signatureB64); err == nil {
+		t.Error("expected signature from another key to be rejected")
+	}
+}
+
+// Assina a forma canônica do JSON como o cliente faz antes do StoreTest
+func signTestJSON(t *testing.T, key *ecdsa.PrivateKey, jsonStr string) string {
+	t.Helper()
+	payload, err := canonicalJSON(jsonStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+func TestVerifyTestSignature(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-chain", nil)
+	registry := new(RegistryContract)
+	admin := newTestContext(stub, newIdentity("Org1MSP", "admin", map[string]string{roleAttribute: adminRole}))
+
+	operatorKey, operatorPEM := newSigningKey(t)
+	deviceKey, devicePEM := newSigningKey(t)
+
+	operatorJSON, err := json.Marshal(Operator{OperatorID: "OP04", OperatorDID: "did:bio:OP04", PublicKey: operatorPEM})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustTx(t, stub, func() error { return registry.RegisterOperator(admin, string(operatorJSON)) })
+	mustTx(t, stub, func() error { return registry.RegisterDevice(admin, deviceJSON(t, "DEV-001", devicePEM)) })
+
+	const testJSON = `{"test_id":"TEST-00999","operator_id":"OP04","operator_did":"did:bio:OP04","device_id":"DEV-001"}`
+	decode := func(jsonStr string) *TestRecord {
+		var record TestRecord
+		if err := json.Unmarshal([]byte(jsonStr), &record); err != nil {
+			t.Fatal(err)
+		}
+		return &record
+	}
+
+	// The operator key is found through the DID index created by RegisterOperator
+	record := decode(testJSON)
+	if err := verifyTestSignature(admin, "TEST-00999", record, testJSON, signTestJSON(t, operatorKey, testJSON), "did:bio:OP04"); err != nil {
+		t.Fatalf("expected operator signature to be accepted: %v", err)
+	}
+	if record.SignerType != signerOperator || record.SignatureKeyID != "did:bio:OP04" {
+		t.Errorf("unexpected signer recorded: %s %s", record.SignerType, record.SignatureKeyID)
+	}
+
+	record = decode(testJSON)
+	if err := verifyTestSignature(admin, "TEST-00999", record, testJSON, signTestJSON(t, deviceKey, testJSON), "DEV-001"); err != nil {
+		t.Fatalf("expected device signature to be accepted: %v", err)
+	}
+	if record.SignerType != signerDevice {
+		t.Errorf("expected device signer, got %s", record.SignerType)
+	}
+
+	// A valid signature cannot be replayed under another test ID
+	if err := verifyTestSignature(admin, "TEST-01000", decode(testJSON), testJSON, signTestJSON(t, operatorKey, testJSON), "did:bio:OP04"); err == nil {
+		t.Error("expected signed payload to be rejected for a different testID")
+	}
+
+	// Payloads without test_id are rejected even when correctly signed
+	const withoutID = `{"operator_id":"OP04","operator_did":"did:bio:OP04","device_id":"DEV-001"}`
+	if err := verifyTestSignature(admin, "TEST-00999", decode(withoutID), withoutID, signTestJSON(t, operatorKey, withoutID), "did:bio:OP04"); err == nil {
+		t.Error("expected payload without test_id to be rejected")
+	}
+
+	// Signature by the wrong key, or under an unrelated key ID
+	if err := verifyTestSignature(admin, "TEST-00999", decode(testJSON), testJSON, signTestJSON(t, deviceKey, testJSON), "did:bio:OP04"); err == nil {
+		t.Error("expected signature by another key to be rejected")
+	}
+	if err := verifyTestSignature(admin, "TEST-00999", decode(testJSON), testJSON, signTestJSON(t, operatorKey, testJSON), "did:bio:OUTRO"); err == nil {
+		t.Error("expected unknown key ID to be rejected")
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Situação de cada imagem gravada
+const (
+	imageStored  = "armazenada"
+	imageUpdated = "atualizada"
+	imageReused  = "reuso"
+	// Item de um lote que não passou nas validações e não foi gravado
+	imageRejected = "rejeitada"
+)
+
+// Nome do evento com os reusos detectados em um envio em lote
+const imageReuseBatchEvent = "ImageReuseBatch"
+
+// Limite de imagens por transação de envio em lote
+const maxBatchImages = 100
+
+// Item de um envio em lote de imagens
+type ImageBatchEntry struct {
+	IDKit    string          `json:"idKit"`
+	HashData string          `json:"hashData"`
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// Resultado da gravação de uma imagem; Error traz o motivo de um item rejeitado
+type ImageStoreResult struct {
+	IDKit    string `json:"idKit"`
+	HashData string `json:"hashData"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+/*
+	Erro de validação de uma imagem, detectado antes de qualquer escrita no
+	ledger. Em um lote, o item é apenas rejeitado; demais erros (de leitura
+	ou escrita no ledger) abortam a transação inteira
+*/
+type imageRejectedError struct {
+	err error
+}
+
+func (e *imageRejectedError) Error() string { return e.err.Error() }
+
+func (e *imageRejectedError) Unwrap() error { return e.err }
+
+// Função que marca um erro como rejeição da imagem
+func rejectImage(format string, args ...interface{}) error {
+	return &imageRejectedError{err: fmt.Errorf(format, args...)}
+}
+
+/*
+	Função que registra várias imagens (por exemplo, os quadros capturados
+	de um cassete) em uma única transação. Recebe um array JSON de
+	{idKit, hashData, metadata} e aplica a cada item as mesmas validações e
+	regras de StoreImage. Itens que não passam nas validações (metadados,
+	posse do kit, imagem inativa, hash repetido no lote) são rejeitados sem
+	impedir a gravação dos demais; o resultado de cada item traz a situação
+	e, se rejeitado, o erro. Falhas de acesso ao ledger abortam o lote inteiro
+*/
+func (c *SmartContract) StoreImages(ctx contractapi.TransactionContextInterface, entriesJSON string) ([]*ImageStoreResult, error) {
+	var entries []ImageBatchEntry
+	if err := json.Unmarshal([]byte(entriesJSON), &entries); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar lote de imagens: %v", err)
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("lote de imagens vazio")
+	}
+	if len(entries) > maxBatchImages {
+		return nil, fmt.Errorf("lote possui %d imagens, limite de %d", len(entries), maxBatchImages)
+	}
+
+	results := []*ImageStoreResult{}
+	reuses := []*imageReuseEventPayload{}
+	seen := map[string]int{}
+
+	for i, entry := range entries {
+		// Leituras no Fabric não enxergam escritas da própria transação, então
+		// o mesmo hash duas vezes no lote produziria registros inconsistentes
+		if previous, ok := seen[entry.HashData]; ok {
+			results = append(results, rejectedResult(entry, fmt.Errorf("hashData repetido no item %d", previous)))
+			continue
+		}
+		seen[entry.HashData] = i
+
+		result, reuse, err := c.storeImage(ctx, entry.IDKit, entry.HashData, string(entry.Metadata))
+		var rejected *imageRejectedError
+		if errors.As(err, &rejected) {
+			results = append(results, rejectedResult(entry, err))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("item %d (%s): %v", i, entry.HashData, err)
+		}
+
+		results = append(results, result)
+		if reuse != nil {
+			reuses = append(reuses, reuse)
+		}
+	}
+
+	// Sinaliza todos os reusos do lote em um único evento
+	if len(reuses) > 0 {
+		payload, err := json.Marshal(reuses)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := ctx.GetStub().SetEvent(imageReuseBatchEvent, payload); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// Função que monta o resultado de um item rejeitado do lote
+func rejectedResult(entry ImageBatchEntry, err error) *ImageStoreResult {
+	return &ImageStoreResult{
+		IDKit:    entry.IDKit,
+		HashData: entry.HashData,
+		Status:   imageRejected,
+		Error:    err.Error(),
+	}
+}
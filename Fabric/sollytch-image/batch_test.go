@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+// Monta o JSON de um lote de imagens do kit, uma por hash
+func batchJSON(t *testing.T, idKit string, testID string, hashes ...string) string {
+	t.Helper()
+	entries := []ImageBatchEntry{}
+	for _, hash := range hashes {
+		entries = append(entries, ImageBatchEntry{
+			IDKit:    idKit,
+			HashData: hash,
+			Metadata: json.RawMessage(captureMetadata(t, testID)),
+		})
+	}
+	bytes, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bytes)
+}
+
+func TestStoreImages(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-image", nil)
+	org1 := newTestContext(stub, newIdentity("Org1MSP", "leitor", nil))
+	org2 := newTestContext(stub, newIdentity("Org2MSP", "leitor", nil))
+	contract := new(SmartContract)
+	first, second := imageHash("quadro 1"), imageHash("quadro 2")
+
+	registerKits(t, stub, org1, "KIT-A", "KIT-C")
+	registerKits(t, stub, org2, "KIT-B")
+
+	var results []*ImageStoreResult
+	mustTx(t, stub, func() error {
+		var err error
+		results, err = contract.StoreImages(org1, batchJSON(t, "KIT-A", "TEST-00001", first, second))
+		return err
+	})
+	if len(results) != 2 || results[0].Status != imageStored || results[1].Status != imageStored {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	storedImage(t, stub, first)
+	storedImage(t, stub, second)
+
+	// Reusos do lote saem juntos em um único evento
+	mustTx(t, stub, func() error {
+		var err error
+		results, err = contract.StoreImages(org2, batchJSON(t, "KIT-B", "TEST-00002", first, second))
+		return err
+	})
+	if results[0].Status != imageReused || results[1].Status != imageReused {
+		t.Errorf("expected both images to be reused, got %+v", results)
+	}
+	event := <-stub.ChaincodeEventsChannel
+	var reuses []imageReuseEventPayload
+	if err := json.Unmarshal(event.Payload, &reuses); err != nil {
+		t.Fatal(err)
+	}
+	if event.EventName != imageReuseBatchEvent || len(reuses) != 2 {
+		t.Errorf("unexpected batch event %s: %s", event.EventName, event.Payload)
+	}
+
+	tests := []struct {
+		name    string
+		entries string
+		err     string
+	}{
+		{"invalid json", "[", "decodificar"},
+		{"empty batch", "[]", "vazio"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := inTx(t, stub, func() error {
+				_, err := contract.StoreImages(org1, test.entries)
+				return err
+			})
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+
+	// Itens inválidos são rejeitados sem impedir a gravação dos demais
+	valid, repeated, invalid, foreign := imageHash("x"), imageHash("x"), imageHash("y"), imageHash("z")
+	var entries []ImageBatchEntry
+	for _, batch := range []string{
+		batchJSON(t, "KIT-C", "TEST-00003", valid, repeated),
+		strings.Replace(batchJSON(t, "KIT-C", "TEST-00003", invalid), `"image/jpeg"`, `"image/gif"`, 1),
+		batchJSON(t, "KIT-B", "TEST-00003", foreign),
+	} {
+		var items []ImageBatchEntry
+		if err := json.Unmarshal([]byte(batch), &items); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, items...)
+	}
+	mixed, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mustTx(t, stub, func() error {
+		var err error
+		results, err = contract.StoreImages(org1, string(mixed))
+		return err
+	})
+	expected := []struct {
+		status string
+		err    string
+	}{
+		{imageStored, ""},
+		{imageRejected, "repetido no item 0"},
+		{imageRejected, "mimeType"},
+		{imageRejected, "403"},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %+v", len(expected), results)
+	}
+	for i, want := range expected {
+		if results[i].Status != want.status || !strings.Contains(results[i].Error, want.err) {
+			t.Errorf("item %d: expected %s with error %q, got %+v", i, want.status, want.err, results[i])
+		}
+	}
+	storedImage(t, stub, valid)
+	for _, hash := range []string{invalid, foreign} {
+		if _, ok := stub.State[hash]; ok {
+			t.Errorf("rejected image %s was stored", hash)
+		}
+	}
+}
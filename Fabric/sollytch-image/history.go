@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Versão de uma imagem no histórico do ledger
+type ImageHistoryEntry struct {
+	TxID        string      `json:"txId"`
+	Timestamp   string      `json:"timestamp"`
+	IsDelete    bool        `json:"isDelete"`
+	SubmittedBy string      `json:"submittedBy"`
+	Image       *ImageAsset `json:"image,omitempty"`
+}
+
+// Imagem vinculada a um teste e o kit que a enviou para ele
+type TestImage struct {
+	IDKit  string      `json:"idKit"`
+	Reused bool        `json:"reused"`
+	Image  *ImageAsset `json:"image"`
+}
+
+// Função que monta a chave do índice de imagens por teste
+func testImageIndexKey(ctx contractapi.TransactionContextInterface, testID string, hashData string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(
+		"teste~hashImagem",
+		[]string{testID, hashData},
+	)
+}
+
+/*
+	Função que indexa a imagem pelo teste informado nos metadados
+	O valor do índice guarda o kit que enviou a imagem para o teste, para
+	distinguir o teste original dos que reivindicaram a imagem em um reuso
+*/
+func putTestImageIndex(ctx contractapi.TransactionContextInterface, testID string, hashData string, idKit string) error {
+	indexKey, err := testImageIndexKey(ctx, testID, hashData)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(indexKey, []byte(idKit))
+}
+
+/*
+	Função que retorna todas as imagens vinculadas a um teste, incluindo as
+	reivindicadas pelo teste em um reuso de outro kit. Nesse caso a imagem
+	vem marcada como reuso, com o kit que a reivindicou; o registro em si
+	continua com o kit e o teste originais
+*/
+func (c *SmartContract) GetImagesByTest(ctx contractapi.TransactionContextInterface, testID string) ([]*TestImage, error) {
+	if testID == "" {
+		return nil, fmt.Errorf("testID não pode ser vazio")
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(
+		"teste~hashImagem",
+		[]string{testID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	results := []*TestImage{}
+
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, parts, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		image, err := c.GetImageByID(ctx, parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		// Índices sem o kit (0x00) foram gravados pelo envio original
+		idKit := string(response.Value)
+		if idKit == "" || idKit == "\x00" {
+			idKit = image.IDKit
+		}
+
+		results = append(results, &TestImage{
+			IDKit:  idKit,
+			Reused: idKit != image.IDKit,
+			Image:  image,
+		})
+	}
+
+	return results, nil
+}
+
+/*
+	Função que retorna todas as versões de uma imagem, da mais recente para
+	a mais antiga, com o ID da transação e quem a submeteu
+	Versões gravadas antes do registro de lastUpdatedBy não têm o submissor
+*/
+func (c *SmartContract) GetImageHistory(ctx contractapi.TransactionContextInterface, hashImagem string) ([]*ImageHistoryEntry, error) {
+	if hashImagem == "" {
+		return nil, fmt.Errorf("hashImagem não pode ser vazio")
+	}
+
+	iterator, err := ctx.GetStub().GetHistoryForKey(hashImagem)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar histórico: %v", err)
+	}
+	defer iterator.Close()
+
+	results := []*ImageHistoryEntry{}
+
+	for iterator.HasNext() {
+		modification, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &ImageHistoryEntry{
+			TxID:     modification.TxId,
+			IsDelete: modification.IsDelete,
+		}
+		if modification.Timestamp != nil {
+			entry.Timestamp = time.Unix(
+				modification.Timestamp.Seconds,
+				int64(modification.Timestamp.Nanos),
+			).UTC().Format(time.RFC3339)
+		}
+
+		if !modification.IsDelete {
+			var asset ImageAsset
+			if err := json.Unmarshal(modification.Value, &asset); err != nil {
+				return nil, fmt.Errorf("erro ao deserializar versão %s: %v", modification.TxId, err)
+			}
+			if asset.ReuseClaims == nil {
+				asset.ReuseClaims = []ReuseClaim{}
+			}
+			entry.Image = &asset
+			entry.SubmittedBy = asset.LastUpdatedBy
+		}
+
+		results = append(results, entry)
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("imagem %s não encontrada", hashImagem)
+	}
+
+	return results, nil
+}
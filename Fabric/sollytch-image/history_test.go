@@ -0,0 +1,169 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// Iterador sobre versões já carregadas
+type historyIterator struct {
+	results []*queryresult.KeyModification
+	next    int
+}
+
+func (h *historyIterator) HasNext() bool { return h.next < len(h.results) }
+
+func (h *historyIterator) Next() (*queryresult.KeyModification, error) {
+	result := h.results[h.next]
+	h.next++
+	return result, nil
+}
+
+func (h *historyIterator) Close() error { return nil }
+
+/*
+	MockStub com GetHistoryForKey, que o shimtest não implementa
+	Cada escrita é guardada com o ID e o timestamp da transação e o
+	histórico é devolvido da versão mais recente para a mais antiga
+*/
+type historyStub struct {
+	*shimtest.MockStub
+	history map[string][]*queryresult.KeyModification
+}
+
+func (s *historyStub) record(key string, value []byte, isDelete bool) {
+	if s.history == nil {
+		s.history = map[string][]*queryresult.KeyModification{}
+	}
+	s.history[key] = append([]*queryresult.KeyModification{{
+		TxId:      s.TxID,
+		Value:     value,
+		Timestamp: s.TxTimestamp,
+		IsDelete:  isDelete,
+	}}, s.history[key]...)
+}
+
+func (s *historyStub) PutState(key string, value []byte) error {
+	if err := s.MockStub.PutState(key, value); err != nil {
+		return err
+	}
+	s.record(key, value, false)
+	return nil
+}
+
+func (s *historyStub) DelState(key string) error {
+	if err := s.MockStub.DelState(key); err != nil {
+		return err
+	}
+	s.record(key, nil, true)
+	return nil
+}
+
+func (s *historyStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return &historyIterator{results: s.history[key]}, nil
+}
+
+func TestGetImagesByTest(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-image", nil)
+	org1 := newTestContext(stub, newIdentity("Org1MSP", "leitor", nil))
+	org2 := newTestContext(stub, newIdentity("Org2MSP", "leitor", nil))
+	contract := new(SmartContract)
+	hash := imageHash("cassete")
+	other := imageHash("controle")
+
+	registerKits(t, stub, org1, "KIT-A")
+	registerKits(t, stub, org2, "KIT-B")
+
+	mustTx(t, stub, func() error { return contract.StoreImage(org1, "KIT-A", hash, captureMetadata(t, "TEST-00001")) })
+	mustTx(t, stub, func() error { return contract.StoreImage(org1, "KIT-A", other, captureMetadata(t, "TEST-00001")) })
+	mustTx(t, stub, func() error { return contract.StoreImage(org2, "KIT-B", hash, captureMetadata(t, "TEST-00002")) })
+
+	if _, err := contract.GetImagesByTest(org1, ""); err == nil {
+		t.Error("expected empty testID to be rejected")
+	}
+
+	images, err := contract.GetImagesByTest(org1, "TEST-00001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 2 {
+		t.Fatalf("expected the two images of TEST-00001, got %d", len(images))
+	}
+	for _, image := range images {
+		if image.Reused || image.IDKit != "KIT-A" || image.Image.TestID != "TEST-00001" {
+			t.Errorf("expected original image of KIT-A, got %+v", image)
+		}
+	}
+
+	// O teste que reivindicou a imagem a recebe marcada como reuso
+	images, err = contract.GetImagesByTest(org2, "TEST-00002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 1 || !images[0].Reused || images[0].IDKit != "KIT-B" || images[0].Image.IDKit != "KIT-A" {
+		t.Errorf("expected reuse of KIT-A's image by KIT-B, got %+v", images)
+	}
+
+	images, err = contract.GetImagesByTest(org1, "TEST-09999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 0 {
+		t.Errorf("expected no images for an unknown test, got %d", len(images))
+	}
+}
+
+func TestGetImageHistory(t *testing.T) {
+	stub := &historyStub{MockStub: shimtest.NewMockStub("sollytch-image", nil)}
+	org1 := newTestContext(stub.MockStub, newIdentity("Org1MSP", "leitor", nil))
+	org1.SetStub(stub)
+	org2 := newTestContext(stub.MockStub, newIdentity("Org2MSP", "leitor", nil))
+	org2.SetStub(stub)
+	contract := new(SmartContract)
+	hash := imageHash("cassete")
+
+	registerKits(t, stub.MockStub, org1, "KIT-A")
+	registerKits(t, stub.MockStub, org2, "KIT-B")
+
+	stub.MockTransactionStart("tx-envio")
+	if err := contract.StoreImage(org1, "KIT-A", hash, captureMetadata(t, "TEST-00001")); err != nil {
+		t.Fatal(err)
+	}
+	stub.MockTransactionEnd("tx-envio")
+
+	stub.MockTransactionStart("tx-reuso")
+	if err := contract.StoreImage(org2, "KIT-B", hash, captureMetadata(t, "TEST-00002")); err != nil {
+		t.Fatal(err)
+	}
+	stub.MockTransactionEnd("tx-reuso")
+
+	if _, err := contract.GetImageHistory(org1, ""); err == nil {
+		t.Error("expected empty hash to be rejected")
+	}
+	if _, err := contract.GetImageHistory(org1, imageHash("outra")); err == nil || !strings.Contains(err.Error(), "não encontrada") {
+		t.Errorf("expected unknown image to be rejected, got %v", err)
+	}
+
+	history, err := contract.GetImageHistory(org1, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected two versions, got %d", len(history))
+	}
+
+	latest, first := history[0], history[1]
+	if latest.TxID != "tx-reuso" || latest.SubmittedBy != "Org2MSP:leitor" || latest.Image.Version != 1 || !latest.Image.Reused {
+		t.Errorf("unexpected latest version: %+v", latest)
+	}
+	if first.TxID != "tx-envio" || first.SubmittedBy != "Org1MSP:leitor" || first.Image.Version != 0 || first.Image.Reused {
+		t.Errorf("unexpected first version: %+v", first)
+	}
+	if first.Timestamp == "" || first.Image.ReuseClaims == nil {
+		t.Errorf("expected timestamp and claims to be filled, got %+v", first)
+	}
+}
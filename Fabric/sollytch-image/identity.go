@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Função que retorna o MSP ID da organização do cliente que submeteu a transação
+func callerMSP(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("erro ao obter MSP do cliente: %v", err)
+	}
+
+	return mspID, nil
+}
+
+/*
+	Função que identifica o cliente que submeteu a transação no formato
+	"MSP:ID", onde ID é o identificador único do certificado do cliente
+*/
+func callerID(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := callerMSP(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("erro ao obter identidade do cliente: %v", err)
+	}
+
+	return mspID + ":" + clientID, nil
+}
+
+// Atributo do certificado (Fabric CA) que define o papel do cliente
+const roleAttribute = "sollytch.role"
+
+// Papel que permite enviar imagens para kits de qualquer organização
+const imageUploaderRole = "image_uploader"
+
+// Papel que permite atribuir o dono de kits que já possuem imagens
+const adminRole = "admin"
+
+// Função que indica se o cliente possui o papel informado no atributo "sollytch.role"
+func hasRole(ctx contractapi.TransactionContextInterface, role string) bool {
+	return ctx.GetClientIdentity().AssertAttributeValue(roleAttribute, role) == nil
+}
+
+// Função que monta o erro de acesso negado, no estilo HTTP 403
+func accessDenied(format string, args ...interface{}) error {
+	return fmt.Errorf("403 acesso negado: "+format, args...)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Identidade de cliente usada nos testes no lugar do certificado real
+type fakeIdentity struct {
+	mspID string
+	id    string
+	attrs map[string]string
+}
+
+func (f *fakeIdentity) GetID() (string, error) { return f.id, nil }
+
+func (f *fakeIdentity) GetMSPID() (string, error) { return f.mspID, nil }
+
+func (f *fakeIdentity) GetAttributeValue(attr string) (string, bool, error) {
+	value, ok := f.attrs[attr]
+	return value, ok, nil
+}
+
+func (f *fakeIdentity) AssertAttributeValue(attr string, value string) error {
+	if actual, ok := f.attrs[attr]; !ok || actual != value {
+		return fmt.Errorf("attribute %s is not %s", attr, value)
+	}
+	return nil
+}
+
+func (f *fakeIdentity) GetX509Certificate() (*x509.Certificate, error) { return nil, nil }
+
+func newIdentity(mspID string, id string, attrs map[string]string) *fakeIdentity {
+	if attrs == nil {
+		attrs = map[string]string{}
+	}
+	return &fakeIdentity{mspID: mspID, id: id, attrs: attrs}
+}
+
+// Contexto de transação sobre um MockStub com a identidade informada
+func newTestContext(stub *shimtest.MockStub, identity *fakeIdentity) *contractapi.TransactionContext {
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(stub)
+	ctx.SetClientIdentity(identity)
+	return ctx
+}
+
+// Executa fn dentro de uma transação do MockStub, como o peer faria
+func inTx(t *testing.T, stub *shimtest.MockStub, fn func() error) error {
+	t.Helper()
+	stub.MockTransactionStart(t.Name())
+	defer stub.MockTransactionEnd(t.Name())
+	return fn()
+}
+
+// Como inTx, mas falha o teste em caso de erro
+func mustTx(t *testing.T, stub *shimtest.MockStub, fn func() error) {
+	t.Helper()
+	if err := inTx(t, stub, fn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Registra os kits informados como da organização do contexto
+func registerKits(t *testing.T, stub *shimtest.MockStub, ctx contractapi.TransactionContextInterface, kits ...string) {
+	t.Helper()
+	for _, idKit := range kits {
+		mustTx(t, stub, func() error { return new(SmartContract).RegisterKit(ctx, idKit) })
+	}
+}
+
+// SHA-256 em hexadecimal, usado como hash de imagem nos testes
+func imageHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Metadados de captura válidos para o teste informado
+func captureMetadata(t *testing.T, testID string) string {
+	t.Helper()
+	bytes, err := json.Marshal(ImageMetadata{
+		TestID:         testID,
+		CapturedAt:     "2025-01-01T12:00:00Z",
+		DeviceID:       "LEITOR-001",
+		MimeType:       "image/jpeg",
+		Width:          1920,
+		Height:         1080,
+		SizeBytes:      2048,
+		HashAlgorithm:  "sha256",
+		PerceptualHash: "00ff00ff00ff00ff",
+		BlurScore:      0.3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bytes)
+}
+
+// Lê a imagem gravada no estado do MockStub
+func storedImage(t *testing.T, stub *shimtest.MockStub, hashData string) *ImageAsset {
+	t.Helper()
+	var asset ImageAsset
+	if err := json.Unmarshal(stub.State[hashData], &asset); err != nil {
+		t.Fatalf("image %s not stored: %v", hashData, err)
+	}
+	return &asset
+}
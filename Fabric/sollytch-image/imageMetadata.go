@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Tolerância para relógios de leitores adiantados em relação ao ledger
+const captureClockSkew = 5 * time.Minute
+
+// Tamanho do hash (em caracteres hexadecimais) por algoritmo aceito
+var hashHexLength = map[string]int{
+	"sha256": 64,
+	"sha512": 128,
+}
+
+// Tipos de imagem aceitos
+var allowedMimeTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// Tamanho do hash perceptual (pHash de 64 bits em hexadecimal)
+const perceptualHashHexLength = 16
+
+/*
+	struct json dos metadados de captura de uma imagem
+	Vincula a imagem ao teste e ao leitor que a produziu, para que o
+	registro sirva sozinho como evidência do teste
+*/
+type ImageMetadata struct {
+	TestID         string  `json:"testId"`
+	CapturedAt     string  `json:"capturedAt"`
+	DeviceID       string  `json:"deviceId"`
+	MimeType       string  `json:"mimeType"`
+	Width          int     `json:"width"`
+	Height         int     `json:"height"`
+	SizeBytes      int64   `json:"sizeBytes"`
+	HashAlgorithm  string  `json:"hashAlgorithm"`
+	PerceptualHash string  `json:"perceptualHash"`
+	BlurScore      float64 `json:"blurScore"`
+}
+
+// Função que verifica se hashData é um hash hexadecimal do algoritmo informado
+func validateHashData(hashData string, algorithm string) error {
+	length, ok := hashHexLength[algorithm]
+	if !ok {
+		return fmt.Errorf("hashAlgorithm %s não suportado", algorithm)
+	}
+
+	if len(hashData) != length || strings.ToLower(hashData) != hashData {
+		return fmt.Errorf("hashData deve ter %d caracteres hexadecimais minúsculos para %s", length, algorithm)
+	}
+	if _, err := hex.DecodeString(hashData); err != nil {
+		return fmt.Errorf("hashData não está em hexadecimal: %v", err)
+	}
+
+	return nil
+}
+
+/*
+	Função que decodifica e valida os metadados recebidos em StoreImage
+	now é o timestamp da transação, usado para rejeitar capturas no futuro
+*/
+func parseImageMetadata(metadataJSON string, hashData string, now time.Time) (*ImageMetadata, error) {
+	var metadata ImageMetadata
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar metadados da imagem: %v", err)
+	}
+
+	if metadata.TestID == "" || metadata.DeviceID == "" {
+		return nil, fmt.Errorf("testId e deviceId são obrigatórios")
+	}
+
+	capturedAt, err := time.Parse(time.RFC3339, metadata.CapturedAt)
+	if err != nil {
+		return nil, fmt.Errorf("capturedAt deve estar no formato RFC3339: %v", err)
+	}
+	if capturedAt.After(now.Add(captureClockSkew)) {
+		return nil, fmt.Errorf("capturedAt %s posterior à transação", metadata.CapturedAt)
+	}
+	// Grava em UTC para que as consultas por período (QueryImages) comparem
+	// datas no mesmo formato
+	metadata.CapturedAt = capturedAt.UTC().Format(time.RFC3339)
+
+	if !allowedMimeTypes[metadata.MimeType] {
+		return nil, fmt.Errorf("mimeType %s não suportado", metadata.MimeType)
+	}
+	if metadata.Width <= 0 || metadata.Height <= 0 {
+		return nil, fmt.Errorf("width e height devem ser positivos")
+	}
+	if metadata.SizeBytes <= 0 {
+		return nil, fmt.Errorf("sizeBytes deve ser positivo")
+	}
+
+	if err := validateHashData(hashData, metadata.HashAlgorithm); err != nil {
+		return nil, err
+	}
+
+	if len(metadata.PerceptualHash) != perceptualHashHexLength {
+		return nil, fmt.Errorf("perceptualHash deve ter %d caracteres hexadecimais", perceptualHashHexLength)
+	}
+	if _, err := hex.DecodeString(metadata.PerceptualHash); err != nil {
+		return nil, fmt.Errorf("perceptualHash não está em hexadecimal: %v", err)
+	}
+	metadata.PerceptualHash = strings.ToLower(metadata.PerceptualHash)
+
+	if metadata.BlurScore < 0 {
+		return nil, fmt.Errorf("blurScore não pode ser negativo")
+	}
+
+	return &metadata, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseImageMetadata(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	sha256Hash := imageHash("cassete")
+	sha512Hash := strings.Repeat("ab", 64)
+
+	valid := map[string]interface{}{
+		"testId":         "TEST-00001",
+		"capturedAt":     "2025-01-01T09:00:00-03:00",
+		"deviceId":       "LEITOR-001",
+		"mimeType":       "image/png",
+		"width":          640,
+		"height":         480,
+		"sizeBytes":      1024,
+		"hashAlgorithm":  "sha256",
+		"perceptualHash": "00FF00FF00FF00FF",
+		"blurScore":      0.2,
+	}
+
+	// Cópia dos metadados válidos com os campos alterados
+	with := func(changes map[string]interface{}) string {
+		fields := map[string]interface{}{}
+		for key, value := range valid {
+			fields[key] = value
+		}
+		for key, value := range changes {
+			if value == nil {
+				delete(fields, key)
+				continue
+			}
+			fields[key] = value
+		}
+		bytes, err := json.Marshal(fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(bytes)
+	}
+
+	tests := []struct {
+		name     string
+		metadata string
+		hash     string
+		err      string
+	}{
+		{"valid sha256", with(nil), sha256Hash, ""},
+		{"valid sha512", with(map[string]interface{}{"hashAlgorithm": "sha512"}), sha512Hash, ""},
+		{"clock skew tolerated", with(map[string]interface{}{"capturedAt": "2025-01-01T12:04:00Z"}), sha256Hash, ""},
+		{"invalid json", "{", sha256Hash, "decodificar"},
+		{"missing testId", with(map[string]interface{}{"testId": nil}), sha256Hash, "testId e deviceId"},
+		{"missing deviceId", with(map[string]interface{}{"deviceId": ""}), sha256Hash, "testId e deviceId"},
+		{"capturedAt not RFC3339", with(map[string]interface{}{"capturedAt": "01/01/2025"}), sha256Hash, "RFC3339"},
+		{"capturedAt in the future", with(map[string]interface{}{"capturedAt": "2025-01-01T12:10:00Z"}), sha256Hash, "posterior"},
+		{"unsupported mimeType", with(map[string]interface{}{"mimeType": "image/gif"}), sha256Hash, "mimeType"},
+		{"zero width", with(map[string]interface{}{"width": 0}), sha256Hash, "width e height"},
+		{"zero size", with(map[string]interface{}{"sizeBytes": 0}), sha256Hash, "sizeBytes"},
+		{"unsupported algorithm", with(map[string]interface{}{"hashAlgorithm": "md5"}), sha256Hash, "não suportado"},
+		{"hash length mismatch", with(map[string]interface{}{"hashAlgorithm": "sha512"}), sha256Hash, "128 caracteres"},
+		{"uppercase hash", with(nil), strings.ToUpper(sha256Hash), "minúsculos"},
+		{"non hex hash", with(nil), strings.Repeat("zz", 32), "hexadecimal"},
+		{"short perceptualHash", with(map[string]interface{}{"perceptualHash": "00ff"}), sha256Hash, "perceptualHash"},
+		{"non hex perceptualHash", with(map[string]interface{}{"perceptualHash": "zzzzzzzzzzzzzzzz"}), sha256Hash, "perceptualHash"},
+		{"negative blurScore", with(map[string]interface{}{"blurScore": -1}), sha256Hash, "blurScore"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metadata, err := parseImageMetadata(test.metadata, test.hash, now)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Datas em UTC e pHash minúsculo, para consultas consistentes
+			if test.name == "valid sha256" {
+				if metadata.CapturedAt != "2025-01-01T12:00:00Z" {
+					t.Errorf("expected capturedAt in UTC, got %s", metadata.CapturedAt)
+				}
+				if metadata.PerceptualHash != "00ff00ff00ff00ff" {
+					t.Errorf("expected lowercase perceptualHash, got %s", metadata.PerceptualHash)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+/*
+	struct json da organização dona de um kit
+	A posse é registrada explicitamente: pela organização que registra um
+	kit novo (RegisterKit) ou por um administrador, para kits que já
+	possuíam imagens antes do controle de posse (AssignKitOwner)
+*/
+type KitOwner struct {
+	IDKit        string `json:"idKit"`
+	OwnerMSP     string `json:"ownerMsp"`
+	RegisteredAt string `json:"registeredAt"`
+	RegisteredBy string `json:"registeredBy"`
+}
+
+// Função que monta a chave de estado do dono de um kit
+func kitOwnerKey(ctx contractapi.TransactionContextInterface, idKit string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("kit", []string{idKit})
+}
+
+// Função que carrega o dono de um kit; retorna nil se o kit não foi registrado
+func getKitOwner(ctx contractapi.TransactionContextInterface, idKit string) (*KitOwner, error) {
+	key, err := kitOwnerKey(ctx, idKit)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao acessar o ledger: %v", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var owner KitOwner
+	if err := json.Unmarshal(data, &owner); err != nil {
+		return nil, fmt.Errorf("erro ao deserializar dono do kit: %v", err)
+	}
+
+	return &owner, nil
+}
+
+// Função que grava o dono de um kit, registrando quem fez o registro
+func putKitOwner(ctx contractapi.TransactionContextInterface, idKit string, ownerMSP string) error {
+	now, by, err := txAuthor(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := kitOwnerKey(ctx, idKit)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.Marshal(KitOwner{
+		IDKit:        idKit,
+		OwnerMSP:     ownerMSP,
+		RegisteredAt: now,
+		RegisteredBy: by,
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, bytes)
+}
+
+// Função que indica se algum kit já listou imagens pelo índice "kit~hashImagem"
+func kitHasImages(ctx contractapi.TransactionContextInterface, idKit string) (bool, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("kit~hashImagem", []string{idKit})
+	if err != nil {
+		return false, err
+	}
+	defer iterator.Close()
+
+	return iterator.HasNext(), nil
+}
+
+/*
+	Função que autoriza uma escrita nas imagens de um kit
+	O kit precisa estar registrado; apenas clientes da organização dona ou
+	com o papel image_uploader podem escrever imagens do kit
+*/
+func authorizeKitWrite(ctx contractapi.TransactionContextInterface, idKit string) error {
+	mspID, err := callerMSP(ctx)
+	if err != nil {
+		return err
+	}
+
+	owner, err := getKitOwner(ctx, idKit)
+	if err != nil {
+		return err
+	}
+	if owner == nil {
+		return fmt.Errorf("kit %s não registrado (ver RegisterKit)", idKit)
+	}
+
+	if owner.OwnerMSP == mspID || hasRole(ctx, imageUploaderRole) {
+		return nil
+	}
+
+	return accessDenied("kit %s pertence à organização %s", idKit, owner.OwnerMSP)
+}
+
+/*
+	Função que registra um kit novo, tornando a organização do cliente sua
+	dona. Kits que já possuem imagens (registradas antes do controle de
+	posse) só podem receber dono por um administrador, em AssignKitOwner
+*/
+func (c *SmartContract) RegisterKit(ctx contractapi.TransactionContextInterface, idKit string) error {
+	if idKit == "" {
+		return fmt.Errorf("idKit não pode ser vazio")
+	}
+
+	owner, err := getKitOwner(ctx, idKit)
+	if err != nil {
+		return err
+	}
+	if owner != nil {
+		return fmt.Errorf("kit %s já pertence à organização %s", idKit, owner.OwnerMSP)
+	}
+
+	hasImages, err := kitHasImages(ctx, idKit)
+	if err != nil {
+		return err
+	}
+	if hasImages {
+		return accessDenied("kit %s já possui imagens; o dono deve ser atribuído por um administrador", idKit)
+	}
+
+	mspID, err := callerMSP(ctx)
+	if err != nil {
+		return err
+	}
+
+	return putKitOwner(ctx, idKit, mspID)
+}
+
+// Função que atribui (ou transfere) o dono de um kit; restrita ao papel admin
+func (c *SmartContract) AssignKitOwner(ctx contractapi.TransactionContextInterface, idKit string, ownerMSP string) error {
+	if !hasRole(ctx, adminRole) {
+		return accessDenied("papel %s exigido", adminRole)
+	}
+
+	if idKit == "" || ownerMSP == "" {
+		return fmt.Errorf("idKit e ownerMSP são obrigatórios")
+	}
+
+	return putKitOwner(ctx, idKit, ownerMSP)
+}
+
+// Função que consulta a organização dona de um kit
+func (c *SmartContract) GetKitOwner(ctx contractapi.TransactionContextInterface, idKit string) (*KitOwner, error) {
+	if idKit == "" {
+		return nil, fmt.Errorf("idKit não pode ser vazio")
+	}
+
+	owner, err := getKitOwner(ctx, idKit)
+	if err != nil {
+		return nil, err
+	}
+	if owner == nil {
+		return nil, fmt.Errorf("kit %s não registrado", idKit)
+	}
+
+	return owner, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+func TestRegisterKit(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-image", nil)
+	org1 := newTestContext(stub, newIdentity("Org1MSP", "leitor", nil))
+	org2 := newTestContext(stub, newIdentity("Org2MSP", "leitor", nil))
+	uploader := newTestContext(stub, newIdentity("Org2MSP", "integracao", map[string]string{roleAttribute: imageUploaderRole}))
+	contract := new(SmartContract)
+
+	if err := inTx(t, stub, func() error { return contract.RegisterKit(org1, "") }); err == nil {
+		t.Error("expected empty idKit to be rejected")
+	}
+
+	registerKits(t, stub, org1, "KIT-A")
+
+	err := inTx(t, stub, func() error { return contract.RegisterKit(org2, "KIT-A") })
+	if err == nil || !strings.Contains(err.Error(), "Org1MSP") {
+		t.Fatalf("expected a registered kit not to be claimed again, got %v", err)
+	}
+
+	owner, err := contract.GetKitOwner(org2, "KIT-A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner.OwnerMSP != "Org1MSP" || owner.RegisteredBy != "Org1MSP:leitor" {
+		t.Errorf("unexpected kit owner: %+v", owner)
+	}
+	if _, err := contract.GetKitOwner(org1, "KIT-X"); err == nil {
+		t.Error("expected unregistered kit lookup to fail")
+	}
+
+	// O papel image_uploader escreve em kits de outras organizações
+	mustTx(t, stub, func() error {
+		return contract.StoreImage(uploader, "KIT-A", imageHash("cassete"), captureMetadata(t, "TEST-00001"))
+	})
+}
+
+func TestAssignKitOwner(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-image", nil)
+	org2 := newTestContext(stub, newIdentity("Org2MSP", "leitor", nil))
+	admin := newTestContext(stub, newIdentity("Org1MSP", "admin", map[string]string{roleAttribute: adminRole}))
+	contract := new(SmartContract)
+
+	// Kit com imagens gravadas antes do controle de posse
+	mustTx(t, stub, func() error {
+		key, err := stub.CreateCompositeKey("kit~hashImagem", []string{"KIT-L", imageHash("legado")})
+		if err != nil {
+			return err
+		}
+		return stub.PutState(key, []byte{0x00})
+	})
+
+	err := inTx(t, stub, func() error { return contract.RegisterKit(org2, "KIT-L") })
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("expected a kit with images not to be self-registered, got %v", err)
+	}
+
+	err = inTx(t, stub, func() error { return contract.AssignKitOwner(org2, "KIT-L", "Org2MSP") })
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("expected non-admin assignment to be denied, got %v", err)
+	}
+	if err := inTx(t, stub, func() error { return contract.AssignKitOwner(admin, "KIT-L", "") }); err == nil {
+		t.Error("expected empty ownerMSP to be rejected")
+	}
+
+	mustTx(t, stub, func() error { return contract.AssignKitOwner(admin, "KIT-L", "Org2MSP") })
+
+	owner, err := contract.GetKitOwner(org2, "KIT-L")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner.OwnerMSP != "Org2MSP" || owner.RegisteredBy != "Org1MSP:admin" {
+		t.Errorf("unexpected kit owner: %+v", owner)
+	}
+
+	mustTx(t, stub, func() error {
+		return contract.StoreImage(org2, "KIT-L", imageHash("nova"), captureMetadata(t, "TEST-00001"))
+	})
+}
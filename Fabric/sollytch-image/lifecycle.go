@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Situações de uma imagem; registros antigos sem status são ativos
+const (
+	imageActive     = "ativa"
+	imageRevoked    = "revogada"
+	imageSuperseded = "substituida"
+)
+
+// Função que indica se a imagem está ativa
+func imageIsActive(asset *ImageAsset) bool {
+	return asset.Status == "" || asset.Status == imageActive
+}
+
+// Função que grava uma imagem no ledger, registrando versão, data e autor da alteração
+func putImageAsset(ctx contractapi.TransactionContextInterface, asset *ImageAsset, updatedAt string, updatedBy string) error {
+	asset.Version++
+	asset.LastUpdatedAt = updatedAt
+	asset.LastUpdatedBy = updatedBy
+
+	bytes, err := json.Marshal(asset)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(asset.HashData, bytes)
+}
+
+// Função que retorna o timestamp da transação e a identidade de quem a submeteu
+func txAuthor(ctx contractapi.TransactionContextInterface) (string, string, error) {
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return "", "", err
+	}
+
+	by, err := callerID(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC().Format(time.RFC3339), by, nil
+}
+
+/*
+	Função que marca uma imagem como revogada (kit errado, foto inutilizável)
+	O registro é mantido no ledger, apenas inativado, com o motivo e a
+	identidade de quem revogou
+*/
+func (c *SmartContract) RevokeImage(ctx contractapi.TransactionContextInterface, hashImagem string, reason string) error {
+	if reason == "" {
+		return fmt.Errorf("reason não pode ser vazio")
+	}
+
+	asset, err := c.GetImageByID(ctx, hashImagem)
+	if err != nil {
+		return err
+	}
+	if !imageIsActive(asset) {
+		return fmt.Errorf("imagem %s já está %s", hashImagem, asset.Status)
+	}
+
+	now, by, err := txAuthor(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := authorizeKitWrite(ctx, asset.IDKit); err != nil {
+		return err
+	}
+
+	asset.Status = imageRevoked
+	asset.InactivatedAt = now
+	asset.InactivatedBy = by
+	asset.InactivationReason = reason
+
+	return putImageAsset(ctx, asset, now, by)
+}
+
+/*
+	Função que substitui uma imagem por outra já registrada originalmente
+	pelo mesmo kit
+	A imagem antiga é mantida, inativada e ligada à nova (supersededBy); a
+	nova registra qual imagem substitui (supersedes)
+*/
+func (c *SmartContract) SupersedeImage(ctx contractapi.TransactionContextInterface, oldHash string, newHash string) error {
+	if oldHash == newHash {
+		return fmt.Errorf("uma imagem não pode substituir a si mesma")
+	}
+
+	oldAsset, err := c.GetImageByID(ctx, oldHash)
+	if err != nil {
+		return err
+	}
+	if !imageIsActive(oldAsset) {
+		return fmt.Errorf("imagem %s já está %s", oldHash, oldAsset.Status)
+	}
+
+	newAsset, err := c.GetImageByID(ctx, newHash)
+	if err != nil {
+		return err
+	}
+	if !imageIsActive(newAsset) {
+		return fmt.Errorf("imagem %s está %s e não pode substituir outra", newHash, newAsset.Status)
+	}
+	// Uma imagem apenas reivindicada pelo kit (reuso) pertence a outro kit
+	// e não pode substituir a evidência deste
+	if newAsset.IDKit != oldAsset.IDKit {
+		return fmt.Errorf("imagem %s não pertence ao kit %s", newHash, oldAsset.IDKit)
+	}
+
+	now, by, err := txAuthor(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := authorizeKitWrite(ctx, oldAsset.IDKit); err != nil {
+		return err
+	}
+
+	oldAsset.Status = imageSuperseded
+	oldAsset.InactivatedAt = now
+	oldAsset.InactivatedBy = by
+	oldAsset.InactivationReason = "substituida por " + newHash
+	oldAsset.SupersededBy = newHash
+
+	if err := putImageAsset(ctx, oldAsset, now, by); err != nil {
+		return err
+	}
+
+	newAsset.Supersedes = oldHash
+
+	return putImageAsset(ctx, newAsset, now, by)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+func TestRevokeImage(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-image", nil)
+	org1 := newTestContext(stub, newIdentity("Org1MSP", "leitor", nil))
+	org2 := newTestContext(stub, newIdentity("Org2MSP", "leitor", nil))
+	contract := new(SmartContract)
+	hash := imageHash("cassete")
+
+	registerKits(t, stub, org1, "KIT-A")
+	mustTx(t, stub, func() error { return contract.StoreImage(org1, "KIT-A", hash, captureMetadata(t, "TEST-00001")) })
+
+	if err := inTx(t, stub, func() error { return contract.RevokeImage(org1, hash, "") }); err == nil {
+		t.Error("expected an empty reason to be rejected")
+	}
+
+	err := inTx(t, stub, func() error { return contract.RevokeImage(org2, hash, "kit errado") })
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("expected other organisation to be denied, got %v", err)
+	}
+
+	mustTx(t, stub, func() error { return contract.RevokeImage(org1, hash, "kit errado") })
+
+	asset := storedImage(t, stub, hash)
+	if asset.Status != imageRevoked || asset.InactivationReason != "kit errado" || asset.InactivatedBy != "Org1MSP:leitor" || asset.Version != 1 {
+		t.Errorf("unexpected revoked image: %+v", asset)
+	}
+
+	if err := inTx(t, stub, func() error { return contract.RevokeImage(org1, hash, "de novo") }); err == nil {
+		t.Error("expected a revoked image not to be revoked again")
+	}
+	if err := inTx(t, stub, func() error { return contract.StoreImage(org1, "KIT-A", hash, captureMetadata(t, "TEST-00001")) }); err == nil {
+		t.Error("expected a revoked image not to be stored again")
+	}
+
+	var active, all []*ImageAsset
+	mustTx(t, stub, func() error {
+		var err error
+		if active, err = contract.GetImagesByKit(org1, "KIT-A"); err != nil {
+			return err
+		}
+		all, err = contract.GetAllImagesByKit(org1, "KIT-A")
+		return err
+	})
+	if len(active) != 0 || len(all) != 1 {
+		t.Errorf("expected revoked image only in GetAllImagesByKit, got %d active and %d total", len(active), len(all))
+	}
+}
+
+func TestSupersedeImage(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-image", nil)
+	org1 := newTestContext(stub, newIdentity("Org1MSP", "leitor", nil))
+	org2 := newTestContext(stub, newIdentity("Org2MSP", "leitor", nil))
+	contract := new(SmartContract)
+	oldHash, newHash, otherKit := imageHash("antiga"), imageHash("nova"), imageHash("outro kit")
+	reused := imageHash("reuso")
+
+	registerKits(t, stub, org1, "KIT-A", "KIT-C")
+	registerKits(t, stub, org2, "KIT-D")
+	mustTx(t, stub, func() error { return contract.StoreImage(org1, "KIT-A", oldHash, captureMetadata(t, "TEST-00001")) })
+	mustTx(t, stub, func() error { return contract.StoreImage(org1, "KIT-A", newHash, captureMetadata(t, "TEST-00001")) })
+	mustTx(t, stub, func() error { return contract.StoreImage(org1, "KIT-C", otherKit, captureMetadata(t, "TEST-00009")) })
+
+	if err := inTx(t, stub, func() error { return contract.SupersedeImage(org1, oldHash, oldHash) }); err == nil {
+		t.Error("expected an image not to supersede itself")
+	}
+	if err := inTx(t, stub, func() error { return contract.SupersedeImage(org1, oldHash, otherKit) }); err == nil {
+		t.Error("expected an image of another kit to be rejected")
+	}
+
+	// KIT-A reivindica uma imagem registrada por KIT-D: o reuso não a torna do kit
+	mustTx(t, stub, func() error { return contract.StoreImage(org2, "KIT-D", reused, captureMetadata(t, "TEST-00010")) })
+	mustTx(t, stub, func() error { return contract.StoreImage(org1, "KIT-A", reused, captureMetadata(t, "TEST-00001")) })
+	if !claimedByKit(storedImage(t, stub, reused), "KIT-A") {
+		t.Fatal("expected KIT-A to have claimed the reused image")
+	}
+	if err := inTx(t, stub, func() error { return contract.SupersedeImage(org1, oldHash, reused) }); err == nil {
+		t.Error("expected an image only claimed by the kit to be rejected")
+	}
+
+	mustTx(t, stub, func() error { return contract.SupersedeImage(org1, oldHash, newHash) })
+
+	old := storedImage(t, stub, oldHash)
+	if old.Status != imageSuperseded || old.SupersededBy != newHash {
+		t.Errorf("unexpected superseded image: %+v", old)
+	}
+	replacement := storedImage(t, stub, newHash)
+	if !imageIsActive(replacement) || replacement.Supersedes != oldHash {
+		t.Errorf("unexpected replacement image: %+v", replacement)
+	}
+
+	if err := inTx(t, stub, func() error { return contract.SupersedeImage(org1, newHash, oldHash) }); err == nil {
+		t.Error("expected an inactive image not to supersede another")
+	}
+}
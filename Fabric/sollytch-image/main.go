@@ -3,6 +3,7 @@ package main
 import (
     "encoding/json"
     "fmt"
+    "os"
     "time"
 
     "github.com/hyperledger/fabric-contract-api-go/contractapi"
@@ -13,11 +14,27 @@ type ImageAsset struct {
     // trackers
     Version       int    `json:"version"`
     LastUpdatedAt string `json:"lastUpdatedAt"`
+    LastUpdatedBy string `json:"lastUpdatedBy"`
     Timestamp     string `json:"timestamp"`
 
     // chave de busca
     IDKit         string `json:"idKit"`
     HashData      string `json:"hashData"`
+
+    // metadados da captura (teste, leitor, formato e qualidade)
+    ImageMetadata
+
+    // reuso do mesmo hash por outros kits
+    Reused        bool         `json:"reused"`
+    ReuseClaims   []ReuseClaim `json:"reuseClaims"`
+
+    // revogação e substituição
+    Status             string `json:"status"`
+    InactivatedAt      string `json:"inactivatedAt,omitempty"`
+    InactivatedBy      string `json:"inactivatedBy,omitempty"`
+    InactivationReason string `json:"inactivationReason,omitempty"`
+    SupersededBy       string `json:"supersededBy,omitempty"`
+    Supersedes         string `json:"supersedes,omitempty"`
 }
 
 type SmartContract struct {
@@ -27,11 +44,30 @@ type SmartContract struct {
 /*
 	Função responsável por armazenar ou atualizar o hash de uma imagem no ledger. Recebe hashData como
     chave principal e idKit como indice secundário por meio de chave composta
+    metadataJSON traz os metadados da captura (testId, capturedAt, deviceId, mimeType, width,
+    height, sizeBytes, hashAlgorithm, perceptualHash e blurScore), validados antes da gravação
+    Um hash já registrado por outro kit não é sobrescrito: o reuso é anexado ao registro
+    e sinalizado pelo evento ImageReuse (ver GetReusedImages)
 */
-func (c *SmartContract) StoreImage(ctx contractapi.TransactionContextInterface, idKit string, hashData string) error {
+func (c *SmartContract) StoreImage(ctx contractapi.TransactionContextInterface, idKit string, hashData string, metadataJSON string) error {
+	_, reuse, err := c.storeImage(ctx, idKit, hashData, metadataJSON)
+	if err != nil {
+		return err
+	}
+
+	// Sinaliza o reuso da imagem por outro kit
+	if reuse != nil {
+		return emitImageReuse(ctx, reuse)
+	}
+
+	return nil
+}
+
+// Função que grava uma imagem e seus índices, retornando o resultado e o reuso detectado, se houver
+func (c *SmartContract) storeImage(ctx contractapi.TransactionContextInterface, idKit string, hashData string, metadataJSON string) (*ImageStoreResult, *imageReuseEventPayload, error) {
 	// Valida se recebeu o hash da imagem e o id do kit
 	if hashData == "" || idKit == "" {
-		return fmt.Errorf("hashData e idKit são obrigatórios")
+		return nil, nil, rejectImage("hashData e idKit são obrigatórios")
 	}
 
 	// Define hash como chave principal
@@ -40,36 +76,84 @@ func (c *SmartContract) StoreImage(ctx contractapi.TransactionContextInterface,
 	// Obtém timestamp da transação
 	txTime, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	formattedTime := time.Unix(
+	now := time.Unix(
 		txTime.Seconds,
 		int64(txTime.Nanos),
-	).UTC().Format(time.RFC3339)
+	).UTC()
+	formattedTime := now.Format(time.RFC3339)
+
+	// Valida os metadados da captura
+	metadata, err := parseImageMetadata(metadataJSON, hashData, now)
+	if err != nil {
+		return nil, nil, &imageRejectedError{err: err}
+	}
+
+	// Apenas a organização dona do kit registrado (ou o papel image_uploader) pode enviar imagens
+	if err := authorizeKitWrite(ctx, idKit); err != nil {
+		return nil, nil, &imageRejectedError{err: err}
+	}
 
 	// Verifica se a imagem já existe
 	exists, err := c.ImageExists(ctx, imageKey)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	var asset ImageAsset
+	var reuse *imageReuseEventPayload
+	result := &ImageStoreResult{IDKit: idKit, HashData: hashData, Status: imageStored}
 
     // caso exista
 	if exists {
 		// Carrega imagem existente para atualização
 		assetBytes, err := ctx.GetStub().GetState(imageKey)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 		if assetBytes == nil {
-			return fmt.Errorf("falha ao carregar imagem existente com hash %s", hashData)
+			return nil, nil, fmt.Errorf("falha ao carregar imagem existente com hash %s", hashData)
 		}
 
 		// Desserializa dados existentes
 		if err := json.Unmarshal(assetBytes, &asset); err != nil {
-			return err
+			return nil, nil, err
+		}
+
+		if asset.ReuseClaims == nil {
+			asset.ReuseClaims = []ReuseClaim{}
+		}
+
+		// Imagens revogadas ou substituídas não podem ser reenviadas
+		if !imageIsActive(&asset) {
+			return nil, nil, rejectImage("imagem %s está %s", hashData, asset.Status)
+		}
+
+		if asset.IDKit == idKit {
+			// Reenvio pelo mesmo kit: atualiza os metadados e move o índice
+			// por teste caso o teste vinculado tenha mudado
+			if asset.TestID != "" && asset.TestID != metadata.TestID {
+				oldIndexKey, err := testImageIndexKey(ctx, asset.TestID, hashData)
+				if err != nil {
+					return nil, nil, err
+				}
+				if err := ctx.GetStub().DelState(oldIndexKey); err != nil {
+					return nil, nil, err
+				}
+			}
+			asset.ImageMetadata = *metadata
+			result.Status = imageUpdated
+		} else if !claimedByKit(&asset, idKit) {
+			// Mesmo hash enviado por outro kit: mantém o original e sinaliza o reuso
+			reuse, err = recordImageReuse(ctx, &asset, idKit, metadata, formattedTime)
+			if err != nil {
+				return nil, nil, err
+			}
+			result.Status = imageReused
+		} else {
+			result.Status = imageUpdated
 		}
 
 		// Incrementa versão e atualiza timestamp
@@ -86,6 +170,9 @@ func (c *SmartContract) StoreImage(ctx contractapi.TransactionContextInterface,
 			HashData:      hashData,
 			Version:       0,
 			LastUpdatedAt: formattedTime,
+			ImageMetadata: *metadata,
+			ReuseClaims:   []ReuseClaim{},
+			Status:        imageActive,
 		}
 
 		// Cria chave composta para indexação por kit
@@ -94,29 +181,55 @@ func (c *SmartContract) StoreImage(ctx contractapi.TransactionContextInterface,
 			[]string{idKit, hashData},
 		)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 
 		// Armazena índice no ledger
 		if err := ctx.GetStub().PutState(indexKey, []byte{0x00}); err != nil {
-			return err
+			return nil, nil, err
 		}
 	}
 
+	// Indexa a imagem pelo teste que a enviou
+	if err := putTestImageIndex(ctx, metadata.TestID, hashData, idKit); err != nil {
+		return nil, nil, err
+	}
+
+	// Registra quem submeteu esta versão, exibido em GetImageHistory
+	asset.LastUpdatedBy, err = callerID(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Serializa objeto
 	assetBytes, err := json.Marshal(asset)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	// Salva o hash como chave principal
-	return ctx.GetStub().PutState(imageKey, assetBytes)
+	if err := ctx.GetStub().PutState(imageKey, assetBytes); err != nil {
+		return nil, nil, err
+	}
+
+	return result, reuse, nil
 }
 
 /*
-	Função que retorna todos os hashes de imagens atrelados a um unico kit. Retorna uma lista com todos os itens inclusos
+	Função que retorna todos os hashes de imagens ativas atrelados a um unico kit. Retorna uma lista com todos os itens inclusos
+	Imagens revogadas ou substituídas são omitidas; use GetAllImagesByKit para incluí-las
 */
 func (c *SmartContract) GetImagesByKit(ctx contractapi.TransactionContextInterface, idKit string,) ([]*ImageAsset, error) {
+    return c.imagesByKit(ctx, idKit, false)
+}
+
+// Função que retorna todas as imagens de um kit, inclusive revogadas e substituídas
+func (c *SmartContract) GetAllImagesByKit(ctx contractapi.TransactionContextInterface, idKit string) ([]*ImageAsset, error) {
+    return c.imagesByKit(ctx, idKit, true)
+}
+
+// Função que percorre o índice "kit~hashImagem", incluindo ou não as imagens inativas
+func (c *SmartContract) imagesByKit(ctx contractapi.TransactionContextInterface, idKit string, includeInactive bool) ([]*ImageAsset, error) {
     // Valida se recebeu o id do kit
     if idKit==""{
         return nil, fmt.Errorf("idKit não pode ser vazio")
@@ -155,6 +268,10 @@ func (c *SmartContract) GetImagesByKit(ctx contractapi.TransactionContextInterfa
             return nil, err
         }
 
+        if !includeInactive && !imageIsActive(image) {
+            continue
+        }
+
         results = append(results, image)
     }
 
@@ -190,6 +307,11 @@ func (c *SmartContract) GetImageByID(ctx contractapi.TransactionContextInterface
         return nil, fmt.Errorf("erro ao deserializar imagem: %v", err)
     }
 
+    // Registros anteriores ao controle de reuso não possuem a lista
+    if asset.ReuseClaims == nil {
+        asset.ReuseClaims = []ReuseClaim{}
+    }
+
     return &asset, nil
 }
 
@@ -210,8 +332,14 @@ func main() {
 		panic(fmt.Sprintf("erro criando chaincode: %v", err))
 	}
 	
-	// Inicia o chaincode e aguarda por transações
-	if err := chaincode.Start(); err != nil {
+	// Inicia o chaincode e aguarda por transações, como serviço externo
+	// (RUN_CCAAS=true) ou conectado ao peer
+	if os.Getenv("RUN_CCAAS") == "true" {
+		err = runCCaaS(chaincode)
+	} else {
+		err = chaincode.Start()
+	}
+	if err != nil {
 		panic(fmt.Sprintf("erro iniciando chaincode: %v", err))
 	}
 }
\ No newline at end of file
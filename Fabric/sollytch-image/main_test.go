@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+func TestStoreImage(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-image", nil)
+	org1 := newTestContext(stub, newIdentity("Org1MSP", "leitor", nil))
+	org2 := newTestContext(stub, newIdentity("Org2MSP", "leitor", nil))
+	contract := new(SmartContract)
+	hash := imageHash("cassete")
+
+	// Kit não registrado não recebe imagens
+	err := inTx(t, stub, func() error { return contract.StoreImage(org1, "KIT-A", hash, captureMetadata(t, "TEST-00001")) })
+	if err == nil || !strings.Contains(err.Error(), "não registrado") {
+		t.Fatalf("expected unregistered kit to be rejected, got %v", err)
+	}
+
+	registerKits(t, stub, org1, "KIT-A")
+	registerKits(t, stub, org2, "KIT-B")
+
+	// Primeiro envio: registra a imagem e os índices
+	mustTx(t, stub, func() error { return contract.StoreImage(org1, "KIT-A", hash, captureMetadata(t, "TEST-00001")) })
+
+	asset := storedImage(t, stub, hash)
+	if asset.IDKit != "KIT-A" || asset.TestID != "TEST-00001" || asset.Status != imageActive || asset.Version != 0 {
+		t.Errorf("unexpected stored image: %+v", asset)
+	}
+	if asset.LastUpdatedBy != "Org1MSP:leitor" {
+		t.Errorf("expected submitter to be recorded, got %s", asset.LastUpdatedBy)
+	}
+
+	var owner *KitOwner
+	mustTx(t, stub, func() error {
+		var err error
+		owner, err = contract.GetKitOwner(org1, "KIT-A")
+		return err
+	})
+	if owner.OwnerMSP != "Org1MSP" {
+		t.Errorf("expected Org1MSP to own KIT-A, got %s", owner.OwnerMSP)
+	}
+
+	// Reenvio pelo mesmo kit com outro teste: atualiza e move o índice por teste
+	mustTx(t, stub, func() error { return contract.StoreImage(org1, "KIT-A", hash, captureMetadata(t, "TEST-00002")) })
+
+	asset = storedImage(t, stub, hash)
+	if asset.Version != 1 || asset.TestID != "TEST-00002" {
+		t.Errorf("expected updated image, got %+v", asset)
+	}
+	oldIndex, _ := stub.CreateCompositeKey("teste~hashImagem", []string{"TEST-00001", hash})
+	if _, ok := stub.State[oldIndex]; ok {
+		t.Error("expected index of the previous test to be removed")
+	}
+
+	// Outra organização não escreve no kit alheio
+	err = inTx(t, stub, func() error { return contract.StoreImage(org2, "KIT-A", imageHash("outra"), captureMetadata(t, "TEST-00003")) })
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("expected access denied, got %v", err)
+	}
+
+	// O mesmo hash enviado por outro kit é registrado como reuso
+	mustTx(t, stub, func() error { return contract.StoreImage(org2, "KIT-B", hash, captureMetadata(t, "TEST-00004")) })
+
+	asset = storedImage(t, stub, hash)
+	if asset.IDKit != "KIT-A" || !asset.Reused || len(asset.ReuseClaims) != 1 || asset.ReuseClaims[0].IDKit != "KIT-B" {
+		t.Errorf("expected reuse claim by KIT-B, got %+v", asset)
+	}
+
+	event := <-stub.ChaincodeEventsChannel
+	if event.EventName != imageReuseEvent {
+		t.Fatalf("expected %s event, got %s", imageReuseEvent, event.EventName)
+	}
+	var payload imageReuseEventPayload
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.OriginalKit != "KIT-A" || payload.ClaimingKit != "KIT-B" || payload.ClaimingTest != "TEST-00004" {
+		t.Errorf("unexpected reuse event: %+v", payload)
+	}
+
+	var images []*ImageAsset
+	mustTx(t, stub, func() error {
+		var err error
+		images, err = contract.GetImagesByKit(org2, "KIT-B")
+		return err
+	})
+	if len(images) != 1 || images[0].HashData != hash {
+		t.Errorf("expected KIT-B to list the reused image, got %v", images)
+	}
+
+	// Metadados inválidos não gravam nada
+	err = inTx(t, stub, func() error { return contract.StoreImage(org1, "KIT-A", imageHash("nova"), `{"testId":"TEST-00005"}`) })
+	if err == nil {
+		t.Fatal("expected invalid metadata to be rejected")
+	}
+	if _, ok := stub.State[imageHash("nova")]; ok {
+		t.Error("expected rejected image not to be stored")
+	}
+}
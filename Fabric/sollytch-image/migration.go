@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Intervalo das chaves simples percorridas pela migração; as chaves
+// compostas começam com \x00 e ficam de fora, como no peer
+const (
+	migrationStartKey = "\x01"
+	migrationEndKey   = string(utf8.MaxRune)
+)
+
+// Resultado de uma página de migração de imagens
+type MigrationResult struct {
+	Scanned  int    `json:"scanned"`
+	Migrated int    `json:"migrated"`
+	Bookmark string `json:"bookmark"`
+	Done     bool   `json:"done"`
+}
+
+// Função que monta a chave do cursor da migração de status das imagens
+func migrationCursorKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("migracao~status", []string{})
+}
+
+/*
+	Função que grava o status "ativa" nas imagens registradas antes do campo
+	status existir, para que QueryImages as encontre pelo índice de status.
+	Restrita a administradores e executada em páginas: percorre as chaves
+	simples do ledger a partir do cursor gravado pela página anterior (a
+	última chave processada). Ao terminar, o cursor é removido
+*/
+func (c *SmartContract) MigrateImageStatus(ctx contractapi.TransactionContextInterface, pageSize int) (*MigrationResult, error) {
+	if !hasRole(ctx, adminRole) {
+		return nil, accessDenied("papel %s exigido", adminRole)
+	}
+
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("pageSize deve ser positivo")
+	}
+
+	cursorKey, err := migrationCursorKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := ctx.GetStub().GetState(cursorKey)
+	if err != nil {
+		return nil, err
+	}
+
+	startKey := migrationStartKey
+	if cursor != nil {
+		startKey = string(cursor)
+	}
+
+	// O intervalo inclui a chave inicial, que já foi processada
+	iterator, err := ctx.GetStub().GetStateByRange(startKey, migrationEndKey)
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	result := &MigrationResult{Bookmark: string(cursor)}
+
+	for iterator.HasNext() {
+		if result.Scanned == pageSize {
+			return result, ctx.GetStub().PutState(cursorKey, []byte(result.Bookmark))
+		}
+
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		if response.Key == string(cursor) {
+			continue
+		}
+
+		result.Scanned++
+		result.Bookmark = response.Key
+
+		migrated, err := migrateImageStatus(response.Key, response.Value)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao migrar imagem %s: %v", response.Key, err)
+		}
+		if migrated == nil {
+			continue
+		}
+
+		if err := ctx.GetStub().PutState(response.Key, migrated); err != nil {
+			return nil, err
+		}
+		result.Migrated++
+	}
+
+	result.Done = true
+	return result, ctx.GetStub().DelState(cursorKey)
+}
+
+/*
+	Função que grava o status "ativa" em uma imagem sem status, preservando
+	os demais campos. Retorna nil quando a chave não é uma imagem (o hash
+	gravado é a própria chave) ou quando o status já está preenchido
+*/
+func migrateImageStatus(key string, data []byte) ([]byte, error) {
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, nil
+	}
+
+	var hashData, status string
+	if raw, ok := record["hashData"]; !ok || json.Unmarshal(raw, &hashData) != nil || hashData != key {
+		return nil, nil
+	}
+	if raw, ok := record["status"]; ok {
+		if err := json.Unmarshal(raw, &status); err != nil {
+			return nil, err
+		}
+	}
+	if status != "" {
+		return nil, nil
+	}
+
+	active, err := json.Marshal(imageActive)
+	if err != nil {
+		return nil, err
+	}
+	record["status"] = active
+
+	return json.Marshal(record)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+func TestMigrateImageStatus(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-image", nil)
+	org1 := newTestContext(stub, newIdentity("Org1MSP", "leitor", nil))
+	admin := newTestContext(stub, newIdentity("Org1MSP", "admin", map[string]string{roleAttribute: adminRole}))
+	contract := new(SmartContract)
+
+	legacy := []string{imageHash("legado 1"), imageHash("legado 2"), imageHash("legado 3")}
+	current := imageHash("atual")
+
+	registerKits(t, stub, org1, "KIT-A")
+	mustTx(t, stub, func() error { return contract.StoreImage(org1, "KIT-A", current, captureMetadata(t, "TEST-00001")) })
+	mustTx(t, stub, func() error {
+		for _, hash := range legacy {
+			if err := stub.PutState(hash, []byte(`{"idKit":"KIT-L","hashData":"`+hash+`","version":2}`)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	before := string(stub.State[current])
+
+	err := inTx(t, stub, func() error {
+		_, err := contract.MigrateImageStatus(org1, 2)
+		return err
+	})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("expected non-admin migration to be denied, got %v", err)
+	}
+
+	// Duas páginas de duas chaves percorrem as quatro imagens
+	var migrated int
+	for page := 0; page < 3; page++ {
+		var result *MigrationResult
+		mustTx(t, stub, func() error {
+			var err error
+			result, err = contract.MigrateImageStatus(admin, 2)
+			return err
+		})
+		migrated += result.Migrated
+		if result.Done {
+			break
+		}
+		if page == 2 {
+			t.Fatal("expected migration to finish")
+		}
+	}
+
+	if migrated != len(legacy) {
+		t.Errorf("expected %d migrated images, got %d", len(legacy), migrated)
+	}
+	for _, hash := range legacy {
+		asset := storedImage(t, stub, hash)
+		if asset.Status != imageActive || asset.IDKit != "KIT-L" || asset.Version != 2 {
+			t.Errorf("unexpected migrated image: %+v", asset)
+		}
+	}
+	if string(stub.State[current]) != before {
+		t.Error("expected image with status to be left unchanged")
+	}
+
+	cursorKey, _ := stub.CreateCompositeKey("migracao~status", []string{})
+	if _, ok := stub.State[cursorKey]; ok {
+		t.Error("expected cursor to be removed at the end")
+	}
+}
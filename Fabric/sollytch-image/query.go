@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Limite de registros por página nas consultas paginadas
+const maxPageSize = 200
+
+// Página de imagens retornada pelas consultas paginadas
+type ImagePage struct {
+	Images         []*ImageAsset `json:"images"`
+	FetchedRecords int32         `json:"fetchedRecords"`
+	Bookmark       string        `json:"bookmark"`
+}
+
+/*
+	struct json dos filtros de QueryImages
+	Todos os campos são opcionais; from e to (RFC3339) delimitam o capturedAt
+	Registros antigos sem status só aparecem no filtro "ativa" depois de
+	migrados por MigrateImageStatus
+*/
+type ImageQuery struct {
+	IDKit    string `json:"idKit"`
+	TestID   string `json:"testId"`
+	DeviceID string `json:"deviceId"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Status   string `json:"status"`
+}
+
+// Função que valida o tamanho de página recebido
+func validatePageSize(pageSize int32) error {
+	if pageSize <= 0 || pageSize > maxPageSize {
+		return fmt.Errorf("pageSize deve estar entre 1 e %d", maxPageSize)
+	}
+	return nil
+}
+
+/*
+	Função que retorna as imagens ativas de um kit em páginas de pageSize
+	registros, a partir do bookmark da página anterior (vazio na primeira)
+	Imagens revogadas ou substituídas são omitidas, por isso uma página pode
+	trazer menos imagens que fetchedRecords. Consultas paginadas só podem
+	ser avaliadas (evaluate), não submetidas
+*/
+func (c *SmartContract) GetImagesByKitWithPagination(ctx contractapi.TransactionContextInterface, idKit string, pageSize int32, bookmark string) (*ImagePage, error) {
+	if idKit == "" {
+		return nil, fmt.Errorf("idKit não pode ser vazio")
+	}
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	iterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(
+		"kit~hashImagem",
+		[]string{idKit},
+		pageSize,
+		bookmark,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	page := &ImagePage{
+		Images:         []*ImageAsset{},
+		FetchedRecords: metadata.FetchedRecordsCount,
+		Bookmark:       metadata.Bookmark,
+	}
+
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, parts, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		image, err := c.GetImageByID(ctx, parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		if !imageIsActive(image) {
+			continue
+		}
+
+		page.Images = append(page.Images, image)
+	}
+
+	return page, nil
+}
+
+// Função que converte uma data RFC3339 do filtro para UTC, no formato gravado em capturedAt
+func normalizeQueryTime(field string, value string) (string, error) {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return "", fmt.Errorf("%s deve estar no formato RFC3339: %v", field, err)
+	}
+	return parsed.UTC().Format(time.RFC3339), nil
+}
+
+// Função que monta o seletor CouchDB a partir dos filtros de QueryImages
+func buildImageSelector(query *ImageQuery) (map[string]interface{}, error) {
+	// hashData separa as imagens dos demais documentos JSON do chaincode
+	// (ex.: donos de kit, que também possuem idKit)
+	selector := map[string]interface{}{
+		"hashData": map[string]interface{}{"$exists": true},
+	}
+
+	if query.IDKit != "" {
+		selector["idKit"] = query.IDKit
+	}
+	if query.TestID != "" {
+		selector["testId"] = query.TestID
+	}
+	if query.DeviceID != "" {
+		selector["deviceId"] = query.DeviceID
+	}
+
+	capturedAt := map[string]interface{}{}
+	if query.From != "" {
+		from, err := normalizeQueryTime("from", query.From)
+		if err != nil {
+			return nil, err
+		}
+		capturedAt["$gte"] = from
+	}
+	if query.To != "" {
+		to, err := normalizeQueryTime("to", query.To)
+		if err != nil {
+			return nil, err
+		}
+		capturedAt["$lte"] = to
+	}
+	if len(capturedAt) > 0 {
+		selector["capturedAt"] = capturedAt
+	}
+
+	// Igualdade em status, coberta pelos índices de status em META-INF;
+	// imagens antigas sem status precisam de MigrateImageStatus
+	switch query.Status {
+	case "":
+	case imageActive, imageRevoked, imageSuperseded:
+		selector["status"] = query.Status
+	default:
+		return nil, fmt.Errorf("status %s inválido", query.Status)
+	}
+
+	return selector, nil
+}
+
+/*
+	Função que consulta imagens por kit, teste, leitor, período de captura
+	(from/to) e status, em páginas de pageSize registros a partir do bookmark
+	Usa consulta rica do CouchDB, apoiada nos índices em META-INF; não
+	funciona com o LevelDB. Consultas paginadas só podem ser avaliadas
+*/
+func (c *SmartContract) QueryImages(ctx contractapi.TransactionContextInterface, queryJSON string, pageSize int32, bookmark string) (*ImagePage, error) {
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	var query ImageQuery
+	if err := json.Unmarshal([]byte(queryJSON), &query); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar filtros da consulta: %v", err)
+	}
+
+	selector, err := buildImageSelector(&query)
+	if err != nil {
+		return nil, err
+	}
+
+	queryString, err := json.Marshal(map[string]interface{}{"selector": selector})
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(string(queryString), pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("erro na consulta ao CouchDB: %v", err)
+	}
+	defer iterator.Close()
+
+	page := &ImagePage{
+		Images:         []*ImageAsset{},
+		FetchedRecords: metadata.FetchedRecordsCount,
+		Bookmark:       metadata.Bookmark,
+	}
+
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var asset ImageAsset
+		if err := json.Unmarshal(response.Value, &asset); err != nil {
+			return nil, fmt.Errorf("erro ao deserializar imagem %s: %v", response.Key, err)
+		}
+		if asset.ReuseClaims == nil {
+			asset.ReuseClaims = []ReuseClaim{}
+		}
+
+		page.Images = append(page.Images, &asset)
+	}
+
+	return page, nil
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Iterador sobre resultados já carregados
+type sliceIterator struct {
+	results []*queryresult.KV
+	next    int
+}
+
+func (s *sliceIterator) HasNext() bool { return s.next < len(s.results) }
+
+func (s *sliceIterator) Next() (*queryresult.KV, error) {
+	result := s.results[s.next]
+	s.next++
+	return result, nil
+}
+
+func (s *sliceIterator) Close() error { return nil }
+
+/*
+	MockStub com as consultas paginadas, que o shimtest não implementa
+	A paginação por chave composta usa a última chave como bookmark; a
+	consulta rica guarda o seletor recebido e devolve as chaves em queryKeys
+*/
+type paginatedStub struct {
+	*shimtest.MockStub
+	lastQuery string
+	queryKeys []string
+}
+
+func (s *paginatedStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	iterator, err := s.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer iterator.Close()
+
+	page := &sliceIterator{}
+	metadata := &pb.QueryResponseMetadata{Bookmark: bookmark}
+	for iterator.HasNext() && len(page.results) < int(pageSize) {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if bookmark != "" && response.Key <= bookmark {
+			continue
+		}
+		page.results = append(page.results, response)
+		metadata.Bookmark = response.Key
+	}
+	metadata.FetchedRecordsCount = int32(len(page.results))
+
+	return page, metadata, nil
+}
+
+func (s *paginatedStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	s.lastQuery = query
+
+	page := &sliceIterator{}
+	for _, key := range s.queryKeys {
+		page.results = append(page.results, &queryresult.KV{Key: key, Value: s.State[key]})
+	}
+
+	return page, &pb.QueryResponseMetadata{FetchedRecordsCount: int32(len(page.results)), Bookmark: "fim"}, nil
+}
+
+func TestBuildImageSelector(t *testing.T) {
+	exists := map[string]interface{}{"$exists": true}
+
+	tests := []struct {
+		name     string
+		query    ImageQuery
+		expected map[string]interface{}
+		err      string
+	}{
+		{
+			name:     "no filters",
+			query:    ImageQuery{},
+			expected: map[string]interface{}{"hashData": exists},
+		},
+		{
+			name:  "kit, test and device",
+			query: ImageQuery{IDKit: "KIT-A", TestID: "TEST-00001", DeviceID: "LEITOR-001"},
+			expected: map[string]interface{}{
+				"hashData": exists,
+				"idKit":    "KIT-A",
+				"testId":   "TEST-00001",
+				"deviceId": "LEITOR-001",
+			},
+		},
+		{
+			name:  "period normalised to UTC",
+			query: ImageQuery{From: "2025-01-01T09:00:00-03:00", To: "2025-01-31T23:59:59Z"},
+			expected: map[string]interface{}{
+				"hashData": exists,
+				"capturedAt": map[string]interface{}{
+					"$gte": "2025-01-01T12:00:00Z",
+					"$lte": "2025-01-31T23:59:59Z",
+				},
+			},
+		},
+		{
+			name:  "active status",
+			query: ImageQuery{Status: imageActive},
+			expected: map[string]interface{}{
+				"hashData": exists,
+				"status":   imageActive,
+			},
+		},
+		{
+			name:     "revoked",
+			query:    ImageQuery{Status: imageRevoked},
+			expected: map[string]interface{}{"hashData": exists, "status": imageRevoked},
+		},
+		{name: "invalid from", query: ImageQuery{From: "ontem"}, err: "from"},
+		{name: "invalid to", query: ImageQuery{To: "amanhã"}, err: "to"},
+		{name: "invalid status", query: ImageQuery{Status: "apagada"}, err: "status"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selector, err := buildImageSelector(&test.query)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(selector, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, selector)
+			}
+		})
+	}
+}
+
+func TestGetImagesByKitWithPagination(t *testing.T) {
+	stub := &paginatedStub{MockStub: shimtest.NewMockStub("sollytch-image", nil)}
+	ctx := newTestContext(stub.MockStub, newIdentity("Org1MSP", "leitor", nil))
+	ctx.SetStub(stub)
+	contract := new(SmartContract)
+
+	registerKits(t, stub.MockStub, ctx, "KIT-A")
+	hashes := []string{imageHash("a"), imageHash("b"), imageHash("c")}
+	for _, hash := range hashes {
+		mustTx(t, stub.MockStub, func() error {
+			return contract.StoreImage(ctx, "KIT-A", hash, captureMetadata(t, "TEST-00001"))
+		})
+	}
+	mustTx(t, stub.MockStub, func() error { return contract.RevokeImage(ctx, hashes[0], "foto desfocada") })
+
+	if _, err := contract.GetImagesByKitWithPagination(ctx, "KIT-A", 0, ""); err == nil {
+		t.Error("expected pageSize 0 to be rejected")
+	}
+	if _, err := contract.GetImagesByKitWithPagination(ctx, "", 2, ""); err == nil {
+		t.Error("expected empty idKit to be rejected")
+	}
+
+	seen := map[string]bool{}
+	fetched := int32(0)
+	bookmark := ""
+	for page := 0; page < 3; page++ {
+		result, err := contract.GetImagesByKitWithPagination(ctx, "KIT-A", 2, bookmark)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, image := range result.Images {
+			if !imageIsActive(image) {
+				t.Errorf("revoked image %s returned", image.HashData)
+			}
+			seen[image.HashData] = true
+		}
+		fetched += result.FetchedRecords
+		if result.FetchedRecords == 0 {
+			break
+		}
+		bookmark = result.Bookmark
+	}
+
+	// A página conta os registros lidos, inclusive a imagem revogada omitida
+	if fetched != 3 {
+		t.Errorf("expected 3 fetched records, got %d", fetched)
+	}
+	if len(seen) != 2 || !seen[hashes[1]] || !seen[hashes[2]] {
+		t.Errorf("expected the two active images, got %v", seen)
+	}
+}
+
+func TestQueryImages(t *testing.T) {
+	stub := &paginatedStub{MockStub: shimtest.NewMockStub("sollytch-image", nil)}
+	ctx := newTestContext(stub.MockStub, newIdentity("Org1MSP", "leitor", nil))
+	ctx.SetStub(stub)
+	contract := new(SmartContract)
+
+	hash := imageHash("a")
+	registerKits(t, stub.MockStub, ctx, "KIT-A")
+	mustTx(t, stub.MockStub, func() error {
+		return contract.StoreImage(ctx, "KIT-A", hash, captureMetadata(t, "TEST-00001"))
+	})
+	stub.queryKeys = []string{hash}
+
+	page, err := contract.QueryImages(ctx, `{"idKit":"KIT-A","status":"revogada"}`, 10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Images) != 1 || page.Images[0].HashData != hash || page.Bookmark != "fim" {
+		t.Errorf("unexpected page: %+v", page)
+	}
+
+	var query struct {
+		Selector map[string]interface{} `json:"selector"`
+	}
+	if err := json.Unmarshal([]byte(stub.lastQuery), &query); err != nil {
+		t.Fatal(err)
+	}
+	if query.Selector["idKit"] != "KIT-A" || query.Selector["status"] != imageRevoked {
+		t.Errorf("unexpected selector: %s", stub.lastQuery)
+	}
+
+	if _, err := contract.QueryImages(ctx, `{"status":"apagada"}`, 10, ""); err == nil {
+		t.Error("expected invalid status to be rejected")
+	}
+	if _, err := contract.QueryImages(ctx, `{}`, maxPageSize+1, ""); err == nil {
+		t.Error("expected oversized page to be rejected")
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Nome do evento emitido quando um hash já registrado é enviado por outro kit
+const imageReuseEvent = "ImageReuse"
+
+// Reivindicação de uma imagem por um kit diferente do original
+type ReuseClaim struct {
+	IDKit     string `json:"idKit"`
+	TestID    string `json:"testId"`
+	DeviceID  string `json:"deviceId"`
+	ClaimedAt string `json:"claimedAt"`
+}
+
+// Payload do evento de reuso de imagem
+type imageReuseEventPayload struct {
+	HashData     string `json:"hashData"`
+	OriginalKit  string `json:"originalKit"`
+	ClaimingKit  string `json:"claimingKit"`
+	OriginalTest string `json:"originalTestId"`
+	ClaimingTest string `json:"claimingTestId"`
+}
+
+// Imagem reivindicada por mais de um kit
+type ImageReuse struct {
+	HashData    string       `json:"hashData"`
+	OriginalKit string       `json:"originalKit"`
+	Claims      []ReuseClaim `json:"claims"`
+}
+
+// Função que indica se o kit já reivindicou a imagem (original ou reuso)
+func claimedByKit(asset *ImageAsset, idKit string) bool {
+	if asset.IDKit == idKit {
+		return true
+	}
+	for _, claim := range asset.ReuseClaims {
+		if claim.IDKit == idKit {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	Função que registra o reuso de uma imagem por outro kit
+	O registro original (kit, metadados) é mantido como evidência; a nova
+	reivindicação é anexada, o kit passa a listar a imagem pelo índice
+	"kit~hashImagem" e o hash entra no índice "reuso~hashImagem". Retorna o
+	payload do evento ImageReuse, com os dois kits, emitido por quem chamou
+*/
+func recordImageReuse(ctx contractapi.TransactionContextInterface, asset *ImageAsset, idKit string, metadata *ImageMetadata, claimedAt string) (*imageReuseEventPayload, error) {
+	asset.Reused = true
+	asset.ReuseClaims = append(asset.ReuseClaims, ReuseClaim{
+		IDKit:     idKit,
+		TestID:    metadata.TestID,
+		DeviceID:  metadata.DeviceID,
+		ClaimedAt: claimedAt,
+	})
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(
+		"kit~hashImagem",
+		[]string{idKit, asset.HashData},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(indexKey, []byte{0x00}); err != nil {
+		return nil, err
+	}
+
+	reuseKey, err := ctx.GetStub().CreateCompositeKey(
+		"reuso~hashImagem",
+		[]string{asset.HashData},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(reuseKey, []byte{0x00}); err != nil {
+		return nil, err
+	}
+
+	return &imageReuseEventPayload{
+		HashData:     asset.HashData,
+		OriginalKit:  asset.IDKit,
+		ClaimingKit:  idKit,
+		OriginalTest: asset.TestID,
+		ClaimingTest: metadata.TestID,
+	}, nil
+}
+
+/*
+	Função que emite o evento ImageReuse
+	O Fabric mantém apenas um evento por transação, por isso o envio em lote
+	emite os reusos juntos (ver StoreImages)
+*/
+func emitImageReuse(ctx contractapi.TransactionContextInterface, reuse *imageReuseEventPayload) error {
+	payload, err := json.Marshal(reuse)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent(imageReuseEvent, payload)
+}
+
+/*
+	Função que lista todos os hashes de imagem reivindicados por mais de um
+	kit, com o kit original e as reivindicações posteriores
+*/
+func (c *SmartContract) GetReusedImages(ctx contractapi.TransactionContextInterface) ([]*ImageReuse, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(
+		"reuso~hashImagem",
+		[]string{},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	results := []*ImageReuse{}
+
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, parts, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		image, err := c.GetImageByID(ctx, parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("erro ao carregar imagem %s: %v", parts[0], err)
+		}
+
+		results = append(results, &ImageReuse{
+			HashData:    image.HashData,
+			OriginalKit: image.IDKit,
+			Claims:      image.ReuseClaims,
+		})
+	}
+
+	return results, nil
+}
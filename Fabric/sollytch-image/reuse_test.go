@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+func TestClaimedByKit(t *testing.T) {
+	asset := &ImageAsset{
+		IDKit: "KIT-A",
+		ReuseClaims: []ReuseClaim{
+			{IDKit: "KIT-B", TestID: "TEST-00002"},
+		},
+	}
+
+	tests := []struct {
+		kit      string
+		expected bool
+	}{
+		{"KIT-A", true},
+		{"KIT-B", true},
+		{"KIT-C", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := claimedByKit(asset, test.kit); got != test.expected {
+			t.Errorf("claimedByKit(%q): expected %v, got %v", test.kit, test.expected, got)
+		}
+	}
+
+	if claimedByKit(&ImageAsset{IDKit: "KIT-A"}, "KIT-B") {
+		t.Error("expected a legacy record without claims to belong only to its kit")
+	}
+}
+
+func TestGetReusedImages(t *testing.T) {
+	stub := shimtest.NewMockStub("sollytch-image", nil)
+	org1 := newTestContext(stub, newIdentity("Org1MSP", "leitor", nil))
+	org2 := newTestContext(stub, newIdentity("Org2MSP", "leitor", nil))
+	contract := new(SmartContract)
+	reused := imageHash("cassete")
+
+	registerKits(t, stub, org1, "KIT-A")
+	registerKits(t, stub, org2, "KIT-B", "KIT-C")
+
+	mustTx(t, stub, func() error { return contract.StoreImage(org1, "KIT-A", reused, captureMetadata(t, "TEST-00001")) })
+	mustTx(t, stub, func() error { return contract.StoreImage(org1, "KIT-A", imageHash("unica"), captureMetadata(t, "TEST-00001")) })
+
+	images, err := contract.GetReusedImages(org1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 0 {
+		t.Fatalf("expected no reused images, got %d", len(images))
+	}
+
+	// Duas reivindicações do mesmo hash; reenviar pelo mesmo kit não duplica
+	mustTx(t, stub, func() error { return contract.StoreImage(org2, "KIT-B", reused, captureMetadata(t, "TEST-00002")) })
+	mustTx(t, stub, func() error { return contract.StoreImage(org2, "KIT-C", reused, captureMetadata(t, "TEST-00003")) })
+	mustTx(t, stub, func() error { return contract.StoreImage(org2, "KIT-B", reused, captureMetadata(t, "TEST-00002")) })
+
+	images, err = contract.GetReusedImages(org1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 1 {
+		t.Fatalf("expected one reused image, got %d", len(images))
+	}
+
+	image := images[0]
+	if image.HashData != reused || image.OriginalKit != "KIT-A" || len(image.Claims) != 2 {
+		t.Fatalf("unexpected reused image: %+v", image)
+	}
+	if image.Claims[0].IDKit != "KIT-B" || image.Claims[0].TestID != "TEST-00002" || image.Claims[1].IDKit != "KIT-C" {
+		t.Errorf("unexpected claims: %+v", image.Claims)
+	}
+}
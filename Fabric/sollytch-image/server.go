@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+/*
+	Este arquivo é idêntico em sollytch-chain e sollytch-image. Cada
+	chaincode é um módulo Go próprio, construído com a sua pasta como
+	contexto do Docker, então um pacote compartilhado fora dela não entra
+	na imagem. O TestServerCopyInSync mantém as duas cópias iguais
+
+	Modo chaincode-as-a-service (RUN_CCAAS=true), com as mesmas variáveis
+	de ambiente do chaincode cc-tools:
+	- CHAINCODE_SERVER_ADDRESS: endereço em que o servidor escuta
+	- CHAINCODE_ID: package ID atribuído na instalação
+	- TLS_ENABLED: TLS mútuo habilitado por padrão, com KEY_PATH, CERT_PATH
+	  e CA_CERT_PATH (CA usada para validar o certificado do peer); só
+	  "false" o desabilita
+	Opcionais:
+	- HEALTH_ADDRESS: endereço do endpoint HTTP /healthz
+	- SHUTDOWN_TIMEOUT: tempo máximo para concluir transações em andamento
+	  ao receber SIGTERM/SIGINT (duração Go, padrão 30s)
+*/
+const defaultShutdownTimeout = 30 * time.Second
+
+/*
+	Chaincode que acompanha as transações em andamento, para que o
+	encerramento aguarde sua conclusão e recuse novas transações
+*/
+type drainingChaincode struct {
+	cc       shim.Chaincode
+	mu       sync.RWMutex
+	draining bool
+	inFlight sync.WaitGroup
+}
+
+// Função que registra o início de uma transação, se o servidor não estiver encerrando
+func (d *drainingChaincode) begin() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.draining {
+		return false
+	}
+	d.inFlight.Add(1)
+	return true
+}
+
+func (d *drainingChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	if !d.begin() {
+		return shim.Error("chaincode em encerramento")
+	}
+	defer d.inFlight.Done()
+
+	return d.cc.Init(stub)
+}
+
+func (d *drainingChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	if !d.begin() {
+		return shim.Error("chaincode em encerramento")
+	}
+	defer d.inFlight.Done()
+
+	return d.cc.Invoke(stub)
+}
+
+// Função que indica se o servidor ainda aceita transações
+func (d *drainingChaincode) healthy() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return !d.draining
+}
+
+/*
+	Função que para de aceitar transações e aguarda as que estão em
+	andamento, até o timeout. Retorna false se o timeout foi atingido
+*/
+func (d *drainingChaincode) drain(timeout time.Duration) bool {
+	d.mu.Lock()
+	d.draining = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Função que inicia o endpoint /healthz: 200 enquanto aceita transações, 503 ao encerrar
+func startHealthServer(address string, chaincode *drainingChaincode) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if !chaincode.healthy() {
+			http.Error(w, "encerrando", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("erro no endpoint de saúde: %v", err)
+		}
+	}()
+
+	return server
+}
+
+// Função que lê o SHUTDOWN_TIMEOUT, usando o padrão quando ausente
+func shutdownTimeout() (time.Duration, error) {
+	value := os.Getenv("SHUTDOWN_TIMEOUT")
+	if value == "" {
+		return defaultShutdownTimeout, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("SHUTDOWN_TIMEOUT inválido: %s", value)
+	}
+
+	return timeout, nil
+}
+
+/*
+	Função que executa o chaincode como serviço externo
+	Encerra de forma graciosa ao receber SIGTERM/SIGINT: o /healthz passa a
+	responder 503, novas transações são recusadas e as em andamento são
+	concluídas antes da saída
+*/
+func runCCaaS(cc shim.Chaincode) error {
+	address := os.Getenv("CHAINCODE_SERVER_ADDRESS")
+	ccid := os.Getenv("CHAINCODE_ID")
+
+	tlsProps, err := getTLSProperties()
+	if err != nil {
+		return err
+	}
+
+	timeout, err := shutdownTimeout()
+	if err != nil {
+		return err
+	}
+
+	chaincode := &drainingChaincode{cc: cc}
+
+	server := &shim.ChaincodeServer{
+		CCID:     ccid,
+		Address:  address,
+		CC:       chaincode,
+		TLSProps: *tlsProps,
+	}
+
+	var health *http.Server
+	if healthAddress := os.Getenv("HEALTH_ADDRESS"); healthAddress != "" {
+		health = startHealthServer(healthAddress, chaincode)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Start()
+	}()
+
+	log.Printf("chaincode %s escutando em %s", ccid, address)
+
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-signals:
+		log.Printf("sinal %s recebido, encerrando", sig)
+	}
+
+	if !chaincode.drain(timeout) {
+		log.Printf("timeout de %s atingido com transações em andamento", timeout)
+	}
+
+	if health != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := health.Shutdown(ctx); err != nil {
+			log.Printf("erro ao encerrar endpoint de saúde: %v", err)
+		}
+	}
+
+	return nil
+}
+
+/*
+	Função que carrega chave, certificado e CA para o TLS mútuo com o peer
+	Sem TLS_ENABLED=false explícito, a falta de algum arquivo impede o
+	servidor de subir em vez de cair para uma conexão sem TLS
+*/
+func getTLSProperties() (*shim.TLSProperties, error) {
+	if enableTLS := os.Getenv("TLS_ENABLED"); enableTLS == "false" {
+		log.Printf("TLS desabilitado (TLS_ENABLED=false)")
+		return &shim.TLSProperties{
+			Disabled: true,
+		}, nil
+	}
+
+	log.Printf("TLS habilitado")
+
+	key, err := os.ReadFile(os.Getenv("KEY_PATH"))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler a chave (KEY_PATH): %v", err)
+	}
+
+	cert, err := os.ReadFile(os.Getenv("CERT_PATH"))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o certificado (CERT_PATH): %v", err)
+	}
+
+	caCert, err := os.ReadFile(os.Getenv("CA_CERT_PATH"))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o certificado da CA (CA_CERT_PATH): %v", err)
+	}
+
+	return &shim.TLSProperties{
+		Disabled:      false,
+		Key:           key,
+		Cert:          cert,
+		ClientCACerts: caCert,
+	}, nil
+}
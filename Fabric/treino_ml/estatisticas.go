@@ -0,0 +1,127 @@
+// estatisticas.go
+package main
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "log"
+    "math"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// Mesmo formato de TrainingStats do chaincode (sollytch-chain/drift.go)
+type FeatureStats struct {
+    Mean float64 `json:"mean"`
+    Std  float64 `json:"std"`
+    Min  float64 `json:"min"`
+    Max  float64 `json:"max"`
+}
+
+type TrainingStats struct {
+    Samples     int                     `json:"samples"`
+    Features    map[string]FeatureStats `json:"features"`
+    ClassPriors map[string]float64      `json:"class_priors"`
+}
+
+/*
+    Calcula as estatísticas de treino de um ensaio_*.csv: média, desvio
+    padrão (populacional), mínimo e máximo de cada feature e a proporção
+    de cada classe da última coluna (a variável-alvo). Valores ausentes
+    ("?" ou vazios) não entram nas estatísticas da feature
+*/
+func computeTrainingStats(path string) (*TrainingStats, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    rows, err := csv.NewReader(file).ReadAll()
+    if err != nil {
+        return nil, fmt.Errorf("erro ao ler CSV: %v", err)
+    }
+    if len(rows) < 2 {
+        return nil, fmt.Errorf("CSV sem amostras")
+    }
+
+    header := rows[0]
+    target := len(header) - 1
+
+    stats := &TrainingStats{
+        Samples:     len(rows) - 1,
+        Features:    map[string]FeatureStats{},
+        ClassPriors: map[string]float64{},
+    }
+
+    for column := 0; column < target; column++ {
+        // Média e variância pelo método de Welford
+        var count int
+        var mean, m2 float64
+        feature := FeatureStats{}
+
+        for _, row := range rows[1:] {
+            raw := strings.TrimSpace(row[column])
+            if raw == "" || raw == "?" {
+                continue
+            }
+            value, err := strconv.ParseFloat(raw, 64)
+            if err != nil {
+                return nil, fmt.Errorf("valor invalido na coluna %s: %q", header[column], raw)
+            }
+
+            if count == 0 || value < feature.Min {
+                feature.Min = value
+            }
+            if count == 0 || value > feature.Max {
+                feature.Max = value
+            }
+
+            count++
+            delta := value - mean
+            mean += delta / float64(count)
+            m2 += delta * (value - mean)
+        }
+
+        if count == 0 {
+            return nil, fmt.Errorf("coluna %s sem valores", header[column])
+        }
+
+        feature.Mean = mean
+        feature.Std = math.Sqrt(m2 / float64(count))
+        stats.Features[header[column]] = feature
+    }
+
+    for _, row := range rows[1:] {
+        stats.ClassPriors[row[target]]++
+    }
+    for class := range stats.ClassPriors {
+        stats.ClassPriors[class] /= float64(stats.Samples)
+    }
+
+    return stats, nil
+}
+
+func main() {
+    if len(os.Args) != 3 {
+        log.Fatal("Uso: go run estatisticas.go <ensaio.csv> <estatisticas.json>")
+    }
+
+    stats, err := computeTrainingStats(os.Args[1])
+    if err != nil {
+        log.Fatalf("Erro ao calcular estatísticas: %v", err)
+    }
+
+    data, err := json.MarshalIndent(stats, "", "  ")
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    if err := os.WriteFile(os.Args[2], data, 0644); err != nil {
+        log.Fatalf("Erro ao salvar estatísticas: %v", err)
+    }
+
+    fmt.Printf("Estatísticas de %d amostras salvas em: %s\n", stats.Samples, os.Args[2])
+}